@@ -12,7 +12,16 @@ import(
 	Resources 		"github.com/ghepesdoru/bookwormFTP/core/parsers/resource"
 	Requester 		"github.com/ghepesdoru/bookwormFTP/client/requester"
 	Settings 		"github.com/ghepesdoru/bookwormFTP/client/settings"
+	Transcript		"github.com/ghepesdoru/bookwormFTP/client/transcript"
 	Status 			"github.com/ghepesdoru/bookwormFTP/core/codes"
+	FilePath		"path/filepath"
+	Path			"path"
+	"context"
+	"crypto/tls"
+	"io"
+	"os"
+	"strconv"
+	"strings"
 )
 
 /* Constants definition */
@@ -26,6 +35,8 @@ const (
 	OPT_Disconnected 	= "disconnected"
 	OPT_LoggedIn		= "logged_in"
 	OPT_PassiveMode		= "passive"
+	OPT_ActiveMode		= "active"
+	OPT_Secure			= "secure"
 	OPT_ExtendedPassive = "extended_passive"
 	OPT_CurrentDir		= "cwd"
 	OPT_Account			= "account"
@@ -36,6 +47,8 @@ const (
 	OPT_ByteSize		= "byte_size"
 	OPT_FileStructure	= "file_structure"
 	OPT_DownloadOverlap	= "download_overlap"
+	OPT_UploadOverlap	= "upload_overlap"
+	OPT_LinkPolicy		= "link_policy"
 )
 
 var (
@@ -55,15 +68,75 @@ var (
 	ERR_HELPNotImplemented	 = fmt.Errorf("Help suggestions not implemented at server side.")
 	ERR_LangNotSupported	 = fmt.Errorf("Language not supported")
 	ERR_NoLanguageSupport	 = fmt.Errorf("No language packs are available at server side.")
+	ERR_InvalidLocalFile	 = fmt.Errorf("Invalid local file. Please specify the path of an existing file.")
+	ERR_SIZENotImplemented	 = fmt.Errorf("Remote file size not supported at server side.")
+	ERR_NotSecure			 = fmt.Errorf("The control connection is not secured.")
+	ERR_UnknownLinkTarget	 = fmt.Errorf("Unable to recreate a symbolic link without a listed target.")
+	ERR_RootRemoval			 = fmt.Errorf("The root directory can not be removed.")
+	ERR_RemoveRefused		 = fmt.Errorf("Resource removal refused by the server.")
+	ERR_TransferInProgress	 = fmt.Errorf("A streamed transfer is in progress. Close it before using the connection.")
+	ERRF_RemoveFailed		 = "Unable to remove %s. Original error: %w"
+	ERR_Timeout				 = Requester.ERR_Timeout
 )
 
+/* Dial, greeting, reply and data idle timeouts. Zero values use the defaults, negative values disable the timeout */
+type Timeouts = Requester.Timeouts
+
 type DownloadOverlapAction string
 const (
 	DO_OverWrite		DownloadOverlapAction = "overwrite"
 	DO_CreateNew		DownloadOverlapAction = "create_new"
 	DO_IgnoreExisting	DownloadOverlapAction = "ignore_existing"
+	DO_Resume			DownloadOverlapAction = "resume"
+)
+
+type UploadOverlapAction string
+const (
+	UO_OverWrite		UploadOverlapAction = "overwrite"
+	UO_CreateUnique		UploadOverlapAction = "create_unique"
+	UO_IgnoreExisting	UploadOverlapAction = "ignore_existing"
 )
 
+/* Symbolic links handling by the recursive operations */
+type LinkPolicy string
+const (
+	LP_Follow			LinkPolicy = "follow"
+	LP_Skip				LinkPolicy = "skip"
+	LP_Recreate			LinkPolicy = "recreate"
+)
+
+/* Size mismatch between the local file and it's remote copy after an upload */
+type SizeMismatchError struct {
+	Path		string
+	LocalSize	int64
+	RemoteSize	int64
+}
+
+func (e *SizeMismatchError) Error() string {
+	return fmt.Sprintf("Upload error: Remote file %s size (%d bytes) does not match the local file size (%d bytes).", e.Path, e.RemoteSize, e.LocalSize)
+}
+
+/* Failures of a recursive removal. Each failure is reported, the removal continuing with the other resources */
+type DeleteTreeError struct {
+	Path		string
+	Failures	[]error
+}
+
+func (e *DeleteTreeError) Error() string {
+	var messages []string
+
+	for _, failure := range e.Failures {
+		messages = append(messages, failure.Error())
+	}
+
+	return fmt.Sprintf("Delete error: Unable to remove %d resources of %s: %s", len(e.Failures), e.Path, strings.Join(messages, " "))
+}
+
+/* Gets all the failures (errors.Is and errors.As check each of them) */
+func (e *DeleteTreeError) Unwrap() []error {
+	return e.Failures
+}
+
 /* BookwormFTP Client type definition */
 type Client struct {
 	Commands	*ClientCommands.Commands
@@ -74,62 +147,32 @@ type Client struct {
 	features	*Features.Features
 	Resources	*Resources.Resource
 	localFM		*FileManager.FileManager
+	streaming	bool	/* A streamed transfer keeps the control connection busy */
 }
 
 /* Instantiates a new client (IPv4 preferred), and takes all possible actions based on address url */
-func NewClient(address string) (client *Client, err error) {
-	var dir, system string
-	// TODO: IMpose a delay on connect to close the connection after 15 seconds if unable to resolve host/establish connection.
-
-	client, err = newClient(address, Address.IPvAny)
-
-	if err != nil {
-		return
-	}
-
-	/* Authenticate with USER and PASS */
-	_, err = client.LogIn(client.credentials)
-
-	if err != nil {
-		return
-	}
-
-	/* Get system type */
-	if system, err = client.System(); err == nil {
-		client.settings.Add(OPT_System, system)
-	}
-
-	/* Grab supported features list */
-	if _, err = client.Features(); err != nil {
-		return
-	}
+func NewClient(address string, timeouts ...Timeouts) (client *Client, err error) {
+	return connect(address, nil, nil, timeouts...)
+}
 
-	/* Get the current directory */
-	dir, err = client.Commands.PWD()
-	if err == nil {
-		client.path.ChangeCurrentDir(dir)
+/* Instantiates a new client over FTPS: implicit for ftps:// addresses, explicit (AUTH TLS) otherwise. The configuration
+ is used for the control and all data connections */
+func NewClientTLS(address string, config *tls.Config, timeouts ...Timeouts) (*Client, error) {
+	if config == nil {
+		config = &tls.Config{}
 	}
 
-	/* Check for initial path, and navigate there if available */
-	dir, _ = client.requester.GetInitialPath()
-
-	/* Enforce transfer parameters defaults (as specified by RFC959) */
-	client.RepresentationType(ClientCommands.TYPE_Ascii, ClientCommands.FMTCTRL_NonPrint)
-	client.TransferMode(ClientCommands.TRANSFER_Stream)
-	client.FileStructure(ClientCommands.FILESTRUCT_File)
-
-	if dir != EmptyString {
-		_, err = client.ChangeDir(dir)
-	} else {
-		_, err = client.List()
-	}
+	return connect(address, config, nil, timeouts...)
+}
 
-	return
+/* Instantiates a new client recording the whole session transcript (login included) with the specified recorder */
+func NewClientRecorded(address string, recorder *Transcript.Recorder, timeouts ...Timeouts) (*Client, error) {
+	return connect(address, nil, recorder, timeouts...)
 }
 
 /* Instantiates a new client and tarts downloading the specified Resources */
-func NewDownload(address string) (client *Client, err error) {
-	client, err = NewClient(address)
+func NewDownload(address string, timeouts ...Timeouts) (client *Client, err error) {
+	client, err = NewClient(address, timeouts...)
 
 	if err != nil {
 		return
@@ -153,13 +196,13 @@ func NewDownload(address string) (client *Client, err error) {
 }
 
 /* Instantiates a new client forcing IP version 4 */
-func NewIPv4(address string) (*Client, error) {
-	return newClient(address, Address.IPv4)
+func NewIPv4(address string, timeouts ...Timeouts) (*Client, error) {
+	return newClient(address, Address.IPv4, nil, timeouts...)
 }
 
 /* Instantiates a new client forcing IP version 6 */
-func NewIPv6(address string) (*Client, error) {
-	return newClient(address, Address.IPv6)
+func NewIPv6(address string, timeouts ...Timeouts) (*Client, error) {
+	return newClient(address, Address.IPv6, nil, timeouts...)
 }
 
 /* Register account information */
@@ -167,6 +210,16 @@ func (c *Client) Account(accountInfo string) (ok bool, err error) {
 	return c.account(accountInfo, false, false)
 }
 
+/* Establish an active mode data connection (the server connects to a local listener), using PORT or EPRT for IPv6 */
+func (c *Client) ActiveMode() (bool, error) {
+	return c.activeMode(!c.IsIPv4())
+}
+
+/* Establish an active mode data connection using EPRT */
+func (c *Client) ActiveModeEPRT() (bool, error) {
+	return c.activeMode(true)
+}
+
 /* Changes the current working directory on the host */
 func (c *Client) ChangeDir(path string) (ok bool, err error) {
 	var dir string
@@ -214,6 +267,24 @@ func (c *Client) ChangeToParentDir() (ok bool, err error) {
 	return ok, err
 }
 
+/* Drops TLS on the control connection after login (CCC), keeping the data connections protection level */
+func (c *Client) ClearControlChannel() (ok bool, err error) {
+	/* Check connection ready state before executing command */
+	if ok, err = c.isReady(); !ok {
+		return
+	}
+
+	if !c.IsSecure() {
+		return false, ERR_NotSecure
+	}
+
+	if ok, err = c.Commands.CCC(); ok {
+		c.settings.Get(OPT_Secure).Reset()
+	}
+
+	return
+}
+
 /* Get the current working directory on host */
 func (c *Client) CurrentDir() string {
 	return c.path.GetCurrentDir()
@@ -282,7 +353,7 @@ func (c *Client) Delete(resourcePath string) (ok bool, err error) {
 	if res != nil {
 		if res.IsDir() {
 			/* Remove each file in the container */
-			ok, err = c.truncateDir(res)
+			ok, err = c.truncateDir(res, newTraversal(c.path.GetCurrentDir()))
 		} else {
 			/* Check if the specified file can be removed */
 			ok, err = c.deleteFile(res)
@@ -300,6 +371,56 @@ func (c *Client) Delete(resourcePath string) (ok bool, err error) {
 	return
 }
 
+/* Deletes the specified remote file or directory with all it's content: depth first, removing each emptied directory
+ (RMD). Failures do not stop the removal, all of them being reported by a *DeleteTreeError. Symbolic links are removed
+ themselves, never followed */
+func (c *Client) DeleteTree(resourcePath string) (ok bool, err error) {
+	var originalPath string
+	var originalResources *Resources.Resource = c.Resources
+	var failures []error
+
+	/* Check connection ready state before executing command */
+	if ok, err = c.isReady(); !ok {
+		return
+	}
+
+	originalPath = c.remotePath(EmptyString)
+	if resourcePath = c.remotePath(resourcePath); resourcePath == RootDir {
+		return false, ERR_RootRemoval
+	}
+
+	/* Locate the resource in it's container's listing */
+	dir := Path.Dir(resourcePath)
+	res, err := c.stat(resourcePath)
+	if err != nil {
+		c.Resources = originalResources
+		return false, err
+	}
+
+	if res.IsDir() {
+		failures = c.deleteTree(res, resourcePath, failures)
+	} else {
+		failures = c.removeFile(res, resourcePath, failures)
+	}
+
+	if originalPath == resourcePath || strings.HasPrefix(originalPath, resourcePath + RootDir) {
+		/* The current directory was removed */
+		c.ChangeDir(dir)
+	} else {
+		c.Resources = originalResources
+
+		if originalPath == dir {
+			c.List()
+		}
+	}
+
+	if len(failures) > 0 {
+		return false, &DeleteTreeError{resourcePath, failures}
+	}
+
+	return true, nil
+}
+
 /* Download the specified file */
 func (c *Client) Download(fileName string) (ok bool, err error) {
 	/* Check connection ready state before executing command */
@@ -307,8 +428,8 @@ func (c *Client) Download(fileName string) (ok bool, err error) {
 		return
 	}
 
-	if !c.InPassiveMode() {
-		_, err = c.PassiveMode()
+	if !c.inDataMode() {
+		_, err = c.dataMode()
 		defer c.RestoreConnections();
 	}
 
@@ -317,6 +438,13 @@ func (c *Client) Download(fileName string) (ok bool, err error) {
 	file := c.path.SplitFile(fileName)
 
 	if ok, err = c.ChangeDir(dir); ok {
+		/* The initial directory is not listed until navigating away from it */
+		if c.Resources == nil {
+			if _, err = c.List(); err != nil {
+				return false, err
+			}
+		}
+
 		/* Use the last subdirectory as container for the downloaded content */
 		if len(file) == 0 {
 			/* Download the entire current directory */
@@ -341,6 +469,12 @@ func (c *Client) Download(fileName string) (ok bool, err error) {
 	return
 }
 
+/* Download the specified file or directory, giving up once the context is done (transfers in progress are aborted) */
+func (c *Client) DownloadContext(ctx context.Context, fileName string) (bool, error) {
+	defer c.requester.BindContext(ctx)()
+	return c.Download(fileName)
+}
+
 /* Extracts the server supported features map */
 func (c *Client) Features() (feat *Features.Features, err error) {
 	/* Check connection ready state before executing command */
@@ -420,6 +554,11 @@ func (c *Client) Host(virtualHost string) (bool, error) {
 	return c.Commands.HOST(virtualHost)
 }
 
+/* Checks if the client accepts data connections on a local listener (active mode) */
+func (c *Client) InActiveMode() bool {
+	return c.requester.InActiveMode()
+}
+
 /* Checks if the client is in any of the supported passive modes */
 func (c *Client) InPassiveMode() bool {
 	return c.settings.Get(OPT_PassiveMode).Is(true) || c.settings.Get(OPT_ExtendedPassive).Is(true)
@@ -430,6 +569,11 @@ func (c *Client) IsIPv4() bool {
 	return c.requester.GetHostAddr().IPFamily == Address.IPv4
 }
 
+/* Checks if the control connection is secured using TLS */
+func (c *Client) IsSecure() bool {
+	return c.requester.IsSecure()
+}
+
 /* Set the client language in use */
 func (c *Client) Language(language string) (ok bool, err error) {
 	var langs []string
@@ -484,11 +628,23 @@ func (c *Client) List() (*Resources.Resource, error) {
 	return c.ListDir(c.path.GetCurrentDir())
 }
 
+/* Lists the current directory contents, giving up once the context is done */
+func (c *Client) ListContext(ctx context.Context) (*Resources.Resource, error) {
+	defer c.requester.BindContext(ctx)()
+	return c.List()
+}
+
 /* Lists the contents of the specified directory */
 func (c *Client) ListDir(dir string) (*Resources.Resource, error) {
 	return c.list(c.path.ToCurrentDir(dir), false)
 }
 
+/* Lists the contents of the specified directory, giving up once the context is done */
+func (c *Client) ListDirContext(ctx context.Context, dir string) (*Resources.Resource, error) {
+	defer c.requester.BindContext(ctx)()
+	return c.ListDir(dir)
+}
+
 /* Lists the specified file properties */
 func (c *Client) ListFile(fileAndPath string) (*Resources.Resource, error) {
 	return c.list(c.path.ToCurrentDir(fileAndPath), true)
@@ -552,6 +708,11 @@ func (c *Client) LogIn(credentials *Credentials.Credentials) (ok bool, err error
 			if ok {
 				/* User account specified/not required */
 				c.settings.Get(OPT_LoggedIn).Set(true)
+
+				/* Secure control connections imply protected data connections */
+				if c.settings.Get(OPT_Secure).Is(true) {
+					ok, err = c.protectData()
+				}
 			}
 		}
 	}
@@ -562,48 +723,59 @@ func (c *Client) LogIn(credentials *Credentials.Credentials) (ok bool, err error
 /* Creates a new directory in the current working directory, or recreate the specified path */
 func (c *Client) MakeDirectory(dirName string) (ok bool, err error) {
 	var creationPath []string
-	var originalPath string
+	var originalPath, startPath, target string
 
 	if ok, err = c.isReady(); !ok {
 		return
 	}
 
 	if !c.features.Supports("MKD") {
-		err = ERR_MKDNotImplemented
-		return
+		return false, ERR_MKDNotImplemented
 	}
 
 	/* Assume the any relative path as part of the current directory's sub path */
 	originalPath = c.CurrentDir()
-	if !c.path.IsAbs(dirName) {
-		dirName = c.path.ToCurrentDir(dirName)
-	}
+	target = strings.TrimRight(c.path.ToCurrentDir(dirName), RootDir) + RootDir
+	startPath = strings.TrimRight(originalPath, RootDir) + RootDir
 
-	if c.path.InCurrentDir(dirName) {
-		creationPath = c.path.ExtractSubPath(dirName)
-	} else {
-		creationPath = c.path.SplitDirList(dirName)
+	if !strings.HasPrefix(target, startPath) {
+		/* Unrelated path, recreate it starting from the root directory */
+		if ok, err = c.ChangeDir(RootDir); !ok {
+			return
+		}
+
+		startPath = RootDir
 	}
 
+	creationPath = strings.Split(target[len(startPath):], RootDir)
+
 	/* Recreate the entire specified path */
 	for _, d := range creationPath {
-		if !c.Resources.ContainsByName(d) {
-			if ok, err = c.Commands.MKD(d); !ok {
-				break
-			}
+		if d == EmptyString {
+			continue
+		}
+
+		if c.Resources == nil || !c.Resources.ContainsByName(d) {
+			ok, err = c.Commands.MKD(d)
 		}
 
 		/* Change to the existing directory with the specified name */
-		if ok, err = c.ChangeDir(d); !ok {
+		if ok, e := c.ChangeDir(d); !ok {
+			if err == nil {
+				err = e
+			}
+
 			break
 		}
-	}
 
-	if len(creationPath) > 0 {
-		/* Restore the initial path */
-		c.ChangeDir(originalPath)
+		err = nil
 	}
 
+	ok = err == nil
+
+	/* Restore the initial path */
+	c.ChangeDir(originalPath)
+
 	return
 }
 
@@ -791,6 +963,54 @@ func (c *Client) Rename(resourceName string, renameTo string) (ok bool, err erro
 func (c *Client) RestoreConnections() {
 	c.settings.Get(OPT_ExtendedPassive).Reset()
 	c.settings.Get(OPT_PassiveMode).Reset()
+	c.requester.CloseDataListener()
+}
+
+/* Resumes the upload of a local file, starting at the current size of it's remote copy. Requires SIZE support at server
+ side, to find the restart offset and verify the result */
+func (c *Client) ResumeUpload(localPath string, remoteName string) (ok bool, err error) {
+	return c.upload(localPath, remoteName, true)
+}
+
+/* Secures the control connection using AUTH TLS (explicit FTPS). Data connections are protected once logged in */
+func (c *Client) Secure(config *tls.Config) (ok bool, err error) {
+	if c.settings.Get(OPT_Disconnected).Is(true) {
+		return false, ERR_Disconnected
+	}
+
+	if c.requester.IsSecure() {
+		/* Already secured (implicit FTPS or a previous AUTH TLS) */
+		c.settings.Get(OPT_Secure).Set(true)
+		return true, nil
+	}
+
+	c.requester.SetTLSConfig(config)
+
+	if ok, err = c.Commands.AUTH(ClientCommands.AUTH_TLS); ok {
+		c.settings.Get(OPT_Secure).Set(true)
+
+		if c.settings.Get(OPT_LoggedIn).Is(true) {
+			ok, err = c.protectData()
+		}
+	}
+
+	return
+}
+
+/* Records the session transcript from now on (the server greeting included) with the specified recorder, nil to stop
+ recording */
+func (c *Client) SetRecorder(recorder *Transcript.Recorder) {
+	c.requester.SetRecorder(recorder)
+}
+
+/* Advertise the specified IP address for active mode data connections instead of the local address (clients behind NAT) */
+func (c *Client) SetActiveAdvertisedAddr(ip string) error {
+	return c.requester.SetAdvertisedAddr(ip)
+}
+
+/* Restricts the local ports used for active mode data connections to the specified (inclusive) range */
+func (c *Client) SetActivePortRange(min int, max int) error {
+	return c.requester.SetActivePortRange(min, max)
 }
 
 /* Makes the client ignore existing files with the same name */
@@ -808,6 +1028,43 @@ func (c *Client) SetDownloadRuleCreateCopy() {
 	c.settings.Get(OPT_DownloadOverlap).Set(DO_CreateNew)
 }
 
+/* Makes the client resume the download of existing partial files (restarts transfers from the local file size) */
+func (c *Client) SetDownloadRuleResume() {
+	c.settings.Get(OPT_DownloadOverlap).Set(DO_Resume)
+}
+
+/* Makes the recursive operations follow symbolic links: linked directories are traversed (cycles are skipped, trees
+ deeper than MaxTraversalDepth fail with ERR_MaxDepth), and links to files are downloaded or removed as files */
+func (c *Client) SetLinkRuleFollow() {
+	c.settings.Get(OPT_LinkPolicy).Set(LP_Follow)
+}
+
+/* Makes the recursive operations ignore symbolic links */
+func (c *Client) SetLinkRuleSkip() {
+	c.settings.Get(OPT_LinkPolicy).Reset()
+}
+
+/* Makes downloads recreate symbolic links locally (pointing to the listed target), and removals delete the links
+ themselves */
+func (c *Client) SetLinkRuleRecreate() {
+	c.settings.Get(OPT_LinkPolicy).Set(LP_Recreate)
+}
+
+/* Makes the client overwrite existing remote files with the same name */
+func (c *Client) SetUploadRuleOverwrite() {
+	c.settings.Get(OPT_UploadOverlap).Reset()
+}
+
+/* Makes the client ignore existing remote files with the same name */
+func (c *Client) SetUploadRuleIgnore() {
+	c.settings.Get(OPT_UploadOverlap).Set(UO_IgnoreExisting)
+}
+
+/* Makes the client store a copy under a server generated unique name (STOU) for existing remote files */
+func (c *Client) SetUploadRuleCreateUnique() {
+	c.settings.Get(OPT_UploadOverlap).Set(UO_CreateUnique)
+}
+
 /* Gets the system type */
 func (c *Client) System() (sys string, err error) {
 	/* Check connection ready state before executing command */
@@ -846,19 +1103,71 @@ func (c *Client) TransferMode(mode string) (ok bool, err error) {
 	return ok, err
 }
 
+/* Upload the specified local file. The remote name defaults to the local file name */
+func (c *Client) Upload(localPath string, remoteName string) (ok bool, err error) {
+	return c.upload(localPath, remoteName, false)
+}
+
+/* Upload the specified local file, giving up once the context is done (transfers in progress are aborted) */
+func (c *Client) UploadContext(ctx context.Context, localPath string, remoteName string) (bool, error) {
+	defer c.requester.BindContext(ctx)()
+	return c.Upload(localPath, remoteName)
+}
+
+/* Recursively upload the specified local directory. The remote directory defaults to the local directory name */
+func (c *Client) UploadDir(localDir string, remoteDir string) (ok bool, err error) {
+	var originalLocalDir, originalPath string
+
+	/* Check connection ready state before executing command */
+	if ok, err = c.isReady(); !ok {
+		return
+	}
+
+	if !FilePath.IsAbs(localDir) {
+		localDir = FilePath.Join(c.localFM.CurrentDir(), localDir)
+	}
+
+	if remoteDir == EmptyString {
+		remoteDir = FilePath.Base(localDir)
+	}
+
+	/* Remember the local and remote paths */
+	originalLocalDir = c.localFM.CurrentDir()
+	originalPath = c.CurrentDir()
+
+	/* Each transfer establishes it's own data connection mode (passive mode addresses are only valid once) */
+	ok, err = c.uploadDir(localDir, c.path.ToCurrentDir(remoteDir))
+
+	/* Restore the original paths */
+	c.changeLocalDir(originalLocalDir)
+	c.ChangeDir(originalPath)
+
+	return
+}
+
+/* Makes the client prefer active mode data connections over passive mode ones */
+func (c *Client) UseActiveMode(active bool) {
+	c.settings.Get(OPT_ActiveMode).Set(active)
+}
+
 /* Instantiate a new client */
-func newClient(address string, ipFamily int) (client *Client, err error) {
+func newClient(address string, ipFamily int, config *tls.Config, timeouts ...Timeouts) (client *Client, err error) {
 	var commands *ClientCommands.Commands
-	var credentials *Credentials.Credentials
 	var requester *Requester.Requester
-	var pathManager *PathManager.PathManager
 
-	/* Create a new client instance based on specified IP version */
-	if ipFamily != Address.IPvAny {
+	/* Create a new client instance based on specified IP version and TLS configuration */
+	if config != nil {
+		if requester, err = Requester.NewRequesterTLS(address, ipFamily, config, timeouts...); err != nil {
+			return
+		}
+
+		commands = ClientCommands.NewCommands()
+		_, err = commands.AttachRequester(requester)
+	} else if ipFamily != Address.IPvAny {
 		if ipFamily == Address.IPv4 {
-			requester, err = Requester.NewRequesterIPv4(address)
+			requester, err = Requester.NewRequesterIPv4(address, timeouts...)
 		} else {
-			requester, err = Requester.NewRequesterIPv6(address)
+			requester, err = Requester.NewRequesterIPv6(address, timeouts...)
 		}
 
 		if err != nil {
@@ -868,7 +1177,7 @@ func newClient(address string, ipFamily int) (client *Client, err error) {
 		commands = ClientCommands.NewCommands()
 		_, err = commands.AttachRequester(requester)
 	} else {
-		commands, err = ClientCommands.NewCommandsProvider(address)
+		commands, err = ClientCommands.NewCommandsProvider(address, timeouts...)
 		if err != nil {
 			return
 		}
@@ -880,37 +1189,51 @@ func newClient(address string, ipFamily int) (client *Client, err error) {
 	}
 
 	if nil == err {
-		credentials = requester.GetCredentials()
+		client, err = fromRequester(commands, requester)
+	}
 
-		/* Force the PathManager to use UNIX like path separators. */
-		if pathManager, err = PathManager.NewUnixPathManagerAt(RootDir); err != nil {
-			return
-		}
+	return
+}
+
+/* Instantiates a new client over the specified connected commands provider */
+func fromRequester(commands *ClientCommands.Commands, requester *Requester.Requester) (client *Client, err error) {
+	var credentials *Credentials.Credentials
+	var pathManager *PathManager.PathManager
 
-		client = &Client{commands, requester, credentials, pathManager, Settings.NewSettings(
-			Settings.NewOption(OPT_DebugMode, true),
-			Settings.NewOption(OPT_LoggedIn, false),
-			Settings.NewOption(OPT_PassiveMode, false),
-			Settings.NewOption(OPT_ExtendedPassive, false),
-			Settings.NewOption(OPT_Account, EmptyString),
-			Settings.NewOption(OPT_AccountEnabled, false),
-			Settings.NewOption(OPT_System, EmptyString),
-			Settings.NewOption(OPT_TransferMode, ClientCommands.TRANSFER_Unspecified),
-			Settings.NewOption(OPT_DataType, ClientCommands.TYPE_Unspecified),
-			Settings.NewOption(OPT_FormatControl, ClientCommands.FMTCTRL_Unspecified),
-			Settings.NewOption(OPT_ByteSize, 8),
-			Settings.NewOption(OPT_FileStructure, ClientCommands.FILESTRUCT_Unspecified),
-			Settings.NewOption(OPT_DownloadOverlap, DO_IgnoreExisting),
-			Settings.NewOption(OPT_Disconnected, false),
-		), nil, nil, nil}
+	credentials = requester.GetCredentials()
 
-		/* Initialize a local file manager based on the client current working dir (localy) */
-		client.localFM, err = FileManager.NewFileManager()
+	/* Force the PathManager to use UNIX like path separators. */
+	if pathManager, err = PathManager.NewUnixPathManagerAt(RootDir); err != nil {
+		return
+	}
 
-		/* Enable debugging */
-		if client.settings.Get(OPT_DebugMode).Is(true) {
-			requester.Logger = Logger.NewSimpleLogger()
-		}
+	client = &Client{commands, requester, credentials, pathManager, Settings.NewSettings(
+		Settings.NewOption(OPT_DebugMode, true),
+		Settings.NewOption(OPT_LoggedIn, false),
+		Settings.NewOption(OPT_PassiveMode, false),
+		Settings.NewOption(OPT_ExtendedPassive, false),
+		Settings.NewOption(OPT_ActiveMode, false),
+		Settings.NewOption(OPT_Secure, false),
+		Settings.NewOption(OPT_Account, EmptyString),
+		Settings.NewOption(OPT_AccountEnabled, false),
+		Settings.NewOption(OPT_System, EmptyString),
+		Settings.NewOption(OPT_TransferMode, ClientCommands.TRANSFER_Unspecified),
+		Settings.NewOption(OPT_DataType, ClientCommands.TYPE_Unspecified),
+		Settings.NewOption(OPT_FormatControl, ClientCommands.FMTCTRL_Unspecified),
+		Settings.NewOption(OPT_ByteSize, 8),
+		Settings.NewOption(OPT_FileStructure, ClientCommands.FILESTRUCT_Unspecified),
+		Settings.NewOption(OPT_DownloadOverlap, DO_IgnoreExisting),
+		Settings.NewOption(OPT_UploadOverlap, UO_OverWrite),
+		Settings.NewOption(OPT_LinkPolicy, LP_Skip),
+		Settings.NewOption(OPT_Disconnected, false),
+	), nil, nil, nil, false}
+
+	/* Initialize a local file manager based on the client current working dir (localy) */
+	client.localFM, err = FileManager.NewFileManager()
+
+	/* Enable debugging */
+	if client.settings.Get(OPT_DebugMode).Is(true) {
+		requester.Logger = Logger.NewSimpleLogger()
 	}
 
 	return
@@ -965,122 +1288,373 @@ func (c *Client) account(accountInfo string, afterREIN bool, fromLogIn bool) (ok
 	return
 }
 
-/* Delete file */
-func (c *Client) deleteFile(res *Resources.Resource) (ok bool, err error) {
-	if res.IsFile() {
-		if !res.CanBeRemoved() {
-			return false, ERR_DeleteRights
-		}
+/* Opens a local data listener and advertises it to the server using PORT or EPRT */
+func (c *Client) activeMode(eprt bool) (ok bool, err error) {
+	if eprt {
+		ok, err = c.Commands.EPRT(0)
+	} else {
+		ok, err = c.Commands.PORT(0)
+	}
 
-		ok, err = c.Commands.DELE(res.Name)
+	if ok {
+		c.settings.Get(OPT_ExtendedPassive).Reset()
+		c.settings.Get(OPT_PassiveMode).Reset()
 	}
 
 	return
 }
 
-/* Download a directory at a time */
-func (c *Client) downloadDir(currentDir string, changePath bool) (ok bool, err error) {
-	if currentDir != RootDir {
-		if !c.localFM.ContainsDir(currentDir) {
-			dirs := c.path.SplitDirList(currentDir)
+/* Changes the local current directory. Relative paths are resolved against the local current directory */
+func (c *Client) changeLocalDir(dir string) (bool, error) {
+	if !FilePath.IsAbs(dir) {
+		dir = FilePath.Join(c.localFM.CurrentDir(), dir)
+	}
 
-			for _, d := range dirs {
-				if d == EmptyString {
-					continue
-				}
+	return c.localFM.ChangeDir(FilePath.Clean(dir) + string(FilePath.Separator))
+}
 
-				/* Recreate the entire path to the current directory */
-				if ok, err = c.localFM.MakeDir(d); !ok {
-					err = fmt.Errorf("Download error: Unable to create local directory %s. Original error: %s", d, err)
-					return
-				} else {
-					if ok, err = c.localFM.ChangeDir("./" + d); !ok {
-						err = fmt.Errorf("Download error: Unable to change the current path to the newly created directory %s. Original Error: %s.", d, err)
-					}
-				}
-			}
-		}
+/* Quits, closing the control connection */
+func (c *Client) close() {
+	c.Quit()
+	c.requester.Close()
+}
+
+/* Connects, authenticates and initializes a new client, securing it's connections if a TLS configuration is available */
+func connect(address string, config *tls.Config, recorder *Transcript.Recorder, timeouts ...Timeouts) (client *Client, err error) {
+	var dir, system string
+
+	client, err = newClient(address, Address.IPvAny, config, timeouts...)
+
+	if err != nil {
+		return
 	}
 
-	/* Change the remote host directory */
-	if changePath {
-		fmt.Println("Change to specified directory.")
-		if ok, err = c.ChangeDir(currentDir); !ok {
+	if recorder != nil {
+		client.requester.SetRecorder(recorder)
+	}
+
+	/* Secure the control connection before sending any credentials (implicit FTPS connections already are) */
+	if config != nil || client.requester.IsSecure() {
+		if _, err = client.Secure(config); err != nil {
 			return
 		}
 	}
 
+	/* Authenticate with USER and PASS */
+	_, err = client.LogIn(client.credentials)
+
+	if err != nil {
+		return
+	}
+
+	/* Get system type */
+	if system, err = client.System(); err == nil {
+		client.settings.Add(OPT_System, system)
+	}
+
+	/* Grab supported features list */
+	if _, err = client.Features(); err != nil {
+		return
+	}
+
+	/* Ask for all the available MLSx facts (servers only send some of them by default) */
+	client.requestAllFacts()
+
+	/* Get the current directory */
+	dir, err = client.Commands.PWD()
 	if err == nil {
-		for _, f := range c.Resources.Content {
-			if nil == f || !f.IsChild() {
-				continue
-			}
+		client.path.ChangeCurrentDir(dir)
+	}
 
-			if f.IsDir() {
-				ok, err = c.downloadDir(f.Name, true)
-			} else {
-				/* File */
-				ok, err = c.downloadFile(f.Name)
-			}
+	/* Check for initial path, and navigate there if available */
+	dir, _ = client.requester.GetInitialPath()
 
-			if !ok {
-				err = fmt.Errorf("Download error: Unable to download remote resource %s. Original error: %s", f.Name, err)
+	/* Enforce transfer parameters defaults (as specified by RFC959) */
+	client.RepresentationType(ClientCommands.TYPE_Ascii, ClientCommands.FMTCTRL_NonPrint)
+	client.TransferMode(ClientCommands.TRANSFER_Stream)
+	client.FileStructure(ClientCommands.FILESTRUCT_File)
+
+	if dir != EmptyString {
+		_, err = client.ChangeDir(dir)
+	} else {
+		_, err = client.List()
+	}
+
+	return
+}
+
+/* Requests all the facts advertised by the server's MLST feature in MLST and MLSD replies (OPTS MLST, RFC3659 7.9) */
+func (c *Client) requestAllFacts() (ok bool, err error) {
+	facts := c.features.GetMLSTFacts()
+
+	if len(facts) > 0 && c.features.Supports("MLST") {
+		ok, err = c.Commands.OPTS("MLST", strings.Join(facts, ";") + ";")
+	}
+
+	return
+}
+
+/* Establishes the data connection mode for the following transfers: active mode when preferred, or when passive mode fails */
+func (c *Client) dataMode() (ok bool, err error) {
+	if c.settings.Get(OPT_ActiveMode).Is(false) {
+		if ok, err = c.PassiveMode(); ok {
+			return
+		}
+
+		/* Some servers advertise EPSV without allowing it, fallback on PASV */
+		if c.IsIPv4() && c.features.Supports("EPSV") {
+			if ok, err = c.passiveMode(false); ok {
 				return
 			}
 		}
 	}
 
+	return c.ActiveMode()
+}
+
+/* Delete file */
+func (c *Client) deleteFile(res *Resources.Resource) (ok bool, err error) {
+	if res.IsFile() || res.IsLink() {
+		if !permitted(res, res.CanBeRemoved) {
+			return false, ERR_DeleteRights
+		}
+
+		ok, err = c.Commands.DELE(res.Name)
+	}
+
+	return
+}
+
+/* Deletes the content of the specified remote directory depth first, then the directory itself (as long as it was
+ emptied). The failures are appended to the specified ones */
+func (c *Client) deleteTree(dir *Resources.Resource, path string, failures []error) []error {
+	var initialFailures int = len(failures)
+
+	listing, err := c.list(path, false)
+	if err != nil {
+		return append(failures, fmt.Errorf(ERRF_RemoveFailed, path, err))
+	}
+
+	if !permitted(listing, listing.CanBePurged) {
+		return append(failures, fmt.Errorf(ERRF_RemoveFailed, path, ERR_TruncateRights))
+	}
+
+	for _, r := range listing.Content {
+		if nil == r || !r.IsChild() {
+			continue
+		}
+
+		if r.IsDir() {
+			failures = c.deleteTree(r, Path.Join(path, r.Name), failures)
+		} else {
+			failures = c.removeFile(r, Path.Join(path, r.Name), failures)
+		}
+	}
+
+	if len(failures) > initialFailures {
+		/* Not emptied */
+		return failures
+	}
+
+	if !permitted(dir, dir.CanBeRemoved) {
+		return append(failures, fmt.Errorf(ERRF_RemoveFailed, path, ERR_DeleteRights))
+	}
+
+	if ok, err := c.Commands.RMD(path); !ok {
+		failures = append(failures, removeError(path, err))
+	}
+
+	return failures
+}
+
+/* Download a directory at a time */
+func (c *Client) downloadDir(currentDir string, changePath bool) (ok bool, err error) {
+	if currentDir != RootDir {
+		if !c.localFM.ContainsDir(currentDir) {
+			dirs := c.path.SplitDirList(currentDir)
+
+			for _, d := range dirs {
+				if d == EmptyString {
+					continue
+				}
+
+				/* Recreate the entire path to the current directory */
+				if ok, err = c.localFM.MakeDir(d); !ok {
+					err = fmt.Errorf("Download error: Unable to create local directory %s. Original error: %s", d, err)
+					return
+				} else {
+					if ok, err = c.localFM.ChangeDir("./" + d); !ok {
+						err = fmt.Errorf("Download error: Unable to change the current path to the newly created directory %s. Original Error: %s.", d, err)
+					}
+				}
+			}
+		}
+	}
+
+	/* Change the remote host directory */
+	if changePath {
+		fmt.Println("Change to specified directory.")
+		if ok, err = c.ChangeDir(currentDir); !ok {
+			return
+		}
+	}
+
+	if err == nil {
+		ok, err = c.downloadContent(newTraversal(c.path.GetCurrentDir()))
+	}
+
+	return
+}
+
+/* Downloads the content of the current remote directory in the current local directory */
+func (c *Client) downloadContent(t *traversal) (ok bool, err error) {
+	ok = true
+
+	for _, f := range c.Resources.Content {
+		if nil == f || !f.IsChild() {
+			continue
+		}
+
+		if f.IsLink() {
+			ok, err = c.downloadLink(f, t)
+		} else if f.IsDir() {
+			ok, err = c.downloadSubDir(f, t)
+		} else {
+			/* File */
+			ok, err = c.downloadFile(f.Name)
+		}
+
+		if !ok {
+			err = fmt.Errorf("Download error: Unable to download remote resource %s. Original error: %w", f.Name, err)
+			return
+		}
+	}
+
+	return
+}
+
+/* Downloads the specified symbolic link of the current remote directory, as required by the links policy */
+func (c *Client) downloadLink(link *Resources.Resource, t *traversal) (ok bool, err error) {
+	policy := c.settings.Get(OPT_LinkPolicy)
+
+	if policy.Is(LP_Recreate) {
+		if link.Target == EmptyString {
+			return false, ERR_UnknownLinkTarget
+		}
+
+		return c.localFM.MakeSymlink(link.Name, link.Target)
+	} else if policy.Is(LP_Follow) {
+		if t.isCycle(link) {
+			/* Already being downloaded */
+			return true, nil
+		}
+
+		if c.isRemoteDir(link.Name) {
+			return c.downloadSubDir(link, t)
+		}
+
+		return c.downloadFile(link.Name)
+	}
+
+	return true, nil
+}
+
+/* Downloads the specified subdirectory (or link to a directory) of the current remote directory in a local directory
+ with the same name. The current remote and local directories are restored afterwards */
+func (c *Client) downloadSubDir(dir *Resources.Resource, t *traversal) (ok bool, err error) {
+	var remoteDir, localDir string = c.path.GetCurrentDir(), c.localFM.CurrentDir()
+
+	if ok, err = t.enter(dir); !ok {
+		/* Cycle (the directory is already being downloaded), or too deep */
+		return err == nil, err
+	}
+	defer t.leave()
+
+	if !c.localFM.ContainsDir(dir.Name) {
+		if ok, err = c.localFM.MakeDir(dir.Name); !ok {
+			return false, fmt.Errorf("Download error: Unable to create local directory %s. Original error: %w", dir.Name, err)
+		}
+	}
+
+	if ok, err = c.localFM.ChangeDir("./" + dir.Name); !ok {
+		return
+	}
+
+	if ok, err = c.ChangeDir(dir.Name); ok {
+		ok, err = c.downloadContent(t)
+	}
+
+	/* Restore the parent directories */
+	c.localFM.ChangeDir(localDir)
+	if restored, e := c.ChangeDir(remoteDir); ok && !restored {
+		ok, err = restored, e
+	}
+
 	return
 }
 
 /* Downloads the specified file */
 func (c *Client) downloadFile(file string) (ok bool, err error) {
+	var offset int64
 	downloadBehaviour := c.settings.Get(OPT_DownloadOverlap)
 	r := c.Resources.GetContentByName(file)
+	size := int64(r.Size)
 
 	/* Download the specified file */
-	if r.CanBeRetrieved() {
+	if !r.IsDir() && permitted(r, r.CanBeRetrieved) {
+		/* Establish a new data connection mode before selecting the local resource (REST has to precede RETR directly,
+		 and the passive mode address of the container listing is no longer valid) */
+		if _, err = c.dataMode(); err != nil {
+			c.RestoreConnections()
+			return false, fmt.Errorf("Download error: Unable to establish a data connection for %s. Original error: %w", r.Name, err)
+		}
+		defer c.RestoreConnections();
+
+		/* Set representation type */
+		if r.IsBinary() {
+			c.RepresentationType(ClientCommands.TYPE_Image, nil)
+		} else {
+			c.RepresentationType(ClientCommands.TYPE_Ascii, ClientCommands.FMTCTRL_NonPrint)
+		}
+
 		if downloadBehaviour.Is(DO_CreateNew) {
 			_, err = c.localFM.SelectForWriteNew(file)
 		} else if downloadBehaviour.Is(DO_OverWrite) {
 			_, err = c.localFM.SelectForWriteTruncate(file)
+		} else if downloadBehaviour.Is(DO_Resume) {
+			if offset, size, err = c.selectForResume(r); offset < 0 {
+				/* The local copy is already complete */
+				return err == nil, err
+			}
+		} else if !c.localFM.ContainsFile(file) {
+			_, err = c.localFM.SelectForWrite(file)
 		} else {
 			/* Ignore the current file */
 			return true, err
 		}
 
-		fmt.Println("Resource selection result", err, downloadBehaviour.Value())
-
 		if err != nil {
 			/* Unable to select the local resource */
 			err = fmt.Errorf("Download error: Unable to select local resource %s", r.Name)
 			return false, err
 		}
 
-		fmt.Println("Resource selected")
-
-		/* Put client in passive mode just before downloading */
-		if !c.InPassiveMode() {
-			_, err = c.PassiveMode()
-			defer c.RestoreConnections();
-		}
-
-		fmt.Println("Pasive mode ok")
-
-		/* Set representation type */
-		if r.IsBinary() {
-			c.RepresentationType(ClientCommands.TYPE_Image, nil)
-			fmt.Println("Representation type selected", ClientCommands.TYPE_Image)
-		} else {
-			c.RepresentationType(ClientCommands.TYPE_Ascii, ClientCommands.FMTCTRL_NonPrint)
-			fmt.Println("Representation type selected", ClientCommands.TYPE_Ascii)
-		}
+		/* Only download files with a size greater then 0 (or an unknown size) */
+		if size != 0 {
+			if offset > 0 {
+				if _, err = c.Commands.REST(strconv.FormatInt(offset, 10)); err != nil {
+					/* Restart the transfer from the beginning */
+					_, err = c.localFM.SelectForWriteTruncate(file)
+				}
+			}
 
-		/* Only download files with a size greater then 0 */
-		if fileRes := c.Resources.GetContentByName(file); fileRes.SizeInkB() > 0 {
-			fmt.Println("File resource size: ", fileRes.Size, fileRes, file, "in kb", fileRes.SizeInkB(), "in mb", fileRes.SizeInMB())
-			if _, err = c.Commands.RETR(file, c.localFM.GetSelection()); err != nil {
-				err = fmt.Errorf("Download error: Unable to RETR file %s. Original error: %s", r.Name, err)
+			if err != nil {
+				c.localFM.SelectionClear()
+				err = fmt.Errorf("Download error: Unable to select local resource %s", r.Name)
+				return
+			} else if _, err = c.Commands.RETR(file, c.localFM.GetSelection()); err != nil {
+				c.localFM.SelectionClear()
+				err = fmt.Errorf("Download error: Unable to RETR file %s. Original error: %w", r.Name, err)
 				return
 			}
 		}
@@ -1100,8 +1674,30 @@ func (c *Client) downloadFile(file string) (ok bool, err error) {
 	return
 }
 
+/* Checks if the specified resource of the current remote directory can be navigated to (directories, and links to
+ directories) */
+func (c *Client) isRemoteDir(name string) bool {
+	var originalPath string = c.path.GetCurrentDir()
+
+	if ok, _ := c.ChangeDir(name); !ok {
+		return false
+	}
+
+	c.ChangeDir(originalPath)
+	return true
+}
+
+/* Checks if any of the data connection modes is established */
+func (c *Client) inDataMode() bool {
+	return c.InPassiveMode() || c.InActiveMode()
+}
+
 /* Checks if the connection is ready to execute commands */
 func (c *Client) isReady() (ok bool, err error) {
+	if c.streaming {
+		return false, ERR_TransferInProgress
+	}
+
 	if c.settings.Get(OPT_LoggedIn).Is(true) {
 		if c.settings.Get(OPT_Disconnected).Is(false) {
 			return true, nil
@@ -1120,8 +1716,8 @@ func (c *Client) list(path string, isFile bool) (res *Resources.Resource, err er
 		return
 	}
 
-	if !c.InPassiveMode() {
-		_, err = c.PassiveMode()
+	if !c.inDataMode() {
+		_, err = c.dataMode()
 		defer c.RestoreConnections();
 	}
 
@@ -1132,12 +1728,14 @@ func (c *Client) list(path string, isFile bool) (res *Resources.Resource, err er
 				res, err = c.Commands.MLSD(path)
 
 				if !c.Commands.LastIsImplemented() {
-					/* MLSD not supported, remove the feature from expected support and fallback on LIST */
+					/* MLSD not supported, remove the feature from expected support and fallback on LIST (over a new data
+					 connection, the passive mode address is single use) */
 					c.features.RemoveFeature("MLSD")
+					c.RestoreConnections()
 					return c.list(path, isFile)
 				}
 			} else if c.features.Supports("LIST") {
-				res, err = c.Commands.LIST(path)
+				res, err = c.Commands.LISTForSystem(path, c.settings.Get(OPT_System).ToString())
 			}
 		} else {
 			/* Single resource listing */
@@ -1145,12 +1743,14 @@ func (c *Client) list(path string, isFile bool) (res *Resources.Resource, err er
 				res, err = c.Commands.MLST(path)
 
 				if !c.Commands.LastIsImplemented() {
-					/* MLST not supported, remove the feature from expected support and fallback on LIST */
+					/* MLST not supported, remove the feature from expected support and fallback on LIST (over a new data
+					 connection) */
 					c.features.RemoveFeature("MLST")
+					c.RestoreConnections()
 					return c.list(path, isFile)
 				}
 			} else if c.features.Supports("LIST") {
-				res, err = c.Commands.LIST(path)
+				res, err = c.Commands.LISTForSystem(path, c.settings.Get(OPT_System).ToString())
 			}
 		}
 	}
@@ -1163,6 +1763,20 @@ func (c *Client) list(path string, isFile bool) (res *Resources.Resource, err er
 	return
 }
 
+/* Removes the specified remote file (symbolic links and other resources included). The failure is appended to the
+ specified ones */
+func (c *Client) removeFile(res *Resources.Resource, path string, failures []error) []error {
+	if !permitted(res, res.CanBeRemoved) {
+		return append(failures, fmt.Errorf(ERRF_RemoveFailed, path, ERR_DeleteRights))
+	}
+
+	if ok, err := c.Commands.DELE(path); !ok {
+		failures = append(failures, removeError(path, err))
+	}
+
+	return failures
+}
+
 /* Activates the passive mode if possible, and marks the internal options */
 func (c *Client) passiveMode(epsv bool) (ok bool, err error) {
 	if epsv {
@@ -1184,29 +1798,178 @@ func (c *Client) passiveMode(epsv bool) (ok bool, err error) {
 	return
 }
 
-/* Delete each file in the directory */
-func (c *Client) truncateDir(res *Resources.Resource) (ok bool, err error) {
-	var originalPath string = c.path.GetCurrentDir()
+/* Protects the data connections of a secure control connection (PBSZ 0, PROT P) */
+func (c *Client) protectData() (ok bool, err error) {
+	if ok, err = c.Commands.PBSZ(0); ok {
+		ok, err = c.Commands.PROT(ClientCommands.PROT_Private)
+	}
+
+	return
+}
+
+/* Checks if the specified remote file exists, using the current listing when possible */
+func (c *Client) remoteFileExists(remotePath string) bool {
+	dir, file := c.path.Split(remotePath)
+
+	if c.Resources != nil && strings.TrimRight(dir, RootDir) == strings.TrimRight(c.CurrentDir(), RootDir) {
+		return c.Resources.ContainsByName(file)
+	}
+
+	if c.features.Supports("SIZE") {
+		size, err := c.Commands.SIZE(remotePath)
+		return err == nil && size > -1
+	}
+
+	return false
+}
+
+/* Gets the size of the specified remote file */
+func (c *Client) remoteFileSize(remotePath string) (int64, error) {
+	if !c.features.Supports("SIZE") {
+		return -1, ERR_SIZENotImplemented
+	}
+
+	size, err := c.Commands.SIZE(remotePath)
+	if err != nil {
+		return -1, err
+	}
+
+	return int64(size), nil
+}
+
+/* Gets the current representation type and it's parameter. The image type is reported for binary and unknown types */
+func (c *Client) representationType() (string, interface {}) {
+	if c.settings.Get(OPT_DataType).Is(ClientCommands.TYPE_Ascii) {
+		return ClientCommands.TYPE_Ascii, c.settings.Get(OPT_FormatControl).ToString()
+	}
+
+	return ClientCommands.TYPE_Image, nil
+}
+
+/* Resumes the upload of the specified file from the local current directory, starting at the remote file size. The
+ remote size is required for both the restart offset and the verification of the result, servers without SIZE support
+ are refused before any transfer */
+func (c *Client) resumeUploadFile(file string, remotePath string) (ok bool, err error) {
+	var f *os.File
+	var offset, size int64
+
+	if !c.features.Supports("SIZE") {
+		return false, ERR_SIZENotImplemented
+	}
+
+	/* Select the local file for reading */
+	if f, err = c.localFM.SelectForRead(file); err != nil || f == nil {
+		return false, fmt.Errorf("Upload error: Unable to select local resource %s", file)
+	}
+
+	defer c.localFM.SelectionClear()
+	localSize := c.localFM.FileSize(file)
+
+	/* Establish the data connection mode just before uploading */
+	if !c.inDataMode() {
+		if _, err = c.dataMode(); err != nil {
+			return false, fmt.Errorf("Upload error: Unable to establish a data connection for %s. Original error: %s", file, err)
+		}
 
+		defer c.RestoreConnections();
+	}
+
+	/* Transfer offsets are only meaningful for binary transfers */
+	if representation, parameter := c.representationType(); representation != ClientCommands.TYPE_Image {
+		defer c.RepresentationType(representation, parameter)
+	}
+
+	c.RepresentationType(ClientCommands.TYPE_Image, nil)
+
+	/* Missing or different (larger) remote files are uploaded from the beginning */
+	if offset, err = c.remoteFileSize(remotePath); err != nil || offset < 0 || offset > localSize {
+		offset = 0
+	}
+
+	if offset < localSize {
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			return false, fmt.Errorf("Upload error: Unable to seek local resource %s. Original error: %s", file, err)
+		}
+
+		if offset == 0 {
+			_, err = c.Commands.STOR(remotePath, f)
+		} else if c.features.SupportsParameter("REST", "STREAM") {
+			if _, err = c.Commands.REST(strconv.FormatInt(offset, 10)); err == nil {
+				_, err = c.Commands.STOR(remotePath, f)
+			}
+		} else {
+			/* No restart support, append the remaining content instead */
+			_, err = c.Commands.APPE(remotePath, f)
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("Upload error: Unable to store %s. Original error: %s", file, err)
+		}
+	}
+
+	/* Verify the transfer result */
+	if size, err = c.remoteFileSize(remotePath); err != nil {
+		return false, fmt.Errorf("Upload error: Unable to verify the remote size of %s. Original error: %s", remotePath, err)
+	} else if size != localSize {
+		return false, &SizeMismatchError{Path: remotePath, LocalSize: localSize, RemoteSize: size}
+	}
+
+	return true, nil
+}
+
+/* Selects the local copy of the specified resource for a resumed download. Returns the offset the transfer restarts from
+ (-1 for complete local copies), and the remote size (-1 when unknown). The restart offset itself (REST) is left to the
+ caller, right before the transfer */
+func (c *Client) selectForResume(r *Resources.Resource) (offset int64, remoteSize int64, err error) {
+	localSize := c.localFM.FileSize(r.Name)
+	remoteSize = int64(r.Size)
+
+	if localSize > 0 && !c.localFM.ContainsFile(r.Name) {
+		/* Created after the local directory was listed, the selection relies on the listing */
+		c.localFM.RefreshList()
+	}
+
+	/* Fallback on the SIZE command for listings without size facts */
+	if remoteSize <= 0 {
+		remoteSize = -1
+
+		if c.features.Supports("SIZE") {
+			if size, e := c.Commands.SIZE(r.Name); e == nil {
+				remoteSize = int64(size)
+			}
+		}
+	}
+
+	if localSize > 0 && localSize == remoteSize {
+		return -1, remoteSize, nil
+	}
+
+	/* ASCII transfers change line endings, only binary transfers can be restarted at the local size */
+	if r.IsBinary() && localSize > 0 && localSize < remoteSize && c.features.SupportsParameter("REST", "STREAM") {
+		if _, err = c.localFM.SelectForAppend(r.Name); err == nil {
+			return localSize, remoteSize, nil
+		}
+	}
+
+	/* Restart the transfer from the beginning */
+	_, err = c.localFM.SelectForWriteTruncate(r.Name)
+	return 0, remoteSize, err
+}
+
+/* Delete each file in the directory */
+func (c *Client) truncateDir(res *Resources.Resource, t *traversal) (ok bool, err error) {
 	/* Check if the current directory can be purged */
-	if !res.CanBePurged() {
+	if !permitted(res, res.CanBePurged) {
 		/* Not all files can be removed from the current dir */
 		return false, ERR_TruncateRights
 	}
 
+	ok = true
 	for _, r := range res.Content {
-		if r.IsDir() {
-			/* Change to the specified path */
-			ok, err = c.ChangeDir(r.Name)
-
-			if ok {
-				ok, err = c.truncateDir(c.Resources)
-
-				if ok {
-					/* Restore to the initial path */
-					ok, err = c.ChangeDir(originalPath)
-				}
-			}
+		if r.IsLink() {
+			ok, err = c.truncateLink(r, t)
+		} else if r.IsDir() {
+			ok, err = c.truncateSubDir(r, t)
 		} else {
 			/* File removal */
 			ok, err = c.deleteFile(r)
@@ -1219,3 +1982,205 @@ func (c *Client) truncateDir(res *Resources.Resource) (ok bool, err error) {
 
 	return
 }
+
+/* Removes the specified symbolic link of the current remote directory, or the content of the linked directory, as
+ required by the links policy */
+func (c *Client) truncateLink(link *Resources.Resource, t *traversal) (ok bool, err error) {
+	policy := c.settings.Get(OPT_LinkPolicy)
+
+	if policy.Is(LP_Recreate) {
+		/* Remove the link itself */
+		return c.deleteFile(link)
+	} else if policy.Is(LP_Follow) {
+		if t.isCycle(link) {
+			/* Already being truncated */
+			return true, nil
+		}
+
+		if c.isRemoteDir(link.Name) {
+			return c.truncateSubDir(link, t)
+		}
+
+		return c.deleteFile(link)
+	}
+
+	return true, nil
+}
+
+/* Deletes each file of the specified subdirectory (or link to a directory) of the current remote directory */
+func (c *Client) truncateSubDir(dir *Resources.Resource, t *traversal) (ok bool, err error) {
+	var originalPath string = c.path.GetCurrentDir()
+
+	if ok, err = t.enter(dir); !ok {
+		/* Cycle (the directory is already being truncated), or too deep */
+		return err == nil, err
+	}
+	defer t.leave()
+
+	/* Change to the specified path */
+	if ok, err = c.ChangeDir(dir.Name); ok {
+		if ok, err = c.truncateDir(c.Resources, t); ok {
+			/* Restore to the initial path */
+			ok, err = c.ChangeDir(originalPath)
+		}
+	}
+
+	return
+}
+
+/* Uploads (or resumes the upload of) the specified local file under the specified remote name */
+func (c *Client) upload(localPath string, remoteName string, resume bool) (ok bool, err error) {
+	/* Check connection ready state before executing command */
+	if ok, err = c.isReady(); !ok {
+		return
+	}
+
+	dir, file := FilePath.Split(localPath)
+	if file == EmptyString {
+		return false, ERR_InvalidLocalFile
+	}
+
+	/* Select the local file's container */
+	if dir != EmptyString {
+		originalDir := c.localFM.CurrentDir()
+		if ok, err = c.changeLocalDir(dir); !ok {
+			return false, fmt.Errorf("Upload error: Unable to change the local directory to %s. Original error: %s", dir, err)
+		}
+
+		defer c.changeLocalDir(originalDir)
+	}
+
+	if remoteName == EmptyString {
+		remoteName = file
+	}
+
+	if resume {
+		return c.resumeUploadFile(file, c.path.ToCurrentDir(remoteName))
+	}
+
+	return c.uploadFile(file, c.path.ToCurrentDir(remoteName))
+}
+
+/* Upload a directory at a time */
+func (c *Client) uploadDir(localDir string, remoteDir string) (ok bool, err error) {
+	var dirs []string
+
+	/* Recreate the remote directory if missing */
+	if ok, err = c.MakeDirectory(remoteDir); !ok {
+		return false, fmt.Errorf("Upload error: Unable to create remote directory %s. Original error: %s", remoteDir, err)
+	}
+
+	if ok, err = c.ChangeDir(remoteDir); !ok {
+		return false, fmt.Errorf("Upload error: Unable to change the remote directory to %s. Original error: %s", remoteDir, err)
+	}
+
+	if ok, err = c.changeLocalDir(localDir); !ok {
+		return false, fmt.Errorf("Upload error: Unable to change the local directory to %s. Original error: %s", localDir, err)
+	}
+
+	/* Upload the files first, the subdirectories will change both current directories */
+	for _, f := range c.localFM.Listing() {
+		if f.IsDir() {
+			dirs = append(dirs, f.Name())
+		} else if f.Mode().IsRegular() {
+			if ok, err = c.uploadFile(f.Name(), c.path.Join(remoteDir, f.Name())); !ok {
+				return
+			}
+		}
+	}
+
+	for _, d := range dirs {
+		if ok, err = c.uploadDir(FilePath.Join(localDir, d), c.path.Join(remoteDir, d)); !ok {
+			return
+		}
+	}
+
+	return true, nil
+}
+
+/* Uploads the specified file from the local current directory */
+func (c *Client) uploadFile(file string, remotePath string) (ok bool, err error) {
+	var f *os.File
+	var unique bool
+	uploadBehaviour := c.settings.Get(OPT_UploadOverlap)
+
+	/* Check the upload overlap rule for existing remote files */
+	if !uploadBehaviour.Is(UO_OverWrite) && c.remoteFileExists(remotePath) {
+		if uploadBehaviour.Is(UO_IgnoreExisting) {
+			/* Ignore the current file */
+			return true, nil
+		}
+
+		unique = true
+	}
+
+	/* Select the local file for reading */
+	if f, err = c.localFM.SelectForRead(file); err != nil || f == nil {
+		return false, fmt.Errorf("Upload error: Unable to select local resource %s", file)
+	}
+
+	if unique {
+		originalPath := c.CurrentDir()
+
+		/* STOU stores the file in the current directory, navigate to the remote container first (it's listing uses a
+		 data connection of it's own). Restored once the data connection settings are */
+		if ok, err = c.ChangeDir(c.path.SplitDir(remotePath)); !ok {
+			c.localFM.SelectionClear()
+			return false, fmt.Errorf("Upload error: Unable to STOU file %s. Original error: %w", file, err)
+		}
+
+		defer c.ChangeDir(originalPath)
+	}
+
+	/* Establish the data connection mode just before uploading */
+	if !c.inDataMode() {
+		if _, err = c.dataMode(); err != nil {
+			c.localFM.SelectionClear()
+			return false, fmt.Errorf("Upload error: Unable to establish a data connection for %s. Original error: %w", file, err)
+		}
+
+		defer c.RestoreConnections();
+	}
+
+	/* Set representation type */
+	if Resources.MIMEFromName(file) == Resources.MIME_Text {
+		c.RepresentationType(ClientCommands.TYPE_Ascii, ClientCommands.FMTCTRL_NonPrint)
+	} else {
+		c.RepresentationType(ClientCommands.TYPE_Image, nil)
+	}
+
+	if unique {
+		if _, err = c.Commands.STOU(f); err != nil {
+			ok, err = false, fmt.Errorf("Upload error: Unable to STOU file %s. Original error: %w", file, err)
+		} else {
+			ok = true
+		}
+	} else if ok, err = c.Commands.STOR(remotePath, f); !ok {
+		err = fmt.Errorf("Upload error: Unable to STOR file %s. Original error: %w", file, err)
+	}
+
+	if e := c.localFM.SelectionClear(); e != nil && err == nil {
+		ok, err = false, fmt.Errorf("Upload error: Unable to close local resource %s", file)
+	}
+
+	return
+}
+
+/* Checks the specified permission of a listed resource. Resources listed without permissions (LIST, or MLSx without
+ the perm fact) are left for the server to check */
+func permitted(res *Resources.Resource, check func() bool) bool {
+	if _, listed := res.Fact("perm"); !listed {
+		return true
+	}
+
+	return check()
+}
+
+/* Builds the failure of a resource removal refused by the server */
+func removeError(path string, err error) error {
+	if err == nil {
+		err = ERR_RemoveRefused
+	}
+
+	return fmt.Errorf(ERRF_RemoveFailed, path, err)
+}
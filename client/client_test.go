@@ -0,0 +1,857 @@
+package client_test
+
+import (
+	Client "github.com/ghepesdoru/bookwormFTP/client"
+	ClientCommands "github.com/ghepesdoru/bookwormFTP/client/commands"
+	TestServer "github.com/ghepesdoru/bookwormFTP/client/testserver"
+	Transcript "github.com/ghepesdoru/bookwormFTP/client/transcript"
+	Access "github.com/ghepesdoru/bookwormFTP/core/access"
+	Credentials "github.com/ghepesdoru/bookwormFTP/core/credentials"
+	Resources "github.com/ghepesdoru/bookwormFTP/core/parsers/resource"
+	Server "github.com/ghepesdoru/bookwormFTP/server"
+	Net "net"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+/* Starts a BookwormFTP server over the specified in memory driver, with a full rights "user" user and a "reader" user
+ only allowed to read and purge directories (but not to delete any resource) */
+func startServer(t *testing.T, driver *Server.MemoryDriver) string {
+	authenticator := Server.NewStaticAuthenticator()
+	credentials, _ := Credentials.NewCredentials("user", "secret")
+	authenticator.AddUser(credentials, nil)
+	credentials, _ = Credentials.NewCredentials("reader", "secret")
+	authenticator.AddUser(credentials, &Server.User{RootDir: "/", Rights: Access.NewAccessRights(append([]Access.Perm{Access.PERM_Purge}, Server.ReadOnlyRights...))})
+
+	server := Server.NewServer("127.0.0.1:0", driver, authenticator)
+	listener, err := Net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Unable to open a loopback listener.", err)
+	}
+
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	return listener.Addr().String()
+}
+
+/* Connects the specified user to the server */
+func connectAs(t *testing.T, username string, addr string) *Client.Client {
+	client, err := Client.NewClient("ftp://" + username + ":secret@" + addr + "/")
+	if err != nil {
+		t.Fatal("Unable to connect to the server.", err)
+	}
+
+	t.Cleanup(func() {
+		client.Quit()
+	})
+
+	return client
+}
+
+/* Connects to a scripted test server advertising the specified features (FEAT lines), with an empty initial directory */
+func scriptedClient(t *testing.T, features ...string) (*Client.Client, *TestServer.Server) {
+	server, err := TestServer.NewServer()
+	if err != nil {
+		t.Fatal("Unable to start the test server.", err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	server.Reply("USER", "331 Password required.")
+	server.Reply("PASS", "230 Logged in.")
+	server.Reply("SYST", "215 UNIX Type: L8")
+	feat := "211-Extensions supported:\r\n"
+	for _, feature := range features {
+		feat += " " + feature + "\r\n"
+	}
+
+	server.Reply("FEAT", feat + "211 End")
+	server.Reply("PWD", "257 \"/\" is the current directory.")
+	server.Reply("TYPE", "200 Type set.")
+	server.Reply("MODE", "200 Mode set.")
+	server.Reply("STRU", "200 Structure set.")
+	server.Transfer("LIST", "150 Here comes the directory listing.", nil, "226 Directory send OK.")
+
+	return connectAs(t, "user", server.Addr()), server
+}
+
+/* Writes the specified local file, failing the test on errors */
+func writeLocal(t *testing.T, path string, content string) string {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal("Unable to write a local file.", err)
+	}
+
+	return path
+}
+
+/* Reads the specified file of the in memory driver (empty for missing files) */
+func readRemote(driver *Server.MemoryDriver, path string) string {
+	file, err := driver.OpenRead(path)
+	if err != nil {
+		return Client.EmptyString
+	}
+	defer file.Close()
+
+	data, _ := io.ReadAll(file)
+	return string(data)
+}
+
+/* Builds an in memory tree */
+func newTree() *Server.MemoryDriver {
+	driver := Server.NewMemoryDriver()
+	driver.MakeDir("/tree")
+	driver.MakeDir("/tree/sub")
+	driver.MakeDir("/tree/sub/deeper")
+	driver.WriteFile("/tree/a.txt", []byte("a"))
+	driver.WriteFile("/tree/sub/b.txt", []byte("b"))
+	driver.WriteFile("/tree/sub/deeper/c.txt", []byte("c"))
+	driver.WriteFile("/keep.txt", []byte("keep"))
+
+	return driver
+}
+
+func TestUploadUnique(t *testing.T) {
+	client, server := scriptedClient(t, "SIZE")
+	local := writeLocal(t, filepath.Join(t.TempDir(), "report.txt"), "report")
+
+	/* The remote file exists, the copies are stored under the server assigned names */
+	client.SetUploadRuleCreateUnique()
+	server.Reply("SIZE", "213 4")
+	server.Transfer("STOU", "150 FILE: report.txt.1", nil, "226 Transfer complete.")
+	server.Transfer("STOU", "150 Ok to send data.", nil, "226 Transfer complete (FILE: report.txt.2).")
+	server.Transfer("STOU", "150 Ok to send data.", nil, "226 Transfer complete.")
+	server.Transfer("STOU", "150 FILE: report.txt.3", nil, "226 Transfer complete.")
+
+	for i := 0; i < 2; i++ {
+		if ok, err := client.Upload(local, "report.txt"); !ok {
+			t.Fatal("Unable to upload a unique copy.", err)
+		}
+	}
+
+	if ok, err := client.Upload(local, "report.txt"); ok || !errors.Is(err, ClientCommands.ERR_NoUniqueName) {
+		t.Fatal("Missing unique name not reported.", err)
+	}
+
+	/* Copies outside of the current directory are stored from their container (listed before the transfer) */
+	server.Reply("CWD", "250 Directory changed.")
+	server.Transfer("MLSD", "150 Here comes the directory listing.", []byte("type=cdir;perm=el; /sub\r\n"), "226 Directory send OK.")
+
+	if ok, err := client.Upload(local, "/sub/report.txt"); !ok {
+		t.Fatal("Unable to upload a unique copy in a subdirectory.", err)
+	}
+
+	if dir := client.CurrentDir(); dir != "/" {
+		t.Fatal("Remote directory not restored.", dir)
+	}
+
+	for _, command := range server.Commands() {
+		if strings.HasPrefix(command, "STOR") {
+			t.Fatal("Existing remote file overwritten.", command)
+		}
+	}
+
+	if uploads := server.Uploads(); len(uploads) != 4 || string(uploads[3]) != "report" {
+		t.Fatal("Invalid uploaded data.", uploads)
+	}
+}
+
+func TestUploadDir(t *testing.T) {
+	driver := newTree()
+	client := connectAs(t, "user", startServer(t, driver))
+
+	local := t.TempDir()
+	os.Mkdir(filepath.Join(local, "sub"), 0755)
+	writeLocal(t, filepath.Join(local, "a.txt"), "new a")
+	writeLocal(t, filepath.Join(local, "sub", "b.txt"), "new b")
+	writeLocal(t, filepath.Join(local, "sub", "d.txt"), "new d")
+
+	/* Existing remote files are kept, the missing ones uploaded */
+	client.SetUploadRuleIgnore()
+	if ok, err := client.UploadDir(local, "/tree"); !ok {
+		t.Fatal("Unable to upload the directory.", err)
+	}
+
+	for path, content := range map[string]string{"/tree/a.txt": "a", "/tree/sub/b.txt": "b", "/tree/sub/d.txt": "new d"} {
+		if data := readRemote(driver, path); data != content {
+			t.Fatal("Invalid remote file after ignoring the existing files.", path, data)
+		}
+	}
+
+	/* Existing remote files are replaced */
+	client.SetUploadRuleOverwrite()
+	if ok, err := client.UploadDir(local, "/tree"); !ok {
+		t.Fatal("Unable to upload the directory.", err)
+	}
+
+	/* Missing remote directories are created */
+	if ok, err := client.UploadDir(local, "/copy/of/tree"); !ok {
+		t.Fatal("Unable to upload the directory in a new remote directory.", err)
+	}
+
+	for path, content := range map[string]string{"/tree/a.txt": "new a", "/tree/sub/b.txt": "new b", "/tree/sub/deeper/c.txt": "c", "/copy/of/tree/sub/d.txt": "new d"} {
+		if data := readRemote(driver, path); data != content {
+			t.Fatal("Invalid remote file after overwriting the existing files.", path, data)
+		}
+	}
+
+	/* Both current directories are restored */
+	if dir := client.CurrentDir(); dir != "/" {
+		t.Fatal("Remote directory not restored.", dir)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+
+	driver := newTree()
+	driver.WriteFile("/tree/large.bin", content)
+
+	local := t.TempDir()
+	t.Chdir(local)
+	client := connectAs(t, "user", startServer(t, driver))
+	client.SetDownloadRuleResume()
+
+	/* Partial local copies are completed from their size (the local part is not downloaded again) */
+	partial := bytes.Repeat([]byte("x"), 300)
+	writeLocal(t, filepath.Join(local, "large.bin"), string(partial))
+
+	if ok, err := client.Download("/tree/large.bin"); !ok {
+		t.Fatal("Unable to resume the download.", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(local, "large.bin")); !bytes.Equal(data, append(partial, content[300:]...)) {
+		t.Fatal("Download not restarted at the local size.", len(data))
+	}
+
+	/* Complete local copies are kept */
+	if ok, err := client.Download("/tree/large.bin"); !ok {
+		t.Fatal("Unable to resume a complete download.", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(local, "large.bin")); !bytes.Equal(data[:300], partial) {
+		t.Fatal("Complete local copy downloaded again.")
+	}
+
+	/* Larger local copies are downloaded again */
+	writeLocal(t, filepath.Join(local, "large.bin"), string(bytes.Repeat([]byte("x"), 1200)))
+	if ok, err := client.Download("/tree/large.bin"); !ok {
+		t.Fatal("Unable to download a different file.", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(local, "large.bin")); !bytes.Equal(data, content) {
+		t.Fatal("Invalid download of a different file.", len(data))
+	}
+
+	/* Text files are not restarted */
+	driver.WriteFile("/tree/a.txt", []byte("text content"))
+	writeLocal(t, filepath.Join(local, "a.txt"), "text")
+
+	if ok, err := client.Download("/tree/a.txt"); !ok {
+		t.Fatal("Unable to resume a text file download.", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(local, "a.txt")); string(data) != "text content" {
+		t.Fatal("Invalid text file download.", string(data))
+	}
+
+	/* Files of unknown size (listed without size facts, without SIZE support) are downloaded again */
+	scripted, server := scriptedClient(t, "MDTM")
+	scripted.SetDownloadRuleResume()
+	server.Transfer("MLSD", "150 Here comes the directory listing.", []byte("type=cdir;modify=20240101000000;perm=el; /\r\ntype=file;modify=20240101000000;perm=r; large.bin\r\n"), "226 Directory send OK.")
+	server.Transfer("RETR", "150 Opening data connection.", []byte("remote content"), "226 Transfer complete.")
+
+	if ok, err := scripted.Download("large.bin"); !ok {
+		t.Fatal("Unable to download a file of unknown size.", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(local, "large.bin")); string(data) != "remote content" {
+		t.Fatal("Invalid download of a file of unknown size.", string(data))
+	}
+
+	for _, command := range server.Commands() {
+		if strings.HasPrefix(command, "REST") {
+			t.Fatal("Restart offset sent for a file of unknown size.", command)
+		}
+	}
+}
+
+func TestDownloadList(t *testing.T) {
+	local := t.TempDir()
+	t.Chdir(local)
+
+	/* Resources listed by LIST carry no permissions, the server decides */
+	client, server := scriptedClient(t, "SIZE")
+	server.Reply("CWD", "250 Directory changed.")
+	server.Transfer("LIST", "150 Here comes the directory listing.", []byte("-rw-r--r-- 1 user group 14 Jan 01 2024 file.bin\r\n"), "226 Directory send OK.")
+	server.Transfer("RETR", "150 Opening data connection.", []byte("remote content"), "226 Transfer complete.")
+
+	/* The initial directory is listed empty */
+	if _, err := client.List(); err != nil {
+		t.Fatal("Unable to list the initial directory.", err)
+	}
+
+	if ok, err := client.Download("/sub/file.bin"); !ok {
+		t.Fatal("Unable to download a file listed by LIST.", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(local, "file.bin")); string(data) != "remote content" {
+		t.Fatal("Invalid download of a file listed by LIST.", string(data))
+	}
+}
+
+func TestResumeUpload(t *testing.T) {
+	driver := newTree()
+	client := connectAs(t, "user", startServer(t, driver))
+	local := writeLocal(t, filepath.Join(t.TempDir(), "large.bin"), "0123456789")
+
+	/* REST STREAM: the remote file is completed from it's size */
+	driver.WriteFile("/tree/large.bin", []byte("0123"))
+	if ok, err := client.ResumeUpload(local, "/tree/large.bin"); !ok {
+		t.Fatal("Unable to resume the upload.", err)
+	}
+
+	if data := readRemote(driver, "/tree/large.bin"); data != "0123456789" {
+		t.Fatal("Invalid resumed upload.", data)
+	}
+
+	/* Missing remote files are uploaded from the beginning */
+	if ok, err := client.ResumeUpload(local, "/tree/new.bin"); !ok {
+		t.Fatal("Unable to upload a missing file.", err)
+	}
+
+	if data := readRemote(driver, "/tree/new.bin"); data != "0123456789" {
+		t.Fatal("Invalid upload of a missing file.", data)
+	}
+
+	/* Without REST STREAM the remaining content is appended */
+	scripted, server := scriptedClient(t, "SIZE")
+	server.Reply("SIZE", "213 4")
+	server.Reply("SIZE", "213 10")
+	server.Transfer("APPE", "150 Ok to send data.", nil, "226 Transfer complete.")
+
+	if ok, err := scripted.ResumeUpload(local, "large.bin"); !ok {
+		t.Fatal("Unable to resume the upload using APPE.", err)
+	}
+
+	if uploads := server.Uploads(); len(uploads) != 1 || string(uploads[0]) != "456789" {
+		t.Fatal("Invalid appended data.", uploads)
+	}
+
+	for _, command := range server.Commands() {
+		if strings.HasPrefix(command, "REST") {
+			t.Fatal("Restart offset sent without REST STREAM support.", command)
+		}
+	}
+
+	/* The remote size is verified */
+	scripted, server = scriptedClient(t, "SIZE", "REST STREAM")
+	server.Reply("SIZE", "213 4")
+	server.Reply("SIZE", "213 7")
+	server.Reply("REST", "350 Restarting at 4.")
+	server.Transfer("STOR", "150 Ok to send data.", nil, "226 Transfer complete.")
+
+	var mismatch *Client.SizeMismatchError
+	if ok, err := scripted.ResumeUpload(local, "large.bin"); ok || !errors.As(err, &mismatch) || mismatch.LocalSize != 10 || mismatch.RemoteSize != 7 {
+		t.Fatal("Remote size mismatch not reported.", err)
+	}
+
+	/* Without SIZE nothing is transferred */
+	unsized, server := scriptedClient(t, "REST STREAM")
+	if ok, err := unsized.ResumeUpload(local, "large.bin"); ok || err != Client.ERR_SIZENotImplemented {
+		t.Fatal("Upload resumed without SIZE support.", err)
+	}
+
+	if uploads := server.Uploads(); len(uploads) != 0 {
+		t.Fatal("Data uploaded without SIZE support.", uploads)
+	}
+}
+
+func TestDeleteTree(t *testing.T) {
+	driver := newTree()
+	client := connectAs(t, "user", startServer(t, driver))
+
+	if ok, err := client.ChangeDir("/tree/sub"); !ok {
+		t.Fatal("Unable to change the current directory.", err)
+	}
+
+	if ok, err := client.DeleteTree("/tree"); !ok {
+		t.Fatal("Unable to delete the tree.", err)
+	}
+
+	if _, err := driver.Stat("/tree"); err == nil {
+		t.Fatal("Tree not removed.")
+	}
+
+	if _, err := driver.Stat("/keep.txt"); err != nil {
+		t.Fatal("Resource outside of the tree removed.", err)
+	}
+
+	/* The removed current directory is replaced by the tree's container */
+	if dir := client.CurrentDir(); dir != "/" {
+		t.Fatal("Invalid current directory after removing it.", dir)
+	}
+
+	if ok, err := client.DeleteTree("missing"); ok || err != Client.ERR_UnableToLocateRes {
+		t.Fatal("Missing resource removed.", err)
+	}
+}
+
+func TestDeleteTreeFailures(t *testing.T) {
+	driver := newTree()
+	client := connectAs(t, "reader", startServer(t, driver))
+
+	ok, err := client.DeleteTree("tree")
+	if ok {
+		t.Fatal("Read only tree removed.")
+	}
+
+	/* Every file is reported, the directories are not emptied */
+	var failures *Client.DeleteTreeError
+	if !errors.As(err, &failures) || len(failures.Failures) != 3 || !errors.Is(err, Client.ERR_DeleteRights) {
+		t.Fatal("Invalid aggregated error.", err)
+	}
+
+	if _, err := driver.Stat("/tree/sub/deeper/c.txt"); err != nil {
+		t.Fatal("Read only file removed.", err)
+	}
+}
+
+func TestDeleteUnlisted(t *testing.T) {
+	/* Resources listed without the perm fact are left for the server to check */
+	client, server := scriptedClient(t, "SIZE")
+	server.Reply("CWD", "250 Directory changed.")
+	server.Transfer("MLSD", "150 Here comes the directory listing.", []byte("type=cdir; /sub\r\ntype=file;size=4; old.txt\r\n"), "226 Directory send OK.")
+	server.Reply("DELE", "250 File removed.")
+	server.Reply("CDUP", "250 Directory changed.")
+
+	if ok, err := client.ChangeDir("/sub"); !ok {
+		t.Fatal("Unable to change the current directory.", err)
+	}
+
+	if ok, err := client.Delete("old.txt"); !ok {
+		t.Fatal("Unable to delete a file listed without permissions.", err)
+	}
+
+	if ok, err := client.Delete("/sub/"); !ok {
+		t.Fatal("Unable to empty a directory listed without permissions.", err)
+	}
+
+	deleted := 0
+	for _, command := range server.Commands() {
+		if command == "DELE old.txt" {
+			deleted++
+		}
+	}
+
+	if deleted != 2 {
+		t.Fatal("Invalid file removals.", server.Commands())
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var visited []string
+
+	client := connectAs(t, "user", startServer(t, newTree()))
+	if ok, err := client.ChangeDir("/tree"); !ok {
+		t.Fatal("Unable to change the current directory.", err)
+	}
+
+	err := client.Walk("/", func(path string, res *Resources.Resource, err error) error {
+		if err != nil {
+			return err
+		}
+
+		visited = append(visited, path)
+		if path == "/tree/sub/deeper" {
+			return Client.SkipDir
+		}
+
+		return nil
+	})
+
+	expected := []string{"/", "/keep.txt", "/tree", "/tree/a.txt", "/tree/sub", "/tree/sub/b.txt", "/tree/sub/deeper"}
+	if err != nil || !reflect.DeepEqual(visited, expected) {
+		t.Fatal("Invalid walk.", err, visited)
+	}
+
+	/* The current directory is left unchanged */
+	if dir := client.CurrentDir(); dir != "/tree/" || client.Resources.GetContentByName("a.txt") == nil {
+		t.Fatal("Walk changed the current directory.", dir)
+	}
+
+	/* Relative roots, and early stops */
+	visited = nil
+	err = client.Walk("sub", func(path string, res *Resources.Resource, err error) error {
+		visited = append(visited, path)
+		if res.IsFile() {
+			return Client.SkipAll
+		}
+
+		return nil
+	})
+
+	if err != nil || !reflect.DeepEqual(visited, []string{"sub", "sub/b.txt"}) {
+		t.Fatal("Invalid walk of a relative root.", err, visited)
+	}
+
+	err = client.Walk("missing", func(path string, res *Resources.Resource, err error) error {
+		return err
+	})
+
+	if err != Client.ERR_UnableToLocateRes {
+		t.Fatal("Missing root not reported.", err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	client := connectAs(t, "user", startServer(t, newTree()))
+	fsys := client.FS()
+
+	if err := fstest.TestFS(fsys, "keep.txt", "tree/a.txt", "tree/sub/deeper/c.txt"); err != nil {
+		t.Fatal("Invalid file system.", err)
+	}
+
+	if data, err := fs.ReadFile(fsys, "tree/sub/b.txt"); err != nil || string(data) != "b" {
+		t.Fatal("Invalid file contents.", string(data), err)
+	}
+
+	if _, err := fs.Stat(fsys, "tree/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Missing file found.", err)
+	}
+
+	/* The client is busy while a file is read */
+	file, _ := fsys.Open("keep.txt")
+	buffer := make([]byte, 1)
+	if _, err := file.Read(buffer); err != nil {
+		t.Fatal("Unable to read a file.", err)
+	}
+
+	if _, err := client.List(); err != Client.ERR_TransferInProgress {
+		t.Fatal("Client used while a file is read.", err)
+	}
+
+	/* Aborting the download leaves the connection usable */
+	file.Close()
+	if _, err := client.List(); err != nil {
+		t.Fatal("Unable to use the client after closing a partially read file.", err)
+	}
+}
+
+func TestOpenCreate(t *testing.T) {
+	driver := newTree()
+	client := connectAs(t, "user", startServer(t, driver))
+	if ok, err := client.ChangeDir("/tree"); !ok {
+		t.Fatal("Unable to change the current directory.", err)
+	}
+
+	/* Compress straight into a remote file */
+	writer, err := client.Create("a.txt.gz")
+	if err != nil {
+		t.Fatal("Unable to create a remote file.", err)
+	}
+
+	compressor := gzip.NewWriter(writer)
+	compressor.Write(bytes.Repeat([]byte("content"), 10000))
+	compressor.Close()
+
+	if err = writer.Close(); err != nil {
+		t.Fatal("Upload not completed.", err)
+	}
+
+	reader, err := client.Open("/tree/a.txt.gz")
+	if err != nil {
+		t.Fatal("Unable to open a remote file.", err)
+	}
+
+	decompressor, err := gzip.NewReader(reader)
+	if err != nil {
+		t.Fatal("Invalid remote file contents.", err)
+	}
+
+	data, err := io.ReadAll(decompressor)
+	if err != nil || !bytes.Equal(data, bytes.Repeat([]byte("content"), 10000)) {
+		t.Fatal("Invalid remote file contents.", len(data), err)
+	}
+
+	if err = reader.Close(); err != nil {
+		t.Fatal("Download not completed.", err)
+	}
+
+	/* Refused transfers fail early, leaving the client usable */
+	if reader, err = client.Open("missing.txt"); reader != nil || err == nil {
+		t.Fatal("Missing file opened.", err)
+	}
+
+	if writer, err = client.Create("/missing/b.txt"); writer != nil || err == nil {
+		t.Fatal("File created in a missing directory.", err)
+	}
+
+	if _, err = client.List(); err != nil {
+		t.Fatal("Unable to use the client after a refused transfer.", err)
+	}
+
+	/* Empty files */
+	writer, _ = client.Create("empty.txt")
+	if err = writer.Close(); err != nil {
+		t.Fatal("Unable to create an empty file.", err)
+	}
+
+	if info, err := driver.Stat("/tree/empty.txt"); err != nil || info.Size() != 0 {
+		t.Fatal("Empty file not created.", err)
+	}
+
+	reader, _ = client.Open("empty.txt")
+	if data, err = io.ReadAll(reader); err != nil || len(data) != 0 || reader.Close() != nil {
+		t.Fatal("Invalid empty file download.", err)
+	}
+}
+
+func TestOpenReaderAt(t *testing.T) {
+	var archive bytes.Buffer
+	var transcript bytes.Buffer
+
+	/* Archive with a large leading member */
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1 << 14)
+	writer := zip.NewWriter(&archive)
+	for name, content := range map[string][]byte{"large.bin": large, "small.txt": []byte("small")} {
+		member, _ := writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		member.Write(content)
+	}
+	writer.Close()
+
+	driver := newTree()
+	driver.WriteFile("/archive.zip", archive.Bytes())
+	client := connectAs(t, "user", startServer(t, driver))
+
+	/* Read the central directory and a single member */
+	reader, size, err := client.OpenReaderAt("archive.zip")
+	if err != nil || size != int64(archive.Len()) {
+		t.Fatal("Unable to open a remote file for random access.", size, err)
+	}
+
+	files, err := zip.NewReader(reader, size)
+	if err != nil {
+		t.Fatal("Unable to read the remote archive.", err)
+	}
+
+	for _, file := range files.File {
+		if file.Name == "small.txt" {
+			member, _ := file.Open()
+			if data, err := io.ReadAll(member); err != nil || string(data) != "small" {
+				t.Fatal("Invalid archive member.", string(data), err)
+			}
+		}
+	}
+
+	/* Reads past the end of the file */
+	buffer := make([]byte, 20)
+	if n, err := reader.ReadAt(buffer, size - 10); n != 10 || err != io.EOF || !bytes.Equal(buffer[:10], archive.Bytes()[size - 10:]) {
+		t.Fatal("Invalid read at the end of the file.", n, err)
+	}
+
+	/* Cached blocks are not requested again */
+	client.SetRecorder(Transcript.NewRecorder(&transcript))
+	reader, _, _ = client.OpenReaderAt("/archive.zip", Client.ReadCache{BlockSize: 4096, Blocks: 4})
+
+	for _, offset := range []int64{100, 5000, 200, 4200} {
+		if n, err := reader.ReadAt(buffer, offset); n != len(buffer) || err != nil || !bytes.Equal(buffer, archive.Bytes()[offset:offset + 20]) {
+			t.Fatal("Invalid cached read.", offset, n, err)
+		}
+	}
+
+	if retrieved := strings.Count(transcript.String(), "RETR /archive.zip"); retrieved != 2 {
+		t.Fatal("Cached blocks requested again.", retrieved)
+	}
+
+	if _, err := client.List(); err != nil {
+		t.Fatal("Unable to use the client after random access reads.", err)
+	}
+}
+
+func TestDownloadSegmented(t *testing.T) {
+	var reported int
+	var mutex sync.Mutex
+
+	content := make([]byte, 100003)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+
+	driver := newTree()
+	driver.WriteFile("/tree/large.bin", content)
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	client := connectAs(t, "user", startServer(t, driver))
+
+	local := filepath.Join(dir, "large.bin")
+	ok, err := client.DownloadSegmented("/tree/large.bin", local, 4, func(status *Client.DataTransferStatus) {
+		mutex.Lock()
+		reported = status.Written
+		mutex.Unlock()
+	})
+
+	if !ok || err != nil {
+		t.Fatal("Unable to download the file in segments.", err)
+	}
+
+	if data, err := os.ReadFile(local); err != nil || !bytes.Equal(data, content) {
+		t.Fatal("Invalid segmented download.", len(data), err)
+	}
+
+	if reported != len(content) {
+		t.Fatal("Invalid aggregated progress.", reported)
+	}
+
+	/* Files smaller than the number of segments */
+	if ok, err = client.DownloadSegmented("tree/a.txt", local, 8); !ok || err != nil {
+		t.Fatal("Unable to download a small file in segments.", err)
+	}
+
+	if data, _ := os.ReadFile(local); string(data) != "a" {
+		t.Fatal("Invalid small file download.", string(data))
+	}
+
+	/* Relative local paths are resolved against the client's local directory */
+	t.Chdir(t.TempDir())
+	if ok, err = client.DownloadSegmented("tree/a.txt", "a.txt", 2); !ok || err != nil {
+		t.Fatal("Unable to download a file in segments to a relative path.", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "a" {
+		t.Fatal("Relative path not resolved against the local directory.", string(data))
+	}
+
+	if ok, err = client.DownloadSegmented("tree/missing.bin", local, 2); ok || err == nil {
+		t.Fatal("Missing file downloaded.")
+	}
+
+	/* The client itself is not used for the transfers */
+	if _, err = client.List(); err != nil {
+		t.Fatal("Unable to use the client after a segmented download.", err)
+	}
+}
+
+func TestDownloadSegmentedCount(t *testing.T) {
+	/* Segments are never empty: 10 bytes in 9 segments are downloaded by 5 sessions of 2 bytes */
+	client, server := scriptedClient(t, "SIZE", "REST STREAM")
+	server.Reply("SIZE", "213 10")
+	server.Reply("REST", "350 Restarting.")
+	server.Transfer("RETR", "150 Opening data connection.", []byte("xx"), "226 Transfer complete.")
+
+	local := filepath.Join(t.TempDir(), "small.bin")
+	if ok, err := client.DownloadSegmented("small.bin", local, 9); !ok {
+		t.Fatal("Unable to download a small file in segments.", err)
+	}
+
+	if data, _ := os.ReadFile(local); string(data) != "xxxxxxxxxx" {
+		t.Fatal("Invalid segmented download.", string(data))
+	}
+
+	/* The client's own session included */
+	sessions := 0
+	for _, command := range server.Commands() {
+		if strings.HasPrefix(command, "USER") {
+			sessions++
+		}
+	}
+
+	if sessions != 6 {
+		t.Fatal("Invalid number of segment sessions.", sessions - 1)
+	}
+}
+
+func TestPool(t *testing.T) {
+	pool, err := Client.NewPool("ftp://user:secret@" + startServer(t, newTree()) + "/", 2)
+	if err != nil {
+		t.Fatal("Unable to create a pool.", err)
+	}
+	defer pool.Close()
+
+	/* Concurrent operations, each goroutine using it's own session */
+	var wait sync.WaitGroup
+	failures := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+
+			client, err := pool.Acquire(context.Background())
+			if err == nil {
+				_, err = client.ListDir("/tree")
+				pool.Release(client)
+			}
+
+			failures <- err
+		}()
+	}
+
+	wait.Wait()
+	close(failures)
+	for err := range failures {
+		if err != nil {
+			t.Fatal("Concurrent pool use failed.", err)
+		}
+	}
+
+	/* All the sessions are lent */
+	first, _ := pool.Acquire(context.Background())
+	second, _ := pool.Acquire(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+	defer cancel()
+
+	if client, err := pool.Acquire(ctx); client != nil || err != context.DeadlineExceeded {
+		t.Fatal("Session lent twice.", err)
+	}
+
+	/* Released sessions get back to their initial directory */
+	second.ChangeDir("/tree")
+	pool.Release(second)
+	if client, _ := pool.Acquire(context.Background()); client != second || client.CurrentDir() != "/" {
+		t.Fatal("Initial directory not restored.", client.CurrentDir())
+	}
+
+	/* Dead sessions are replaced */
+	first.Quit()
+	pool.Release(first)
+	if client, err := pool.Acquire(context.Background()); err != nil || client == first {
+		t.Fatal("Dead session lent.", err)
+	} else if _, err = client.List(); err != nil {
+		t.Fatal("Unable to use a replacement session.", err)
+	} else {
+		first = client
+	}
+
+	/* Idle sessions are checked before being lent */
+	pool.SetHealthCheckAfter(0)
+	pool.Release(first)
+	first.Quit()
+	if client, err := pool.Acquire(context.Background()); err != nil || client == first {
+		t.Fatal("Dead idle session lent.", err)
+	}
+
+	pool.Close()
+	if _, err := pool.Acquire(context.Background()); err != Client.ERR_PoolClosed {
+		t.Fatal("Session lent by a closed pool.", err)
+	}
+}
@@ -15,6 +15,7 @@ type Command struct {
 	ok					bool
 	err					[]error
 	response			*Response.Response
+	responses			[]*Response.Response	/* All responses received while executing the command */
 }
 
 /* Client Command builder */
@@ -26,7 +27,7 @@ func NewCommand(command string, parameters string, statuses []int) *Command {
 		status[s] = true
 	}
 
-	return &Command{command, parameters, status, true, []error{}, &Response.Response{}}
+	return &Command{command, parameters, status, true, []error{}, &Response.Response{}, []*Response.Response{}}
 }
 
 /* Adds a new error to the command errors list */
@@ -45,6 +46,10 @@ func (c *Command) AttachResponse(response *Response.Response, err error) {
 	}
 
 	c.response = response
+
+	if response != nil {
+		c.responses = append(c.responses, response)
+	}
 }
 
 /* Command serialization as byte array */
@@ -127,6 +132,11 @@ func (c *Command) Response() *Response.Response {
 	return c.response
 }
 
+/* Getter for all responses received for the current command, including preliminary replies */
+func (c *Command) Responses() []*Response.Response {
+	return c.responses
+}
+
 /* Checks if the current command completed successfully after it's execution */
 func (c *Command) Success() bool {
 	return c.ok
@@ -8,8 +8,10 @@ import (
 	ResourceParser "github.com/ghepesdoru/bookwormFTP/core/parsers/resource"
 	Requester "github.com/ghepesdoru/bookwormFTP/client/requester"
 	Status "github.com/ghepesdoru/bookwormFTP/core/codes"
+	"context"
 	"fmt"
 	"io"
+	"regexp"
 	"strings"
 	"strconv"
 	"time"
@@ -41,6 +43,16 @@ const (
 	FILESTRUCT_Record 	= "R"
 	FILESTRUCT_Page   	= "P"
 	FILESTRUCT_Unspecified = "U"
+
+	/* Security mechanisms */
+	AUTH_TLS			= "TLS"
+	AUTH_SSL			= "SSL"
+
+	/* Data channel protection levels */
+	PROT_Clear			= "C"
+	PROT_Safe			= "S"
+	PROT_Confidential	= "E"
+	PROT_Private		= "P"
 )
 
 /* Default errors definition */
@@ -56,9 +68,16 @@ var (
 	ERR_InvalidType		= fmt.Errorf("Invalid type specified. Please consider using one of the available types (A, E, I, L).")
 	ERR_InvalidFMTCTRL	= fmt.Errorf("Invalid format control. Please consider using one of the avialable format controls (N, T, C).")
 	ERR_InvalidByteSize	= fmt.Errorf("Invalid byte size for Local byte Byte size type.")
+	ERR_NoUniqueName	= fmt.Errorf("Unable to determine the server assigned file name.")
+	ERR_InvalidPortAddr	= fmt.Errorf("PORT requires an IPv4 address. Please consider using EPRT.")
+	ERR_InvalidMechanism= fmt.Errorf("Unsupported security mechanism. Please consider using one of the available mechanisms (TLS, SSL).")
+	ERR_InvalidProtLevel= fmt.Errorf("Invalid data channel protection level. Please consider using one of the available levels (C, S, E, P).")
 )
 
 var (
+	/* Matches the server assigned file name in a STOU reply (RFC1123: "FILE: pppp") */
+	MatchUniqueFileName = regexp.MustCompile(`(?i)FILE:[[:blank:]]*([^[:space:]]+)`)
+
 	/* Definition of valid representation types */
 	RepresentationTypes = map[string]map[string]bool{
 		/* ASCII type */
@@ -93,10 +112,10 @@ func NewCommands() (c *Commands) {
 }
 
 /* Instantiate a new Commands instance. This can be used as a commands provider in the client */
-func NewCommandsProvider(hostURL string) (c *Commands, err error) {
+func NewCommandsProvider(hostURL string, timeouts ...Requester.Timeouts) (c *Commands, err error) {
 	var requester *Requester.Requester
 
-	if requester, err = Requester.NewRequester(hostURL); err == nil {
+	if requester, err = Requester.NewRequester(hostURL, timeouts...); err == nil {
 		c = NewCommands()
 		_, err = c.AttachRequester(requester)
 
@@ -152,6 +171,15 @@ func (c *Commands) Requester() *Requester.Requester {
 	return nil
 }
 
+/* Binds the specified context to the attached requester, until the returned release function is called */
+func (c *Commands) bindContext(ctx context.Context) (release func()) {
+	if c.requester == nil {
+		return func() {}
+	}
+
+	return c.requester.BindContext(ctx)
+}
+
 /* Implementation for all commands that only have to return a status flag and eventual errors */
 func (c *Commands) simpleControlCommand(name string, param string, expected ...int) (bool, error) {
 	if ok, err := c.IsReady(); !ok {
@@ -165,6 +193,34 @@ func (c *Commands) simpleControlCommand(name string, param string, expected ...i
 	return command.Success(), command.LastError()
 }
 
+/* Implementation for the active mode commands. Opens a local data listener on the specified port (0 for any port) and advertises it */
+func (c *Commands) activeModeCommand(name string, port uint) (ok bool, err error) {
+	var addr *Address.Addr
+	var specifier string
+
+	if ok, err = c.IsReady(); !ok {
+		return
+	}
+
+	if addr, err = c.requester.ListenDataPort(int(port)); err != nil {
+		return false, err
+	}
+
+	if name == "eprt" {
+		specifier = addr.ToExtendedPortSpecifier()
+	} else if specifier = addr.ToPortSpecifier(); specifier == EmptyString {
+		/* PORT only supports IPv4 addresses */
+		c.requester.CloseDataListener()
+		return false, ERR_InvalidPortAddr
+	}
+
+	if ok, err = c.simpleControlCommand(name, specifier, Status.PositiveCompletion); !ok {
+		c.requester.CloseDataListener()
+	}
+
+	return
+}
+
 /* Implementation for all commands that require a server response message on the control connection */
 func (c *Commands) controlCommand(name string, param string, expected ...int) (bool, error, string) {
 	ok, err, msg := c.controlCommandByte(name, param, expected...)
@@ -206,6 +262,17 @@ func (c *Commands) dataCommand(w io.Writer, name string, param string, expected
 	return data, command.LastError()
 }
 
+/* Implementation for all commands that push the contents of a reader on the data connection */
+func (c *Commands) uploadCommand(r io.Reader, name string, param string, expected ...int) (*Command.Command, error) {
+	if ok, err := c.IsReady(); !ok {
+		return nil, err
+	}
+
+	command := c.requester.RequestUpload(Command.NewCommand(name, param, expected), r)
+	c.lastCommand = command
+	return command, command.LastError()
+}
+
 /* Return the last executed command */
 func (c *Commands) LastStatus() int {
 	if c.lastCommand != nil && c.lastCommand.IsValidResponse() {
@@ -217,8 +284,9 @@ func (c *Commands) LastStatus() int {
 
 /* Checks if the server supports the last executed command */
 func (c *Commands) LastIsImplemented() bool {
-	status := c.LastStatus()
-	if status != -1 {
+	/* Failures other than the not implemented ones (missing resources, ...) still prove the command's support */
+	if c.lastCommand != nil && c.lastCommand.Response() != nil {
+		status := c.lastCommand.Response().Status()
 		if status != Status.NotImplemented && Status.CommandNotImplemented != status {
 			return true
 		}
@@ -252,20 +320,41 @@ func (c *Commands) ALLO() (bool, error) {
 	return c.simpleControlCommand("allo", EmptyString, 0)
 }
 
-func (c *Commands) APPE() (bool, error) {
-	return c.simpleControlCommand("appe", EmptyString, 0)
+func (c *Commands) APPE(path string, r io.Reader) (bool, error) {
+	command, err := c.uploadCommand(r, "appe", path, Status.DataConnectionClose, Status.FileActionOk)
+	return err == nil && command.Success(), err
 }
 
-func (c *Commands) AUTH() (bool, error) {
-	return c.simpleControlCommand("auth", EmptyString, 0)
+func (c *Commands) AUTH(mechanism string) (ok bool, err error) {
+	mechanism = asUpperNormalized(mechanism)
+
+	if mechanism != AUTH_TLS && mechanism != AUTH_SSL {
+		return false, ERR_InvalidMechanism
+	}
+
+	if ok, err = c.simpleControlCommand("auth", mechanism, Status.SecurityDataExchangeComplete); ok {
+		/* Negotiate TLS on the control connection */
+		if err = c.requester.StartTLS(); err != nil {
+			ok = false
+		}
+	}
+
+	return
 }
 
 func (c *Commands) AUTH_PLUS() (bool, error) {
 	return c.simpleControlCommand("auth+", EmptyString, 0)
 }
 
-func (c *Commands) CCC() (bool, error) {
-	return c.simpleControlCommand("ccc", EmptyString, Status.PositiveCompletion)
+func (c *Commands) CCC() (ok bool, err error) {
+	if ok, err = c.simpleControlCommand("ccc", EmptyString, Status.PositiveCompletion); ok {
+		/* Continue in clear text on the control connection */
+		if err = c.requester.StopTLS(); err != nil {
+			ok = false
+		}
+	}
+
+	return
 }
 
 func (c *Commands) CDUP() (bool, error) {
@@ -289,9 +378,7 @@ func (c *Commands) ENC() (bool, error) {
 }
 
 func (c *Commands) EPRT(port uint) (bool, error) {
-	addr := c.requester.GetHostAddr()
-	addr.Port = int(port)
-	return c.simpleControlCommand("eprt", addr.ToExtendedPortSpecifier(), Status.PositiveCompletion)
+	return c.activeModeCommand("eprt", port)
 }
 
 func (c *Commands) EPSV() (ok bool, err error) {
@@ -330,16 +417,26 @@ func (c *Commands) LANG(lang string) (bool, error) {
 }
 
 func (c *Commands) LIST(path string) (*ResourceParser.Resource, error) {
+	return c.LISTForSystem(path, EmptyString)
+}
+
+/* Lists the specified path, parsing the listing in the format of the specified system (SYST reply) first */
+func (c *Commands) LISTForSystem(path string, system string) (*ResourceParser.Resource, error) {
 	var res *ResourceParser.Resource
 
 	data, err := c.simpleDataCommand("list", path, Status.DataConnectionClose)
 	if err == nil {
-		res, err = ResourceParser.FromList(data)
+		res, err = ResourceParser.FromListWithSystem(data, system)
 	}
 
 	return res, err
 }
 
+func (c *Commands) LISTContext(ctx context.Context, path string) (*ResourceParser.Resource, error) {
+	defer c.bindContext(ctx)()
+	return c.LIST(path)
+}
+
 func (c *Commands) MDTM(path string) (t *time.Time, err error) {
 	var result []byte
 	_, err, result = c.controlCommandByte("mdtm", path, Status.FileStatus)
@@ -369,15 +466,20 @@ func (c *Commands) MLSD(dir string) (res *ResourceParser.Resource, err error) {
 	return
 }
 
+func (c *Commands) MLSDContext(ctx context.Context, dir string) (*ResourceParser.Resource, error) {
+	defer c.bindContext(ctx)()
+	return c.MLSD(dir)
+}
+
 func (c *Commands) MLST(file string) (res *ResourceParser.Resource, err error) {
 	var data []byte
 	if len(file) == 0 {
 		return res, ERR_InvalidFileName
 	}
 
-	data, err = c.simpleDataCommand("mlst", file, Status.FileActionOk)
-	if err == nil {
-		res, err = ResourceParser.FromMLSxList(data)
+	/* The resource facts are sent on the control connection (RFC3659 7.2) */
+	if _, err, data = c.controlCommandByte("mlst", file, Status.FileActionOk); err == nil {
+		res, err = ResourceParser.FromMLST(data)
 	}
 
 	return
@@ -418,8 +520,8 @@ func (c *Commands) PASV() (ok bool, err error) {
 	return
 }
 
-func (c *Commands) PBSZ() (bool, error) {
-	return c.simpleControlCommand("pbsz", EmptyString, 0)
+func (c *Commands) PBSZ(size uint) (bool, error) {
+	return c.simpleControlCommand("pbsz", strconv.FormatUint(uint64(size), 10), Status.PositiveCompletion)
 }
 
 func (c *Commands) PBSZ_PLUS() (bool, error) {
@@ -427,13 +529,22 @@ func (c *Commands) PBSZ_PLUS() (bool, error) {
 }
 
 func (c *Commands) PORT(port uint) (bool, error) {
-	addr := c.requester.GetHostAddr()
-	addr.Port = int(port)
-	return c.simpleControlCommand("port", addr.ToPortSpecifier(), Status.PositiveCompletion)
+	return c.activeModeCommand("port", port)
 }
 
-func (c *Commands) PROT() (bool, error) {
-	return c.simpleControlCommand("prot", EmptyString, 0)
+func (c *Commands) PROT(level string) (ok bool, err error) {
+	level = asUpperNormalized(level)
+
+	if level != PROT_Clear && level != PROT_Safe && level != PROT_Confidential && level != PROT_Private {
+		return false, ERR_InvalidProtLevel
+	}
+
+	if ok, err = c.simpleControlCommand("prot", level, Status.PositiveCompletion); ok {
+		/* Only the private level is supported by TLS, everything else is sent in clear text */
+		c.requester.ProtectData(level == PROT_Private)
+	}
+
+	return
 }
 
 func (c *Commands) PROT_PLUS() (bool, error) {
@@ -487,6 +598,11 @@ func (c *Commands) RETR(path string, w io.Writer) ([]byte, error) {
 	return c.dataCommand(w, "retr", path, Status.DataConnectionClose)
 }
 
+func (c *Commands) RETRContext(ctx context.Context, path string, w io.Writer) ([]byte, error) {
+	defer c.bindContext(ctx)()
+	return c.RETR(path, w)
+}
+
 func (c *Commands) RMD(path string) (bool, error) {
 	return c.simpleControlCommand("rmd", path, Status.FileActionOk)
 }
@@ -505,7 +621,7 @@ func (c *Commands) SITE(params string) (bool, error) {
 
 func (c *Commands) SIZE(fileName string) (int, error) {
 	_, err, response := c.controlCommand("size", fileName, Status.FileStatus)
-	return BaseParser.ToInt([]byte(response)), err
+	return BaseParser.ToInt(BaseParser.Trim([]byte(response))), err
 }
 
 func (c *Commands) SMNT(path string) (bool, error) {
@@ -517,12 +633,31 @@ func (c *Commands) STAT(resource string) (string, error) {
 	return string(response), err
 }
 
-func (c *Commands) STOR() {
+func (c *Commands) STOR(path string, r io.Reader) (bool, error) {
+	command, err := c.uploadCommand(r, "stor", path, Status.DataConnectionClose, Status.FileActionOk)
+	return err == nil && command.Success(), err
+}
 
+func (c *Commands) STORContext(ctx context.Context, path string, r io.Reader) (bool, error) {
+	defer c.bindContext(ctx)()
+	return c.STOR(path, r)
 }
 
-func (c *Commands) STOU() {
+func (c *Commands) STOU(r io.Reader) (name string, err error) {
+	var command *Command.Command
+
+	if command, err = c.uploadCommand(r, "stou", EmptyString, Status.DataConnectionClose, Status.FileActionOk); err != nil {
+		return
+	}
+
+	/* The server assigned name is usually part of the preliminary reply, but some servers send it on completion */
+	for _, response := range command.Responses() {
+		if m := MatchUniqueFileName.FindStringSubmatch(response.Message()); m != nil {
+			return m[1], nil
+		}
+	}
 
+	return name, ERR_NoUniqueName
 }
 
 func (c *Commands) STRU(structure string) (bool, error) {
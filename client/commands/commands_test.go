@@ -1 +1,101 @@
 package commands
+
+import (
+	TestServer "github.com/ghepesdoru/bookwormFTP/client/testserver"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/* Starts a scripted server, and attaches a Commands to it */
+func scriptedCommands(t *testing.T) (*Commands, *TestServer.Server) {
+	server, err := TestServer.NewServer()
+	if err != nil {
+		t.Fatal("Unable to start the test server.", err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	c, err := NewCommandsProvider(server.URL("user", "secret", EmptyString))
+	if err != nil {
+		t.Fatal("Unable to connect to the test server.", err)
+	}
+
+	return c, server
+}
+
+func TestSecurityArguments(t *testing.T) {
+	c := NewCommands()
+
+	if _, err := c.AUTH("KERBEROS_V4"); err != ERR_InvalidMechanism {
+		t.Fatal("Unsupported security mechanism accepted.", err)
+	}
+
+	if _, err := c.PROT("X"); err != ERR_InvalidProtLevel {
+		t.Fatal("Invalid protection level accepted.", err)
+	}
+
+	/* Valid arguments still require an attached requester */
+	if _, err := c.PROT("p"); err != ERR_NoRequester {
+		t.Fatal("Protection level command executed without a requester.", err)
+	}
+}
+
+func TestScriptedSession(t *testing.T) {
+	c, server := scriptedCommands(t)
+
+	server.Reply("USER", "331 Password required.")
+	server.Reply("PASS", "530 Login incorrect.")
+	server.Reply("PASS", "230 Logged in.")
+	server.Reply("FEAT", "211-Extensions supported:\r\n MLST size*;modify*;type*;\r\n SIZE\r\n211 End")
+	server.Reply("PWD", "257 \"/home/user\" is the current directory.")
+	server.Transfer("RETR", "150 Opening data connection.", []byte("file content"), "226 Transfer complete.")
+	server.Transfer("STOR", "150 Ok to send data.", nil, "226 Transfer complete.")
+
+	c.USER("user")
+	if ok, _ := c.PASS("wrong"); ok {
+		t.Fatal("Rejected login reported as successful.")
+	}
+
+	c.USER("user")
+	if ok, err := c.PASS("secret"); !ok {
+		t.Fatal("Unable to login.", err)
+	}
+
+	if features, err := c.FEAT(); err != nil || !features.Supports("MLST") || !features.Supports("SIZE") {
+		t.Fatal("Invalid features.", features, err)
+	}
+
+	if dir, err := c.PWD(); err != nil || dir != "/home/user" {
+		t.Fatal("Invalid current directory.", dir, err)
+	}
+
+	/* Unscripted commands are not implemented */
+	if ok, _ := c.NOOP(); ok || c.LastIsImplemented() {
+		t.Fatal("Unscripted command succeeded.")
+	}
+
+	var buffer bytes.Buffer
+	c.PASV()
+	if _, err := c.RETR("file.txt", &buffer); err != nil || buffer.String() != "file content" {
+		t.Fatal("Invalid downloaded content.", buffer.String(), err)
+	}
+
+	c.EPSV()
+	if ok, err := c.STOR("upload.txt", strings.NewReader("uploaded")); !ok {
+		t.Fatal("Unable to upload.", err)
+	}
+
+	if uploads := server.Uploads(); len(uploads) != 1 || string(uploads[0]) != "uploaded" {
+		t.Fatal("Invalid uploaded content.", uploads)
+	}
+
+	c.QUIT()
+
+	expected := []string{"USER user", "PASS wrong", "USER user", "PASS secret", "FEAT", "PWD", "NOOP", "PASV", "RETR file.txt", "EPSV", "STOR upload.txt", "QUIT"}
+	if commands := server.Commands(); !reflect.DeepEqual(commands, expected) {
+		t.Fatal("Invalid commands sequence.", commands)
+	}
+}
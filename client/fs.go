@@ -0,0 +1,279 @@
+package client
+
+import (
+	Resources "github.com/ghepesdoru/bookwormFTP/core/parsers/resource"
+	Path "path"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+/* Default modes of the resources listed without UNIX permissions */
+const (
+	DefaultFileMode		fs.FileMode = 0644
+	DefaultDirMode		fs.FileMode = fs.ModeDir | 0755
+	DefaultLinkMode		fs.FileMode = fs.ModeSymlink | 0777
+)
+
+var (
+	ERR_IsDirectory		 = errors.New("is a directory")
+)
+
+/* Read only io/fs view of the server of a connected client (fs.FS, fs.ReadDirFS, fs.StatFS and fs.ReadFileFS). Names
+ are relative to the client's current directory at the time the FS was created. Resources are described using MLST and
+ MLSD when available, and files are streamed using RETR: the client can not be used while a file is being read (until
+ closed), and only one file can be read at a time */
+type FS struct {
+	client		*Client
+	root		string
+}
+
+/* Remote resource description */
+type fileInfo struct {
+	name		string
+	res			*Resources.Resource
+}
+
+/* Opened remote directory */
+type dirFile struct {
+	info		*fileInfo
+	entries		[]fs.DirEntry
+}
+
+/* Opened remote file, downloaded on first read */
+type remoteFile struct {
+	fsys		*FS
+	path		string
+	info		*fileInfo
+	stream		*download
+	closed		bool
+}
+
+/* Gets an io/fs view of the server, rooted at the current directory */
+func (c *Client) FS() *FS {
+	return &FS{c, c.remotePath(EmptyString)}
+}
+
+/* Opens the named file or directory */
+func (f *FS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		entries, err := f.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &dirFile{info, entries}, nil
+	}
+
+	return &remoteFile{f, f.absolute(name), info, nil, false}, nil
+}
+
+/* Reads the named directory, returning it's entries sorted by name */
+func (f *FS) ReadDir(name string) (entries []fs.DirEntry, err error) {
+	var listing *Resources.Resource
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	originalResources := f.client.Resources
+	listing, err = f.client.list(f.absolute(name), false)
+	f.client.Resources = originalResources
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	for _, r := range listing.Content {
+		if nil != r && r.IsChild() {
+			entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{r.Name, r}))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+/* Reads the whole named file */
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		/* Already described by the file */
+		return nil, err
+	}
+
+	return data, nil
+}
+
+/* Describes the named file or directory */
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+/* Gets the absolute remote path of the specified name */
+func (f *FS) absolute(name string) string {
+	return Path.Join(f.root, name)
+}
+
+/* Describes the named resource using MLST when available, or it's container's listing */
+func (f *FS) stat(op string, name string) (info *fileInfo, err error) {
+	var res *Resources.Resource
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	path := f.absolute(name)
+	if ok, err := f.client.isReady(); !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	if f.client.features.Supports("MLST") {
+		res, err = f.client.Commands.MLST(path)
+	}
+
+	if res == nil {
+		originalResources := f.client.Resources
+		res, err = f.client.stat(path)
+		f.client.Resources = originalResources
+	}
+
+	if err == ERR_UnableToLocateRes {
+		err = fs.ErrNotExist
+	}
+
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	return &fileInfo{Path.Base(name), res}, nil
+}
+
+func (i *fileInfo) Name() string {
+	return i.name
+}
+
+func (i *fileInfo) Size() int64 {
+	return int64(i.res.Size)
+}
+
+/* Gets the listed mode, or a default mode matching the resource type */
+func (i *fileInfo) Mode() fs.FileMode {
+	mode := i.res.Mode
+
+	if mode == 0 {
+		switch {
+		case i.res.IsDir():
+			mode = DefaultDirMode
+		case i.res.IsLink():
+			mode = DefaultLinkMode
+		default:
+			mode = DefaultFileMode
+		}
+	} else if i.res.IsDir() {
+		mode |= fs.ModeDir
+	}
+
+	return mode
+}
+
+/* Gets the last modification time, in UTC */
+func (i *fileInfo) ModTime() time.Time {
+	if i.res.Modify == nil {
+		return time.Time{}
+	}
+
+	return i.res.Modify.UTC()
+}
+
+func (i *fileInfo) IsDir() bool {
+	return i.res.IsDir()
+}
+
+/* Gets the described resource */
+func (i *fileInfo) Sys() any {
+	return i.res
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFile) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: ERR_IsDirectory}
+}
+
+/* Reads the next n entries (all remaining ones for n <= 0) */
+func (d *dirFile) ReadDir(n int) (entries []fs.DirEntry, err error) {
+	if n <= 0 || n > len(d.entries) {
+		if n > 0 && len(d.entries) == 0 {
+			return nil, io.EOF
+		}
+
+		n = len(d.entries)
+	}
+
+	entries, d.entries = d.entries[:n], d.entries[n:]
+	return entries, nil
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+func (r *remoteFile) Stat() (fs.FileInfo, error) {
+	return r.info, nil
+}
+
+/* Reads the file contents, starting the download on the first call */
+func (r *remoteFile) Read(p []byte) (n int, err error) {
+	if r.closed {
+		return 0, &fs.PathError{Op: "read", Path: r.info.name, Err: fs.ErrClosed}
+	}
+
+	if r.stream == nil {
+		if r.stream, err = r.fsys.client.retrieve(r.path); err != nil {
+			return 0, &fs.PathError{Op: "read", Path: r.info.name, Err: err}
+		}
+	}
+
+	if n, err = r.stream.Read(p); err != nil && err != io.EOF {
+		err = &fs.PathError{Op: "read", Path: r.info.name, Err: err}
+	}
+
+	return
+}
+
+func (r *remoteFile) Close() (err error) {
+	if r.closed {
+		return &fs.PathError{Op: "close", Path: r.info.name, Err: fs.ErrClosed}
+	}
+
+	r.closed = true
+	if r.stream != nil {
+		if err = r.stream.Close(); err != nil {
+			err = &fs.PathError{Op: "close", Path: r.info.name, Err: err}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/* Idle time after which the pooled sessions are checked before being lent */
+const DefaultHealthCheckAfter = 30 * time.Second
+
+var (
+	ERR_PoolClosed			= fmt.Errorf("The connection pool is closed.")
+	ERR_InvalidPoolSize		= fmt.Errorf("Invalid pool size. Please specify at least one session.")
+)
+
+/* Pool of sessions to the same server. A Client is not safe for concurrent use, the pool lends each session to a single
+ goroutine at a time (Acquire), until given back (Release). Idle sessions are checked (NOOP) before being lent, and dead
+ sessions are transparently replaced by new ones */
+type Pool struct {
+	connect			func() (*Client, error)
+	sessions		chan *pooledSession
+	lent			map[*Client]*pooledSession
+	healthCheck		time.Duration
+	done			chan struct{}
+	closed			bool
+	mutex			sync.Mutex
+}
+
+/* Pool slot, holding a session (nil once dead, until replaced) */
+type pooledSession struct {
+	client			*Client
+	dir				string		/* Initial directory, restored on release */
+	idleSince		time.Time
+}
+
+/* Instantiates a new pool of size sessions to the specified address. Each session is logged in and initialized (FEAT,
+ TYPE, ...) as a NewClient client */
+func NewPool(address string, size int, timeouts ...Timeouts) (*Pool, error) {
+	return newPool(size, func() (*Client, error) {
+		return connect(address, nil, nil, timeouts...)
+	})
+}
+
+/* Instantiates a new pool of size FTPS sessions to the specified address, as NewClientTLS clients */
+func NewPoolTLS(address string, config *tls.Config, size int, timeouts ...Timeouts) (*Pool, error) {
+	if config == nil {
+		config = &tls.Config{}
+	}
+
+	return newPool(size, func() (*Client, error) {
+		return connect(address, config, nil, timeouts...)
+	})
+}
+
+/* Lends an idle session, waiting for one to be released if all of them are in use. Dead sessions are replaced by new
+ ones before being lent. Fails once the context is done, or the pool closed */
+func (p *Pool) Acquire(ctx context.Context) (*Client, error) {
+	var s *pooledSession
+
+	select {
+	case s = <-p.sessions:
+	case <-p.done:
+		return nil, ERR_PoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if !p.isAlive(s) {
+		if err := p.replace(s); err != nil {
+			/* Keep the slot for a later attempt */
+			p.giveBack(s)
+			return nil, err
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		s.client.close()
+		return nil, ERR_PoolClosed
+	}
+
+	p.lent[s.client] = s
+	return s.client, nil
+}
+
+/* Closes all the idle sessions. Lent sessions are closed once released */
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.closed = true
+	close(p.done)
+
+	for {
+		select {
+		case s := <-p.sessions:
+			if s.client != nil {
+				s.client.close()
+			}
+		default:
+			return
+		}
+	}
+}
+
+/* Gives back the specified lent session, restoring it's initial directory. Unusable sessions (disconnected, or with a
+ streamed transfer still open) are closed, and replaced on a later Acquire. Clients not lent by the pool are ignored */
+func (p *Pool) Release(c *Client) {
+	p.mutex.Lock()
+	s, lent := p.lent[c]
+	delete(p.lent, c)
+	p.mutex.Unlock()
+
+	if !lent {
+		return
+	}
+
+	if ok, _ := c.isReady(); !ok {
+		c.close()
+		s.client = nil
+	} else if c.CurrentDir() != s.dir {
+		if ok, _ := c.ChangeDir(s.dir); !ok {
+			c.close()
+			s.client = nil
+		}
+	}
+
+	p.giveBack(s)
+}
+
+/* Sets the idle time after which the sessions are checked (NOOP) before being lent. Zero checks the sessions each time,
+ negative values disable the checks */
+func (p *Pool) SetHealthCheckAfter(idle time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.healthCheck = idle
+}
+
+/* Instantiates a new pool, connecting all of it's sessions */
+func newPool(size int, connect func() (*Client, error)) (*Pool, error) {
+	if size < 1 {
+		return nil, ERR_InvalidPoolSize
+	}
+
+	p := &Pool{connect, make(chan *pooledSession, size), make(map[*Client]*pooledSession), DefaultHealthCheckAfter, make(chan struct{}), false, sync.Mutex{}}
+
+	for i := 0; i < size; i++ {
+		s := &pooledSession{}
+		if err := p.replace(s); err != nil {
+			p.Close()
+			return nil, err
+		}
+
+		p.sessions <- s
+	}
+
+	return p, nil
+}
+
+/* Puts the specified slot back in the idle sessions (closing it's session if the pool was closed meanwhile) */
+func (p *Pool) giveBack(s *pooledSession) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		if s.client != nil {
+			s.client.close()
+		}
+
+		return
+	}
+
+	s.idleSince = time.Now()
+	p.sessions <- s
+}
+
+/* Checks if the slot's session is usable, using NOOP for sessions idle for longer than the health check interval */
+func (p *Pool) isAlive(s *pooledSession) bool {
+	if s.client == nil {
+		return false
+	}
+
+	p.mutex.Lock()
+	healthCheck := p.healthCheck
+	p.mutex.Unlock()
+
+	if healthCheck < 0 || time.Since(s.idleSince) < healthCheck {
+		return true
+	}
+
+	if ok, _ := s.client.isReady(); ok {
+		if ok, _ = s.client.Commands.NOOP(); ok {
+			return true
+		}
+	}
+
+	/* Dead session */
+	s.client.close()
+	s.client = nil
+	return false
+}
+
+/* Connects a new session in the specified slot */
+func (p *Pool) replace(s *pooledSession) error {
+	client, err := p.connect()
+	if err != nil {
+		if client != nil {
+			client.requester.Close()
+		}
+
+		return err
+	}
+
+	s.client, s.dir, s.idleSince = client, client.CurrentDir(), time.Now()
+	return nil
+}
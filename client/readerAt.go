@@ -0,0 +1,276 @@
+package client
+
+import (
+	ClientCommands "github.com/ghepesdoru/bookwormFTP/client/commands"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+var (
+	ERR_RESTNotImplemented	= fmt.Errorf("Restarted transfers (REST STREAM) not supported at server side.")
+	ERR_NegativeOffset		= fmt.Errorf("Invalid negative offset.")
+)
+
+/* Block cache of a random access reader: up to Blocks blocks of BlockSize bytes are kept, the least recently used ones
+ being dropped first */
+type ReadCache struct {
+	BlockSize	int
+	Blocks		int
+}
+
+/* Random access reader of a remote file */
+type readerAt struct {
+	client		*Client
+	path		string
+	size		int64
+	cache		ReadCache
+	blocks		map[int64][]byte
+	recent		[]int64		/* Cached blocks, least recently used first */
+	mutex		sync.Mutex
+}
+
+/* Writer passing on a byte range of a transfer, ending the transfer once the range is complete */
+type rangeWriter struct {
+	w			io.Writer
+	n			int64
+	length		int64
+	cancel		context.CancelFunc
+}
+
+/* Opens the specified remote file for random access reads, returning it's size. Each read restarts the transfer at the
+ read offset (REST + RETR, in binary mode) and aborts it (ABOR) once enough bytes arrive. An optional block cache reads
+ whole blocks, serving later reads of the same blocks without requesting the server. The client can not be used by
+ other goroutines while reading */
+func (c *Client) OpenReaderAt(path string, cache ...ReadCache) (io.ReaderAt, int64, error) {
+	/* Check connection ready state before executing command */
+	if ok, err := c.isReady(); !ok {
+		return nil, 0, err
+	}
+
+	if !c.features.SupportsParameter("REST", "STREAM") {
+		return nil, 0, ERR_RESTNotImplemented
+	}
+
+	path = c.remotePath(path)
+	size, err := c.remoteSize(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r := &readerAt{c, path, size, ReadCache{}, nil, nil, sync.Mutex{}}
+	if len(cache) > 0 && cache[0].BlockSize > 0 && cache[0].Blocks > 0 {
+		r.cache = cache[0]
+		r.blocks = make(map[int64][]byte)
+	}
+
+	return r, size, nil
+}
+
+/* Reads len(p) bytes starting at the specified offset (io.ReaderAt) */
+func (r *readerAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	if offset < 0 {
+		return 0, ERR_NegativeOffset
+	} else if offset >= r.size {
+		return 0, io.EOF
+	}
+
+	/* The client serves a single request at a time */
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	wanted := p
+	if remaining := r.size - offset; int64(len(p)) > remaining {
+		wanted = p[:remaining]
+	}
+
+	if r.blocks == nil {
+		n, err = r.client.readRange(r.path, offset, wanted)
+	} else {
+		n, err = r.readCached(wanted, offset)
+	}
+
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+
+	return
+}
+
+/* Reads the specified range through the block cache. Consecutive missing blocks are requested together */
+func (r *readerAt) readCached(p []byte, offset int64) (n int, err error) {
+	blockSize := int64(r.cache.BlockSize)
+
+	for n < len(p) {
+		position := offset + int64(n)
+		index := position / blockSize
+		data, cached := r.blocks[index]
+
+		if cached {
+			r.touch(index)
+		} else {
+			/* Request all the missing blocks up to the end of the range */
+			last := index
+			for end := (offset + int64(len(p)) - 1) / blockSize; last < end; last++ {
+				if _, ok := r.blocks[last + 1]; ok {
+					break
+				}
+			}
+
+			length := (last - index + 1) * blockSize
+			if remaining := r.size - index * blockSize; length > remaining {
+				length = remaining
+			}
+
+			data = make([]byte, length)
+			read, err := r.client.readRange(r.path, index * blockSize, data)
+			data = data[:read]
+
+			if err != nil {
+				/* Return the available data, without caching it */
+				if start := position - index * blockSize; start < int64(read) {
+					n += copy(p[n:], data[start:])
+				}
+
+				return n, err
+			}
+
+			for i := index; i <= last && (i - index) * blockSize < int64(read); i++ {
+				start := (i - index) * blockSize
+				r.store(i, data[start:min(start + blockSize, int64(read))])
+			}
+		}
+
+		start := position - index * blockSize
+		if start >= int64(len(data)) {
+			/* The file is shorter than expected */
+			return n, io.EOF
+		}
+
+		n += copy(p[n:], data[start:])
+	}
+
+	return n, nil
+}
+
+/* Caches the specified block, dropping the least recently used blocks over the cache size */
+func (r *readerAt) store(index int64, data []byte) {
+	if _, ok := r.blocks[index]; !ok && len(r.recent) >= r.cache.Blocks {
+		delete(r.blocks, r.recent[0])
+		r.recent = r.recent[1:]
+	}
+
+	r.blocks[index] = data
+	r.touch(index)
+}
+
+/* Marks the specified block as the most recently used one */
+func (r *readerAt) touch(index int64) {
+	for i, cached := range r.recent {
+		if cached == index {
+			r.recent = append(r.recent[:i], r.recent[i + 1:]...)
+			break
+		}
+	}
+
+	r.recent = append(r.recent, index)
+}
+
+/* Gets the binary size of the specified remote file (SIZE), or it's listed size. The representation type is restored
+ once known */
+func (c *Client) remoteSize(path string) (size int64, err error) {
+	if c.features.Supports("SIZE") {
+		if representation, parameter := c.representationType(); representation != ClientCommands.TYPE_Image {
+			defer c.RepresentationType(representation, parameter)
+		}
+
+		c.RepresentationType(ClientCommands.TYPE_Image, nil)
+		if s, err := c.Commands.SIZE(path); err == nil {
+			return int64(s), nil
+		}
+	}
+
+	originalResources := c.Resources
+	res, err := c.stat(path)
+	c.Resources = originalResources
+
+	if err != nil {
+		return 0, err
+	} else if !res.IsFile() {
+		return 0, ERR_NonRetrievable
+	}
+
+	return int64(res.Size), nil
+}
+
+/* Reads len(p) bytes of the specified remote file, starting at the specified offset. Fewer bytes are read from shorter
+ files */
+func (c *Client) readRange(path string, offset int64, p []byte) (int, error) {
+	/* The buffer is filled in place, never growing past len(p) */
+	n, err := c.retrieveRange(path, offset, int64(len(p)), bytes.NewBuffer(p[:0]))
+	return int(n), err
+}
+
+/* Writes length bytes of the specified remote file, starting at the specified offset, to the specified writer. The
+ transfer is aborted once enough bytes arrive */
+func (c *Client) retrieveRange(path string, offset int64, length int64, w io.Writer) (n int64, err error) {
+	/* Check connection ready state before executing command */
+	if ok, err := c.isReady(); !ok {
+		return 0, err
+	}
+
+	if length <= 0 {
+		return 0, nil
+	}
+
+	c.RepresentationType(ClientCommands.TYPE_Image, nil)
+
+	/* Establish the data connection mode first, REST has to precede RETR directly */
+	if !c.inDataMode() {
+		if _, err = c.dataMode(); err != nil {
+			c.RestoreConnections()
+			return 0, err
+		}
+
+		defer c.RestoreConnections()
+	}
+
+	if offset > 0 {
+		if _, err = c.Commands.REST(strconv.FormatInt(offset, 10)); err != nil {
+			return 0, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bounded := &rangeWriter{w, 0, length, cancel}
+	if _, err = c.Commands.RETRContext(ctx, path, bounded); err != nil && bounded.n == length {
+		/* Aborted once the range was complete */
+		err = nil
+	}
+
+	return bounded.n, err
+}
+
+/* Writes the range data, ending the transfer once complete (the remaining data is discarded) */
+func (w *rangeWriter) Write(p []byte) (int, error) {
+	data := p
+	if remaining := w.length - w.n; int64(len(data)) > remaining {
+		data = data[:remaining]
+	}
+
+	written, err := w.w.Write(data)
+	if w.n += int64(written); err != nil {
+		return written, err
+	}
+
+	if w.n == w.length {
+		w.cancel()
+	}
+
+	return len(p), nil
+}
@@ -2,6 +2,7 @@ package requester
 
 import (
 	Command "github.com/ghepesdoru/bookwormFTP/client/command"
+	Transcript "github.com/ghepesdoru/bookwormFTP/client/transcript"
 	Address "github.com/ghepesdoru/bookwormFTP/core/addr"
 	Status "github.com/ghepesdoru/bookwormFTP/core/codes"
 	Commands "github.com/ghepesdoru/bookwormFTP/core/commands"
@@ -12,10 +13,16 @@ import (
 	Response "github.com/ghepesdoru/bookwormFTP/core/response"
 	Net "net"
 	Path "path"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,13 +30,21 @@ const (
 	/* Generic constants */
 	DefaultRequesterProtocol	= "tcp"
 	DefaultHostPort          	= 21
+	DefaultImplicitTLSPort		= 990
 	DefaultUserName				= "anonymous"
 	DefaultPassword				= ""
 	DefaultDataPort				= -1
 	DefaultWorkingDir			= "/"
 	DefaultScheme				= "ftp://"
+	ImplicitTLSScheme			= "ftps"
 	EmptyString              	= ""
 	CommandRetries           	= 3
+	DefaultDialTimeout			= 15 * time.Second
+	DefaultGreetingTimeout		= 15 * time.Second
+	DefaultReplyTimeout			= 60 * time.Second
+	DefaultDataIdleTimeout		= 60 * time.Second
+	CloseNotifyTimeout			= 2 * time.Second
+	AbortTimeout				= 5 * time.Second
 )
 
 /* Error definitions */
@@ -44,6 +59,11 @@ var (
 	ERR_NoServerResponse        = fmt.Errorf("Unable to fetch a response from server at this time.")
 	ERR_RestartSequence         = fmt.Errorf("Restart sequence.")
 	ERR_InvalidDataAddr         = fmt.Errorf("Invalid data connection Addr.")
+	ERR_InvalidPortRange        = fmt.Errorf("Invalid active mode port range.")
+	ERR_InvalidAdvertisedAddr   = fmt.Errorf("Invalid active mode advertised address. Please specify an IP address.")
+	ERR_NoDataConnection        = fmt.Errorf("The server did not open the active mode data connection.")
+	ERR_NoTLSConfig             = fmt.Errorf("No TLS configuration available. Please specify a *tls.Config.")
+	ERR_Timeout                 = fmt.Errorf("Timeout expired while waiting for the server.")
 
 	/* Error formats */
 	ERRF_InvalidCommandName          = "Unrecognized command %s."
@@ -52,6 +72,7 @@ var (
 	ERRF_CommandMaxRetries           = "%s reached the maximum number of retries. Transient Negative Completion reply status %d, message: %s"
 	ERRF_CommandFailure              = "Command failure: %d %s"
 	ERRF_MissingPortInHost           = "missing port in address"
+	ERRF_CommandInterrupted          = "%s: %w"
 )
 
 /* BookwormFTP Requester type definition */
@@ -68,6 +89,20 @@ type Requester struct {
 	connected         	bool
 	ready             	bool
 	Logger				*Logger.Logger
+	dataListener		Net.Listener
+	activePortMin		int
+	activePortMax		int
+	advertisedIP		Net.IP
+	hostName			string
+	tlsConfig			*tls.Config
+	protectData			bool
+	ctx					context.Context
+	stopDataWatch		func() bool
+	timeouts			Timeouts
+	pendingReplies		int
+	recorder			*Transcript.Recorder
+	greeting			[]byte
+	implicitTLS			bool
 }
 
 type DataTransferStatus struct {
@@ -75,24 +110,102 @@ type DataTransferStatus struct {
 	Written				int
 }
 
+/* Connection timeouts. Zero values use the defaults, negative values disable the timeout */
+type Timeouts struct {
+	Dial				time.Duration	/* Establishing the control and data connections (implicit TLS handshake included) */
+	Greeting			time.Duration	/* Waiting for the server greeting */
+	Reply				time.Duration	/* Waiting for each reply, while no data is flowing */
+	DataIdle			time.Duration	/* Maximum time without any data flowing on a data connection */
+}
+
+/* Control connection reply, as read by the control Reader */
+type controlReply struct {
+	raw					[]byte
+	err					error
+}
+
+/* Data connection wrapper. Every read or write pushes the deadline forward by the idle timeout, without passing the bound
+ context deadline. Once interrupted, the connection fails all reads and writes */
+type dataConn struct {
+	Net.Conn
+	idle				time.Duration
+	deadline			time.Time
+	mutex				sync.Mutex
+	interrupted			bool
+}
+
 /* Generates a new Requester using any of the supported ip versions. (IPv4 first) */
-func NewRequester(hostURL string) (r *Requester, err error) {
-	r, err = NewRequesterIPv4(hostURL)
-	if err != nil {
-		r, err = NewRequesterIPv6(hostURL)
+func NewRequester(hostURL string, timeouts ...Timeouts) (r *Requester, err error) {
+	var errIPv4 error
+
+	if r, errIPv4 = NewRequesterIPv4(hostURL, timeouts...); errIPv4 != nil {
+		/* Report the IPv4 failure (refused connection, invalid certificate) for hosts without an IPv6 address */
+		if r, err = NewRequesterIPv6(hostURL, timeouts...); err == ERR_UnableToLookupHost {
+			err = errIPv4
+		}
 	}
 
 	return
 }
 
 /* Instantiate a new Requester that can exclusively use the IPv4 */
-func NewRequesterIPv4(hostURL string) (*Requester, error) {
-	return preprocessRequesterBuild(hostURL, Address.IPv4)
+func NewRequesterIPv4(hostURL string, timeouts ...Timeouts) (*Requester, error) {
+	return preprocessRequesterBuild(hostURL, Address.IPv4, nil, withDefaultTimeouts(timeouts))
 }
 
 /* Instantiate a new Requester that can exclusively use the IPv6 */
-func NewRequesterIPv6(hostURL string) (*Requester, error) {
-	return preprocessRequesterBuild(hostURL, Address.IPv6)
+func NewRequesterIPv6(hostURL string, timeouts ...Timeouts) (*Requester, error) {
+	return preprocessRequesterBuild(hostURL, Address.IPv6, nil, withDefaultTimeouts(timeouts))
+}
+
+/* Instantiate a new Requester using the specified TLS configuration (Address.IPvAny tries IPv4 first). ftps:// URLs are
+ dialed over TLS (implicit FTPS), any other URL keeps the configuration for a later AUTH TLS */
+func NewRequesterTLS(hostURL string, ipFamily int, config *tls.Config, timeouts ...Timeouts) (r *Requester, err error) {
+	var errIPv4 error
+
+	if config == nil {
+		config = &tls.Config{}
+	}
+
+	if ipFamily != Address.IPvAny {
+		return preprocessRequesterBuild(hostURL, ipFamily, config, withDefaultTimeouts(timeouts))
+	}
+
+	if r, errIPv4 = preprocessRequesterBuild(hostURL, Address.IPv4, config, withDefaultTimeouts(timeouts)); errIPv4 != nil {
+		if r, err = preprocessRequesterBuild(hostURL, Address.IPv6, config, withDefaultTimeouts(timeouts)); err == ERR_UnableToLookupHost {
+			err = errIPv4
+		}
+	}
+
+	return
+}
+
+/* Binds the specified context to all following requests, until the returned release function is called. Waiting on the
+ server stops once the context is done, and the control and data connections inherit it's deadline */
+func (r *Requester) BindContext(ctx context.Context) (release func()) {
+	previous := r.ctx
+	r.ctx = ctx
+
+	return func() {
+		r.ctx = previous
+	}
+}
+
+/* Closes the control connection (and any data connection or listener) without notifying the server */
+func (r *Requester) Close() error {
+	r.closeDataChannel(false)
+	r.CloseDataListener()
+	r.connected, r.ready = false, false
+
+	return r.controlConnection.Close()
+}
+
+/* Closes the active mode data listener, if any */
+func (r *Requester) CloseDataListener() {
+	if r.dataListener != nil {
+		r.dataListener.Close()
+		r.dataListener = nil
+	}
 }
 
 /* Initial url credentials getter */
@@ -119,6 +232,17 @@ func (r *Requester) GetDataStatus() *DataTransferStatus {
 	return &DataTransferStatus{}
 }
 
+/* Checks if data connections are currently accepted on a local listener (active mode) */
+func (r *Requester) InActiveMode() bool {
+	return r.dataListener != nil
+}
+
+/* Checks if the control connection is secured using TLS */
+func (r *Requester) IsSecure() bool {
+	_, ok := r.controlConnection.(*tls.Conn)
+	return ok
+}
+
 /* Checks if the current requester is connected */
 func (r *Requester) IsConnected() bool {
 	return r.connected
@@ -129,6 +253,54 @@ func (r *Requester) IsReady() bool {
 	return r.ready
 }
 
+/* Opens a local data listener for active mode transfers and returns the address to advertise to the server.
+ A port of 0 selects the first available port from the configured port range (or any available port) */
+func (r *Requester) ListenDataPort(port int) (addr *Address.Addr, err error) {
+	var listener Net.Listener
+	var ports []int = []int{port}
+	local := Address.FromConnectionLocal(r.controlConnection)
+
+	r.CloseDataListener()
+
+	if port <= 0 && r.activePortMax > 0 {
+		ports = ports[:0]
+		for p := r.activePortMin; p <= r.activePortMax; p += 1 {
+			ports = append(ports, p)
+		}
+	}
+
+	for _, p := range ports {
+		if listener, err = Net.Listen(local.Network(), Net.JoinHostPort(local.IP.String(), strconv.Itoa(p))); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.dataListener = listener
+	r.dataAddress = nil
+	addr = &Address.Addr{IP: local.IP, Port: listener.Addr().(*Net.TCPAddr).Port, IPFamily: local.IPFamily}
+
+	/* Advertise the configured address (NAT) instead of the local one */
+	if r.advertisedIP != nil {
+		ip := r.advertisedIP
+		addr.IP = &ip
+
+		if !Address.IsIPv4(&ip) {
+			addr.IPFamily = Address.IPv6
+		}
+	}
+
+	return addr, nil
+}
+
+/* Enables or disables TLS on the following data connections (PROT P/PROT C) */
+func (r *Requester) ProtectData(protect bool) {
+	r.protectData = protect
+}
+
 /* Register data address */
 func (r *Requester) RegisterDataAddr(addr *Address.Addr) (bool, error) {
 	if nil == addr {
@@ -136,12 +308,15 @@ func (r *Requester) RegisterDataAddr(addr *Address.Addr) (bool, error) {
 	}
 
 	/* Correct Addr that only specify a port */
-	if addr.IP == nil {
+	if addr.IP == nil || *addr.IP == nil {
 		addr2 := r.GetHostAddr()
 		addr.IP = addr2.IP
 		addr.IPFamily = addr2.IPFamily
 	}
 
+	/* Passive mode replaces any previous active mode listener */
+	r.CloseDataListener()
+
 	r.dataAddress = addr
 	return true, nil
 }
@@ -170,22 +345,145 @@ func (r *Requester) RequestDataPipe(command *Command.Command, w io.Writer) (*Com
 	return r.executeDataCommand(command, w)
 }
 
+/* Make an upload request to the server, writing all the specified reader's content on the data connection */
+func (r *Requester) RequestUpload(command *Command.Command, source io.Reader) *Command.Command {
+	return r.executeUploadCommand(command, source)
+}
+
+/* Records the session transcript (commands, replies and data) with the specified recorder, nil to stop recording. The
+ server greeting, already read, is recorded first */
+func (r *Requester) SetRecorder(recorder *Transcript.Recorder) {
+	if r.recorder = recorder; recorder != nil && r.greeting != nil {
+		recorder.Reply(r.greeting)
+	}
+}
+
+/* Registers the TLS configuration used for the control and data connections */
+func (r *Requester) SetTLSConfig(config *tls.Config) {
+	if config == nil {
+		r.tlsConfig = nil
+		return
+	}
+
+	r.tlsConfig = buildTLSConfig(config, r.hostName)
+}
+
+/* Restricts the active mode data listener to the specified (inclusive) port range. Use 0, 0 for any available port */
+func (r *Requester) SetActivePortRange(min int, max int) error {
+	if min < 0 || max > 65535 || min > max || (min == 0 && max != 0) {
+		return ERR_InvalidPortRange
+	}
+
+	r.activePortMin, r.activePortMax = min, max
+	return nil
+}
+
+/* Advertises the specified IP address in PORT/EPRT commands instead of the local address (for clients behind NAT). Use an empty string to reset */
+func (r *Requester) SetAdvertisedAddr(ip string) error {
+	if ip == EmptyString {
+		r.advertisedIP = nil
+		return nil
+	}
+
+	if r.advertisedIP = Net.ParseIP(ip); r.advertisedIP == nil {
+		return ERR_InvalidAdvertisedAddr
+	}
+
+	return nil
+}
+
+/* Upgrades the control connection to TLS (explicit FTPS, after a successful AUTH TLS) */
+func (r *Requester) StartTLS() (err error) {
+	var conn *tls.Conn
+
+	if r.tlsConfig == nil {
+		return ERR_NoTLSConfig
+	}
+
+	if r.IsSecure() {
+		return nil
+	}
+
+	conn = tls.Client(r.controlConnection, r.tlsConfig)
+	if err = conn.Handshake(); err != nil {
+		/* The connection state is unknown after a failed handshake */
+		r.ready = false
+		return err
+	}
+
+	r.controlConnection = conn
+	r.controlReader = Reader.NewReader(conn)
+	return nil
+}
+
+/* Drops the control connection TLS layer and continues in clear text (after a successful CCC) */
+func (r *Requester) StopTLS() (err error) {
+	conn, ok := r.controlConnection.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	/* Exchange the TLS closure alerts without closing the underlying connection */
+	err = conn.CloseWrite()
+	conn.SetReadDeadline(time.Now().Add(CloseNotifyTimeout))
+	io.Copy(io.Discard, conn)
+
+	/* The closure alert exchange leaves deadlines on the underlying connection */
+	r.controlConnection = conn.NetConn()
+	r.controlConnection.SetDeadline(time.Time{})
+	r.controlReader = Reader.NewReader(r.controlConnection)
+	return err
+}
+
+/* Opens a new control connection to the same host, reusing the credentials, TLS configuration, timeouts and active mode
+ settings. The new requester is neither authenticated nor secured (implicit FTPS aside) */
+func (r *Requester) NewSession() (session *Requester, err error) {
+	if session, err = buildRequester(r.hostAddress, r.credentials, EmptyString, r.tlsConfig, r.implicitTLS, r.timeouts); err != nil {
+		return nil, err
+	}
+
+	session.hostName = r.hostName
+	session.activePortMin, session.activePortMax = r.activePortMin, r.activePortMax
+	session.advertisedIP = r.advertisedIP
+	session.Logger = r.Logger
+
+	return session, nil
+}
+
 /* Make a sequence of requests */
 func (r *Requester) Sequence(commands ...*Command.Command) (bool, *Command.Command) {
 	return r.sequence(commands)
 }
 
 /* Prepares required data for the new Requester instance to be build */
-func preprocessRequesterBuild(hostURL string, ipFamily int) (*Requester, error) {
+func preprocessRequesterBuild(hostURL string, ipFamily int, config *tls.Config, timeouts Timeouts) (*Requester, error) {
 	var host, path string
 	var port int
+	var implicitTLS bool
 	var credentials *Credentials.Credentials
 	var err error
 	var addr *Address.Addr
 
-	if host, port, path, credentials, err = getHostParsedUrl(hostURL); err == nil {
+	if host, port, path, credentials, implicitTLS, err = getHostParsedUrl(hostURL); err == nil {
 		if addr, err = getHostAddr(host, port, ipFamily); err == nil {
-			return buildRequester(addr, credentials, path)
+			var r *Requester
+
+			if implicitTLS && config == nil {
+				/* Implicit FTPS is not possible in clear text, use the default configuration */
+				config = &tls.Config{}
+			}
+
+			if config != nil {
+				config = buildTLSConfig(config, host)
+			}
+
+			if r, err = buildRequester(addr, credentials, path, config, implicitTLS, timeouts); err == nil {
+				/* Remember the host name for TLS certificate verification */
+				r.hostName = host
+				r.tlsConfig = config
+			}
+
+			return r, err
 		}
 	}
 
@@ -193,8 +491,9 @@ func preprocessRequesterBuild(hostURL string, ipFamily int) (*Requester, error)
 }
 
 /* Normalizes URL parsing */
-func getHostParsedUrl(hostURL string) (host string, port int, path string, credentials *Credentials.Credentials, err error) {
+func getHostParsedUrl(hostURL string) (host string, port int, path string, credentials *Credentials.Credentials, implicitTLS bool, err error) {
 	var URLData *url.URL
+	var defaultPort int = DefaultHostPort
 
 	/* Extract url parts */
 	URLData, err = url.Parse(hostURL)
@@ -209,11 +508,16 @@ func getHostParsedUrl(hostURL string) (host string, port int, path string, crede
 		return
 	}
 
+	/* ftps:// connections are secured from the start, on a different default port */
+	if implicitTLS = strings.ToLower(URLData.Scheme) == ImplicitTLSScheme; implicitTLS {
+		defaultPort = DefaultImplicitTLSPort
+	}
+
 	/* Extract port */
 	host, s, err := Net.SplitHostPort(URLData.Host)
 	if err != nil {
 		/* Fallback on default port - specified host does not specify a custom port */
-		port = DefaultHostPort
+		port = defaultPort
 		host = URLData.Host
 		err = nil /* Clear the error. */
 	} else {
@@ -221,7 +525,7 @@ func getHostParsedUrl(hostURL string) (host string, port int, path string, crede
 
 		if err != nil {
 			/* Invalid port specified. Fallback on default */
-			port = DefaultHostPort
+			port = defaultPort
 		}
 	}
 
@@ -271,13 +575,39 @@ func getHostAddr(host string, port int, ipFamily int) (*Address.Addr, error) {
 	return nil, ERR_UnableToLookupHost
 }
 
-/* Private Requester builder */
-func buildRequester(hostAddr *Address.Addr, credentials *Credentials.Credentials, navigateTo string) (requester *Requester, err error) {
+/* Builds the TLS configuration used by a requester, without altering the specified one. Sessions are shared between
+ connections (many servers require data connections to reuse the control connection's TLS session) */
+func buildTLSConfig(config *tls.Config, hostName string) *tls.Config {
+	config = config.Clone()
+
+	if config.ServerName == EmptyString {
+		config.ServerName = hostName
+	}
+
+	if config.ClientSessionCache == nil {
+		config.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	return config
+}
+
+/* Private Requester builder. Implicit TLS connections are secured before the server greeting */
+func buildRequester(hostAddr *Address.Addr, credentials *Credentials.Credentials, navigateTo string, config *tls.Config, implicitTLS bool, timeouts Timeouts) (requester *Requester, err error) {
 	var conn Net.Conn
 	var dir, file string
+	var dialer *Net.Dialer = &Net.Dialer{Timeout: max(timeouts.Dial, 0)}
+
+	if implicitTLS {
+		conn, err = tls.DialWithDialer(dialer, hostAddr.Network(), hostAddr.String(), config)
+	} else {
+		conn, err = dialer.Dial(hostAddr.Network(), hostAddr.String())
+	}
 
-	conn, err = Net.Dial(hostAddr.Network(), hostAddr.String())
 	if err != nil {
+		if isTimeout(err) {
+			err = fmt.Errorf(ERRF_CommandInterrupted, hostAddr.String(), ERR_Timeout)
+		}
+
 		return /* Return with the original Dial generated error. */
 	}
 
@@ -299,53 +629,162 @@ func buildRequester(hostAddr *Address.Addr, credentials *Credentials.Credentials
 	}
 
 	/* Instantiate the new Requester */
-	requester = &Requester{conn, Reader.NewReader(conn), nil, nil, hostAddr, nil, credentials, dir, file, true, false, Logger.NewNullLogger()}
+	requester = &Requester{
+		controlConnection:	conn,
+		controlReader:		Reader.NewReader(conn),
+		hostAddress:		hostAddr,
+		credentials:		credentials,
+		initDir:			dir,
+		initFile:			file,
+		connected:			true,
+		Logger:				Logger.NewNullLogger(),
+		tlsConfig:			config,
+		protectData:		implicitTLS,	/* Implicit FTPS protects the data connections from the start */
+		timeouts:			timeouts,
+		implicitTLS:		implicitTLS,
+	}
+
+	/* Wait for the server greeting, and check for server ready status */
+	welcomeMessage, err := requester.readResponse(timeouts.Greeting)
+	if err != nil {
+		conn.Close()
 
-	/* Grab server greeting, and check for server ready status */
-	welcomeMessage, _ := requester.getResponse()
-	if welcomeMessage != nil {
-		if Status.Ready == welcomeMessage.Status() {
-			/* Server ready */
-			requester.ready = true
+		if isTimeout(err) {
+			return nil, fmt.Errorf(ERRF_CommandInterrupted, hostAddr.String(), ERR_Timeout)
 		}
+
+		return nil, err
+	}
+
+	if Status.Ready == welcomeMessage.Status() {
+		/* Server ready */
+		requester.ready = true
 	}
 
 	return
 }
 
-/* Reads a response taking the data connection into account. */
-func (r *Requester) getResponse() (response *Response.Response, err error) {
+/* Blocks until a complete reply is read on the control connection. The reply timeout only starts once the data connection
+ (if any) is done, while the bound context and failing data connections interrupt the read right away */
+func (r *Requester) readResponse(timeout time.Duration) (response *Response.Response, err error) {
+	var replies chan controlReply = make(chan controlReply, 1)
 	var parser *Parser.Parser = Parser.NewParser()
-	var raw []byte = r.controlReader.Get()
+	var ctx context.Context = r.boundContext()
+	var dataDone <-chan struct{}
+	var expire <-chan time.Time
+	var timer *time.Timer
+	var reply controlReply
+
+	startTimer := func() {
+		if timeout > 0 {
+			timer = time.NewTimer(timeout)
+			expire = timer.C
+		}
+	}
+
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	go func() {
+		raw, err := r.controlReader.ReadReply()
+		replies <- controlReply{raw, err}
+	}()
+
+	if r.dataReader != nil {
+		/* The reply timeout does not count while data is flowing */
+		dataDone = r.dataReader.Done()
+	} else {
+		startTimer()
+	}
+
+	for waiting := true; waiting; {
+		select {
+		case reply = <-replies:
+			waiting = false
+		case <-ctx.Done():
+			reply, err = r.interruptRead(replies), ctx.Err()
+			waiting = false
+		case <-dataDone:
+			dataDone = nil
+
+			if err = r.dataReader.GetError(); err != nil {
+				/* The data connection failed (or went idle), the transfer reply is still pending */
+				reply = r.interruptRead(replies)
+				waiting = false
+			} else {
+				startTimer()
+			}
+		case <-expire:
+			reply = r.interruptRead(replies)
+			waiting = false
+		}
+	}
+
+	if reply.raw != nil {
+		r.recordReply(reply.raw)
+	}
+
+	if reply.err != nil {
+		if err == nil {
+			/* Expired reply timeout or broken connection. The control connection state is unknown from now on */
+			r.ready = false
+			err = reply.err
+		}
+
+		return nil, err
+	}
 
 	/* Parse the read content */
-	parser.ParseBlock(raw)
+	parser.ParseBlock(reply.raw)
 
-	if parser.HasErrors() {
+	if response = parser.Get(); parser.HasErrors() || response == nil {
 		/* Debug point */
 		for _, err := range parser.Errors() {
 			r.Logger.Critical(err.Error())
 		}
 
-		err = ERR_ResponseParsingError
-	} else {
-		if parser.Length() > 1 {
-			/* Debug point. This will happen if multiple commands are executed before calling getResponse */
-			r.Logger.Critical("Accumulation of responses not consumed: " + string(raw))
+		return nil, ERR_ResponseParsingError
+	}
 
-			err = ERR_UnconsumedResponses
-		} else {
-			response = parser.Get()
-		}
+	if response.Status() / 100 != 1 && r.pendingReplies > 0 {
+		/* Final reply of a sent command */
+		r.pendingReplies -= 1
 	}
 
+	return response, nil
+}
+
+/* Records a reply read on the control connection. The first reply is always the server greeting, kept for recorders
+ attached later on (parsing alters the raw reply, keep a copy) */
+func (r *Requester) recordReply(raw []byte) {
+	if r.greeting == nil {
+		r.greeting = append([]byte{}, raw...)
+	} else if r.recorder != nil {
+		r.recorder.Reply(raw)
+	}
+}
+
+/* Interrupts the pending control connection read, and returns it's outcome (replies read in the meantime are kept) */
+func (r *Requester) interruptRead(replies chan controlReply) (reply controlReply) {
+	r.controlConnection.SetReadDeadline(time.Now())
+	reply = <-replies
+	r.controlConnection.SetReadDeadline(time.Time{})
+
 	return
 }
 
 /* Initializes a new data connection based on the current dataAddress value */
 func (r *Requester) establishDataConnection() (conn Net.Conn, err error) {
 	if r.dataAddress != nil {
-		conn, err = Net.Dial(r.dataAddress.Network(), r.dataAddress.String())
+		var dialer *Net.Dialer = &Net.Dialer{Timeout: max(r.timeouts.Dial, 0)}
+
+		if conn, err = dialer.DialContext(r.boundContext(), r.dataAddress.Network(), r.dataAddress.String()); err == nil {
+			conn = r.protect(conn)
+		}
+
 		return
 	} else {
 		err = ERR_InvalidDataAddress
@@ -354,68 +793,305 @@ func (r *Requester) establishDataConnection() (conn Net.Conn, err error) {
 	return nil, ERR_CanNotEstablishDataConn
 }
 
-/* Start listening for incoming data in the data channel */
-func (r *Requester) listenDataChannel() (ok bool, err error) {
-	if r.dataReader != nil {
-		/* Stop the old reader from reading, and instantiate a new one for the current data connection. */
-		r.dataReader.StopReading()
-		r.dataConnection.Close()
+/* Accepts the server's data connection on the active mode listener, up to the dial timeout or until the bound context is done */
+func (r *Requester) acceptDataConnection() (conn Net.Conn, err error) {
+	listener, ok := r.dataListener.(*Net.TCPListener)
+	if !ok {
+		return nil, ERR_CanNotEstablishDataConn
+	}
+
+	listener.SetDeadline(deadlineAfter(r.timeouts.Dial))
+	stop := context.AfterFunc(r.boundContext(), func() {
+		listener.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	for {
+		if conn, err = listener.Accept(); err != nil {
+			if isTimeout(err) {
+				/* Reported as a timeout (or as the bound context error) by commandError */
+				return nil, err
+			}
+
+			return nil, ERR_NoDataConnection
+		}
+
+		if r.isServerPeer(conn) {
+			return r.protect(conn), nil
+		}
+
+		/* Data connections from other hosts are refused, the server may still connect (RFC 2577) */
+		r.Logger.Warning("Data connection refused from " + conn.RemoteAddr().String())
+		conn.Close()
+	}
+}
+
+/* Checks if the specified connection comes from the server's host */
+func (r *Requester) isServerPeer(conn Net.Conn) bool {
+	server, ok := r.controlConnection.RemoteAddr().(*Net.TCPAddr)
+	if !ok {
+		return true
+	}
+
+	peer, ok := conn.RemoteAddr().(*Net.TCPAddr)
+	return ok && peer.IP.Equal(server.IP)
+}
+
+/* Wraps the specified data connection in TLS if data protection is enabled. The client always acts as the TLS client */
+func (r *Requester) protect(conn Net.Conn) Net.Conn {
+	if r.protectData && r.tlsConfig != nil {
+		return tls.Client(conn, r.tlsConfig)
+	}
+
+	return conn
+}
+
+/* Returns the bound context, or the background context if none is bound */
+func (r *Requester) boundContext() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+
+	return context.Background()
+}
+
+/* Returns the bound context error wrapped with the command name, once the context is done */
+func (r *Requester) contextError(command *Command.Command) error {
+	if err := r.boundContext().Err(); err != nil {
+		return fmt.Errorf(ERRF_CommandInterrupted, command.Name(), err)
+	}
+
+	return nil
+}
+
+/* Reports connection errors caused by the bound context or an expired deadline as such, wrapped with the command name */
+func (r *Requester) commandError(command *Command.Command, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if ctxErr := r.contextError(command); ctxErr != nil {
+		return ctxErr
+	}
+
+	if isTimeout(err) {
+		return fmt.Errorf(ERRF_CommandInterrupted, command.Name(), ERR_Timeout)
+	}
+
+	return err
+}
+
+/* Checks if the bound context or a timeout ended the specified command, making sure the context error is the last one reported */
+func (r *Requester) interrupted(command *Command.Command) bool {
+	if err := r.contextError(command); err != nil {
+		if !errors.Is(command.LastError(), r.ctx.Err()) {
+			command.AddError(err)
+		}
+
+		return true
 	}
 
-	r.dataConnection, err = r.establishDataConnection()
+	return errors.Is(command.LastError(), ERR_Timeout)
+}
+
+/* Wraps the data connection applying the idle timeout and the bound context deadline. The connection is interrupted as
+ soon as the context is done */
+func (r *Requester) watchDataConnection(conn Net.Conn) (Net.Conn, func() bool) {
+	var watched *dataConn = &dataConn{Conn: conn, idle: r.timeouts.DataIdle}
+	var ctx context.Context = r.boundContext()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		watched.deadline = deadline
+	}
+
+	watched.extend()
+	return watched, context.AfterFunc(ctx, watched.interrupt)
+}
+
+/* Aborts the interrupted transfer (ABOR) and consumes the server replies still pending (usually 426 followed by 226). The
+ control connection state is unknown if the server does not reply in time */
+func (r *Requester) abort() {
+	var command *Command.Command = Command.NewCommand("abor", EmptyString, []int{Status.DataConnectionOpen, Status.DataConnectionClose})
+
+	/* The bound context is already done */
+	ctx := r.ctx
+	r.ctx = nil
+	defer func() {
+		r.ctx = ctx
+	}()
+
+	r.closeDataChannel(false)
+
+	if !r.ready {
+		/* The server stopped replying on the control connection */
+		return
+	}
+
+	/* Send ABOR right away, without waiting for the interrupted transfer reply */
+	r.Logger.Information("Executing: " + command.String())
+	if _, err := r.write(command); err != nil {
+		r.ready = false
+		return
+	}
+
+	for deadline := time.Now().Add(AbortTimeout); r.pendingReplies > 0; {
+		if _, err := r.readResponse(time.Until(deadline)); err != nil {
+			r.ready = false
+			return
+		}
+	}
+
+	r.Logger.Information(command.Name() + " successfull.")
+}
+
+/* Start listening for incoming data in the data channel, streaming all reads to the specified writer (or collecting them
+ in memory if no writer is specified) */
+func (r *Requester) listenDataChannel(w io.Writer) (ok bool, err error) {
+	var conn Net.Conn
+
+	/* Drop any previous data connection */
+	r.closeDataChannel(false)
+
+	if r.InActiveMode() {
+		conn, err = r.acceptDataConnection()
+	} else {
+		conn, err = r.establishDataConnection()
+	}
 
 	if err == nil {
-		r.dataReader = Reader.NewReader(r.dataConnection)
+		var source io.Reader
+
+		r.dataConnection, r.stopDataWatch = r.watchDataConnection(conn)
+		if source = r.dataConnection; r.recorder != nil {
+			source = r.recorder.Reader(source)
+		}
+
+		r.dataReader = Reader.NewDataReader(source, w)
 		ok = true
 	}
 
 	return ok, err
 }
 
-/* Close the data channel and return all collected data */
-func (r *Requester) closeDataChannel() (ok bool, data []byte) {
+/* Close the data channel and return all collected data. Completed transfers are read up to the end of the data stream,
+ while incomplete ones are dropped */
+func (r *Requester) closeDataChannel(completed bool) (data []byte, err error) {
 	if r.dataReader != nil {
-		data = r.dataReader.Get()
-		if r.dataReader.IsActive() {
-			r.dataReader.StopReading()
+		if !completed {
+			r.dataConnection.Close()
 		}
+
+		err = r.dataReader.Wait()
+		data = r.dataReader.Get()
+
+		r.dataConnection.Close()
 		r.dataReader = nil
 		r.dataConnection = nil
-		ok = true
 	}
 
-	return ok, data
+	if r.stopDataWatch != nil {
+		r.stopDataWatch()
+		r.stopDataWatch = nil
+	}
+
+	return data, err
 }
 
-/* Makes requests to the server based on provided Command contents */
+/* Makes requests to the server based on provided Command contents. Replies left pending by previous commands (interrupted
+ by the bound context) are consumed first */
 func (r *Requester) request(command *Command.Command) (bool, error) {
+	for r.pendingReplies > 0 {
+		if _, err := r.readResponse(AbortTimeout); err != nil {
+			return false, err
+		}
+	}
+
+	return r.write(command)
+}
+
+/* Writes the specified command on the control connection, within the bound context deadline */
+func (r *Requester) write(command *Command.Command) (bool, error) {
 	var EOL []byte = []byte("\r\n")
 
-	/* Send the request to the server */
+	if deadline, ok := r.boundContext().Deadline(); ok {
+		r.controlConnection.SetWriteDeadline(deadline)
+	} else {
+		r.controlConnection.SetWriteDeadline(time.Time{})
+	}
+
 	n, err := r.controlConnection.Write(append(command.Byte(), EOL...))
+	if n > 0 {
+		r.pendingReplies += 1
+
+		if r.recorder != nil {
+			r.recorder.Command(command.String())
+		}
+	}
+
 	return n > 0, err
 }
 
 /* Executes the specified command listening on the data connection. */
 func (r *Requester) executeDataCommand(command *Command.Command, w io.Writer) (*Command.Command, []byte) {
+	var response *Response.Response
+	var data []byte
 	var err error
 	r.Logger.Information("Executing: " + command.String())
 
-	/* Listen for incoming data on the data connection (if required) */
-	_, err = r.listenDataChannel()
-
-	if err != nil {
+	if err = r.contextError(command); err != nil {
 		command.AddError(err)
 		return command, []byte{}
 	}
 
-	/* If required, pipe the data connection readings to the specified writer  */
-	if w != nil {
-		r.dataReader.AttachDestination(w)
+	if r.InActiveMode() {
+		/* Active mode: the server connects to the local listener once it accepted the command */
+		if !r.IsReady() {
+			command.AddError(ERR_ServerNotReady)
+			return command, []byte{}
+		}
+
+		if _, err = r.request(command); err != nil {
+			command.AddError(r.commandError(command, err))
+			return command, []byte{}
+		}
+
+		if response, err = r.awaitResponse(command); err != nil {
+			command.AddError(err)
+			command.AttachResponse(&Response.Response{}, nil)
+		} else {
+			command.AttachResponse(response, nil)
+
+			if response.Status() / 100 == 1 {
+				if _, err = r.listenDataChannel(w); err != nil {
+					command.AddError(r.commandError(command, err))
+				}
+			}
+
+			if command.Success() {
+				/* Wait for the completion reply. The data connection can not be accepted again on transient failures */
+				r.evaluate(command, response.Status(), false, 0)
+			}
+		}
+	} else {
+		/* Passive mode: connect to the server before sending the command, and stream the data connection readings */
+		if _, err = r.listenDataChannel(w); err != nil {
+			command.AddError(r.commandError(command, err))
+			return command, []byte{}
+		}
+
+		r.execute(command, false, true, CommandRetries)
 	}
 
-	r.execute(command, false, true, CommandRetries)
-	_, data := r.closeDataChannel()
+	if !command.Success() && r.interrupted(command) {
+		/* Cancelled mid-stream */
+		r.abort()
+		return command, []byte{}
+	}
+
+	if data, err = r.closeDataChannel(command.Success()); err != nil && command.Success() {
+		/* The transfer completed on the server side, but the data could not be read or stored */
+		command.AddError(r.commandError(command, err))
+	}
 
 	if command.Success() {
 		r.Logger.Information(command.Name() + " successfull.")
@@ -426,10 +1102,124 @@ func (r *Requester) executeDataCommand(command *Command.Command, w io.Writer) (*
 	return command, data
 }
 
+/* Executes the specified command writing the source contents on the data connection. */
+func (r *Requester) executeUploadCommand(command *Command.Command, source io.Reader) *Command.Command {
+	var conn Net.Conn
+	var response *Response.Response
+	var err error
+	r.Logger.Information("Executing: " + command.String())
+
+	if !r.IsReady() {
+		/* Do not make requests on closed connections */
+		command.AddError(ERR_ServerNotReady)
+		return command
+	}
+
+	if err = r.contextError(command); err != nil {
+		command.AddError(err)
+		return command
+	}
+
+	/* Passive mode: connect to the server before sending the command */
+	if !r.InActiveMode() {
+		if conn, err = r.establishDataConnection(); err != nil {
+			command.AddError(r.commandError(command, err))
+			return command
+		}
+	}
+
+	/* Send the command and wait for the server to accept the transfer */
+	if _, err = r.request(command); err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		command.AddError(r.commandError(command, err))
+		return command
+	}
+
+	response, err = r.awaitResponse(command)
+	command.AttachResponse(response, err)
+
+	/* Active mode: the server connects to the local listener once it accepted the transfer */
+	if r.InActiveMode() && response != nil && response.Status() / 100 == 1 {
+		if conn, err = r.acceptDataConnection(); err != nil {
+			command.AddError(r.commandError(command, err))
+		}
+	}
+
+	if conn != nil && response != nil && response.Status() / 100 == 1 {
+		var stop func() bool
+
+		secure, isSecure := conn.(*tls.Conn)
+		conn, stop = r.watchDataConnection(conn)
+		defer stop()
+
+		/* Transfer accepted. Push the source contents, and mark the end of data by closing the data connection */
+		if isSecure {
+			/* Empty sources would otherwise never start the TLS handshake */
+			err = secure.Handshake()
+		}
+
+		if err == nil {
+			var destination io.Writer = conn
+
+			if r.recorder != nil {
+				destination = r.recorder.Writer(conn)
+			}
+
+			_, err = io.Copy(destination, source)
+		}
+
+		if err != nil {
+			command.AddError(r.commandError(command, err))
+		}
+		conn.Close()
+
+		/* Wait for the transfer completion reply */
+		r.execute(command, false, false, 0)
+	} else {
+		if conn != nil {
+			conn.Close()
+		}
+
+		if response == nil {
+			command.AddError(ERR_NoServerResponse)
+			command.AttachResponse(&Response.Response{}, nil)
+		} else {
+			r.evaluate(command, response.Status(), false, 0)
+		}
+	}
+
+	if !command.Success() && r.interrupted(command) {
+		/* Cancelled mid-stream */
+		r.abort()
+		return command
+	}
+
+	if command.Success() {
+		r.Logger.Information(command.Name() + " successfull.")
+	} else {
+		r.Logger.Information(command.Name() + " failed." + command.LastError().Error())
+	}
+
+	return command
+}
+
+/* Blocks until the server sends a response on the control connection, the reply timeout expires, or the bound context is
+ done. Errors are reported with the command name */
+func (r *Requester) awaitResponse(command *Command.Command) (*Response.Response, error) {
+	response, err := r.readResponse(r.timeouts.Reply)
+	if err != nil {
+		return nil, r.commandError(command, err)
+	}
+
+	return response, nil
+}
+
 /* Executes a command (wrapper around request, takes care of response reading, error handling, and is status aware) */
 func (r *Requester) execute(command *Command.Command, isSequence bool, execute bool, leftRetries int) (*Command.Command) {
+	var response *Response.Response
 	var err error
-	var status int = -1
 
 	if command.Name() == Commands.UnknownCommand {
 		command.AddError(Commands.ERR_InvalidCommandName)
@@ -443,71 +1233,52 @@ func (r *Requester) execute(command *Command.Command, isSequence bool, execute b
 
 	/* Execute the command */
 	if execute {
-		_, err = r.request(command)
-		execute = false
-
-		/* Error communicating to server */
-		if err != nil {
+		if err = r.contextError(command); err != nil {
+			/* Do not start new requests once the bound context is done */
 			command.AddError(err)
 			return command
-		} else {
-			/* Get the server response */
-			command.AttachResponse(r.getResponse())
 		}
-	} else {
-		/* Block until the server responds or the data connection closes */
-		for {
-			if !r.dataReader.IsActive() {
-				/* Break the waiting if the data reader is closed. Mark a status of DataConnectionClose */
-				if command.IsExpectedStatus(Status.DataConnectionClose) {
-					status = Status.DataConnectionClose
-					r.dataConnection.Close() /* Destroy data connection descriptor at this point */
-					break
-				}
-			}
-
-			/* Keep searching for content up to the pipe dead timeout. */
-			v := r.controlReader.Peek()
 
-			if len(v) > 0 {
-				break
-			} else {
-				time.Sleep(200 * time.Millisecond)
-			}
+		/* Error communicating to server */
+		if _, err = r.request(command); err != nil {
+			command.AddError(r.commandError(command, err))
+			return command
 		}
-
-		/* Get the server response */
-		command.AttachResponse(r.getResponse())
 	}
 
-	if command.Response() == nil && status == -1{
-		/* Empty server response */
-		command.AddError(ERR_NoServerResponse)
+	/* Block until the server responds. The reply timeout does not count while data is flowing */
+	if response, err = r.awaitResponse(command); err != nil {
+		command.AddError(err)
 
 		/* Attach an empty response to the command to ensure interface chaining capabilities. */
 		command.AttachResponse(&Response.Response{}, nil)
 
 		return command
-	} else if status == -1 {
-		/* Check response status to determine the execution completion */
-		status = command.Response().Status()
 	}
 
+	command.AttachResponse(response, nil)
+
+	/* Check response status to determine the execution completion */
+	return r.evaluate(command, response.Status(), isSequence, leftRetries)
+}
+
+/* Evaluates the specified reply status, deciding the command's completion state or next action */
+func (r *Requester) evaluate(command *Command.Command, status int, isSequence bool, leftRetries int) (*Command.Command) {
 	/* Check relay status for next action */
 	first := status / 100
 	//	second := (status / 10) % 10
 
 	if first == 1 {
 		/* Positive Preliminary reply - wait for a new response */
-		return r.execute(command, isSequence, execute, leftRetries)
+		return r.execute(command, isSequence, false, leftRetries)
 	} else if first == 2 {
 		/* Positive Completion reply - action completed successfully, no matter of the expected status */
 		if !command.IsExpectedStatus(status) {
 			command.AddError(fmt.Errorf(ERRF_InvalidCompletionStatus, command.Name(), command.ExpectedStatus(), status, command.Response().Message()))
 		}
 	} else if first == 3 {
-		/* Positive Intermediate reply - sequence of commands mandatory */
-		if !isSequence {
+		/* Positive Intermediate reply - sequence of commands mandatory, unless the command expects it (REST) */
+		if !isSequence && !command.IsExpectedStatus(status) {
 			/* Error: Invalid single command. Use a sequence */
 			command.AddError(fmt.Errorf(ERRF_InvalidCommandOutOfSequence, command.Name(), status, command.Response().Message()))
 		}
@@ -566,3 +1337,90 @@ func (r *Requester) sequence(commands []*Command.Command) (ok bool, last *Comman
 
 	return last.Success(), last
 }
+
+/* Fills in the default values for the unspecified timeouts */
+func withDefaultTimeouts(timeouts []Timeouts) (t Timeouts) {
+	if len(timeouts) > 0 {
+		t = timeouts[0]
+	}
+
+	if t.Dial == 0 {
+		t.Dial = DefaultDialTimeout
+	}
+
+	if t.Greeting == 0 {
+		t.Greeting = DefaultGreetingTimeout
+	}
+
+	if t.Reply == 0 {
+		t.Reply = DefaultReplyTimeout
+	}
+
+	if t.DataIdle == 0 {
+		t.DataIdle = DefaultDataIdleTimeout
+	}
+
+	return
+}
+
+/* Computes the deadline of the specified timeout. Disabled timeouts have no deadline (zero time) */
+func deadlineAfter(timeout time.Duration) time.Time {
+	if timeout > 0 {
+		return time.Now().Add(timeout)
+	}
+
+	return time.Time{}
+}
+
+/* Checks if the specified error is caused by an expired deadline */
+func isTimeout(err error) bool {
+	var netErr Net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+/* io.Reader interface implementation */
+func (c *dataConn) Read(p []byte) (int, error) {
+	if err := c.extend(); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Read(p)
+}
+
+/* io.Writer interface implementation */
+func (c *dataConn) Write(p []byte) (int, error) {
+	if err := c.extend(); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Write(p)
+}
+
+/* Pushes the connection deadline forward by the idle timeout */
+func (c *dataConn) extend() error {
+	var deadline time.Time
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.interrupted {
+		return os.ErrDeadlineExceeded
+	}
+
+	if deadline = c.deadline; c.idle > 0 {
+		if idle := time.Now().Add(c.idle); deadline.IsZero() || idle.Before(deadline) {
+			deadline = idle
+		}
+	}
+
+	return c.Conn.SetDeadline(deadline)
+}
+
+/* Fails all pending and following reads and writes */
+func (c *dataConn) interrupt() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.interrupted = true
+	c.Conn.SetDeadline(time.Now())
+}
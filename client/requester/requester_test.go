@@ -1 +1,325 @@
 package requester
+
+import (
+	Command "github.com/ghepesdoru/bookwormFTP/client/command"
+	TestServer "github.com/ghepesdoru/bookwormFTP/client/testserver"
+	Logger "github.com/ghepesdoru/bookwormFTP/core/logger"
+	Reader "github.com/ghepesdoru/bookwormFTP/core/reader"
+	Net "net"
+	"context"
+	"crypto/tls"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* Builds a requester with a loopback control connection */
+func loopbackRequester(t *testing.T) (*Requester, func()) {
+	l, err := Net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Unable to open a loopback listener.", err)
+	}
+
+	conn, err := Net.Dial("tcp4", l.Addr().String())
+	if err != nil {
+		l.Close()
+		t.Fatal("Unable to connect to the loopback listener.", err)
+	}
+
+	r := &Requester{controlConnection: conn}
+	return r, func() {
+		r.CloseDataListener()
+		conn.Close()
+		l.Close()
+	}
+}
+
+func TestActivePortRange(t *testing.T) {
+	r := &Requester{}
+
+	for _, invalid := range [][2]int{{-1, 10}, {10, 5}, {0, 10}, {1024, 70000}} {
+		if r.SetActivePortRange(invalid[0], invalid[1]) != ERR_InvalidPortRange {
+			t.Fatal("Invalid port range accepted.", invalid)
+		}
+	}
+
+	if err := r.SetActivePortRange(40000, 40010); err != nil {
+		t.Fatal("Valid port range rejected.", err)
+	}
+
+	if err := r.SetActivePortRange(0, 0); err != nil {
+		t.Fatal("Unrestricted port range rejected.", err)
+	}
+}
+
+func TestListenDataPort(t *testing.T) {
+	r, closeAll := loopbackRequester(t)
+	defer closeAll()
+
+	addr, err := r.ListenDataPort(0)
+	if err != nil {
+		t.Fatal("Unable to open a data listener.", err)
+	}
+
+	if !r.InActiveMode() || addr.Port == 0 || addr.IP.String() != "127.0.0.1" {
+		t.Fatal("Invalid active mode data address.", addr)
+	}
+
+	/* The advertised address replaces the local one, the port is kept */
+	if err = r.SetAdvertisedAddr("not an ip"); err != ERR_InvalidAdvertisedAddr {
+		t.Fatal("Invalid advertised address accepted.", err)
+	}
+
+	r.SetAdvertisedAddr("203.0.113.7")
+	if addr, err = r.ListenDataPort(0); err != nil || addr.IP.String() != "203.0.113.7" || addr.ToPortSpecifier() == "" {
+		t.Fatal("Advertised address not used.", addr, err)
+	}
+
+	/* Switching to passive mode closes the listener */
+	r.RegisterDataAddr(addr)
+	if r.InActiveMode() {
+		t.Fatal("Data listener still open in passive mode.")
+	}
+}
+
+func TestListenDataPortRange(t *testing.T) {
+	r, closeAll := loopbackRequester(t)
+	defer closeAll()
+
+	/* Occupy the first port of the range */
+	busy, err := Net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Unable to open a loopback listener.", err)
+	}
+	defer busy.Close()
+
+	first := busy.Addr().(*Net.TCPAddr).Port
+	r.SetActivePortRange(first, first + 20)
+
+	addr, err := r.ListenDataPort(0)
+	if err != nil {
+		t.Fatal("Unable to open a data listener in the port range.", err)
+	}
+
+	if addr.Port <= first || addr.Port > first + 20 {
+		t.Fatal("Data listener port out of the configured range.", addr.Port)
+	}
+}
+
+func TestDataConnectionPeer(t *testing.T) {
+	r, closeAll := loopbackRequester(t)
+	defer closeAll()
+
+	r.Logger = Logger.NewNullLogger()
+	r.timeouts.Dial = 2 * time.Second
+
+	addr, err := r.ListenDataPort(0)
+	if err != nil {
+		t.Fatal("Unable to open a data listener.", err)
+	}
+
+	/* Another loopback address stands for a foreign host */
+	dialer := &Net.Dialer{LocalAddr: &Net.TCPAddr{IP: Net.ParseIP("127.0.0.2")}}
+	foreign, err := dialer.Dial("tcp4", addr.String())
+	if err != nil {
+		t.Skip("Secondary loopback address not available.", err)
+	}
+	defer foreign.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if conn, err := Net.Dial("tcp4", addr.String()); err == nil {
+			defer conn.Close()
+			conn.Write([]byte("data"))
+			time.Sleep(time.Second)
+		}
+	}()
+
+	conn, err := r.acceptDataConnection()
+	if err != nil {
+		t.Fatal("Server data connection not accepted.", err)
+	}
+	defer conn.Close()
+
+	if conn.RemoteAddr().(*Net.TCPAddr).IP.String() != "127.0.0.1" {
+		t.Fatal("Data connection accepted from a foreign host.", conn.RemoteAddr())
+	}
+
+	/* The foreign connection got closed */
+	foreign.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = foreign.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+		t.Fatal("Foreign data connection kept open.", err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	r := &Requester{hostName: "ftp.example.com"}
+	config := &tls.Config{}
+
+	r.SetTLSConfig(config)
+	if r.tlsConfig == config || config.ServerName != "" || config.ClientSessionCache != nil {
+		t.Fatal("The specified TLS configuration was modified.")
+	}
+
+	if r.tlsConfig.ServerName != "ftp.example.com" {
+		t.Fatal("Invalid TLS server name.", r.tlsConfig.ServerName)
+	}
+
+	/* Data connections reuse the control connection's TLS session */
+	if r.tlsConfig.ClientSessionCache == nil {
+		t.Fatal("No TLS session cache available.")
+	}
+
+	r.SetTLSConfig(&tls.Config{ServerName: "pinned.example.com"})
+	if r.tlsConfig.ServerName != "pinned.example.com" {
+		t.Fatal("The specified TLS server name was replaced.", r.tlsConfig.ServerName)
+	}
+}
+
+func TestBindContext(t *testing.T) {
+	r, closeAll := loopbackRequester(t)
+	defer closeAll()
+
+	r.controlReader = Reader.NewReader(r.controlConnection)
+	r.Logger = Logger.NewNullLogger()
+	r.ready = true
+
+	/* Cancelled contexts do not start new requests */
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	release := r.BindContext(ctx)
+	command := r.Request(Command.NewCommand("noop", "", []int{200}))
+	release()
+
+	if err := command.LastError(); !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), "NOOP") {
+		t.Fatal("Invalid cancelled request error.", err)
+	}
+
+	/* The server never replies, stop waiting at the context deadline */
+	ctx, cancel = context.WithTimeout(context.Background(), 100 * time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	release = r.BindContext(ctx)
+	command = r.Request(Command.NewCommand("noop", "", []int{200}))
+	release()
+
+	if err := command.LastError(); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 2 * time.Second {
+		t.Fatal("Invalid request deadline.", err, time.Since(start))
+	}
+
+	if r.ctx != nil {
+		t.Fatal("Context still bound after release.")
+	}
+}
+
+func TestImplicitTLSUrl(t *testing.T) {
+	for url, expected := range map[string]int{"ftp.example.com": DefaultHostPort, "ftp://ftp.example.com/pub": DefaultHostPort, "ftps://ftp.example.com/pub": DefaultImplicitTLSPort, "FTPS://ftp.example.com:2990": 2990} {
+		host, port, _, _, implicitTLS, err := getHostParsedUrl(url)
+		if err != nil || host != "ftp.example.com" || port != expected {
+			t.Fatal("Invalid host or port.", url, host, port, err)
+		}
+
+		if implicitTLS != (port != DefaultHostPort) {
+			t.Fatal("Invalid implicit TLS detection.", url, implicitTLS)
+		}
+	}
+}
+
+func TestDefaultTimeouts(t *testing.T) {
+	if timeouts := withDefaultTimeouts(nil); timeouts.Dial != DefaultDialTimeout || timeouts.Greeting != DefaultGreetingTimeout || timeouts.Reply != DefaultReplyTimeout || timeouts.DataIdle != DefaultDataIdleTimeout {
+		t.Fatal("Invalid default timeouts.", timeouts)
+	}
+
+	timeouts := withDefaultTimeouts([]Timeouts{{Dial: -1, Reply: 5 * time.Second}})
+	if timeouts.Dial != -1 || timeouts.Reply != 5 * time.Second || timeouts.Greeting != DefaultGreetingTimeout {
+		t.Fatal("Specified timeouts not kept.", timeouts)
+	}
+
+	if !deadlineAfter(timeouts.Dial).IsZero() {
+		t.Fatal("Deadline set for a disabled timeout.")
+	}
+}
+
+func TestReplyTimeout(t *testing.T) {
+	r, closeAll := loopbackRequester(t)
+	defer closeAll()
+
+	r.controlReader = Reader.NewReader(r.controlConnection)
+	r.Logger = Logger.NewNullLogger()
+	r.timeouts.Reply = 300 * time.Millisecond
+	r.ready = true
+
+	/* The server never replies */
+	command := r.Request(Command.NewCommand("noop", "", []int{200}))
+	if err := command.LastError(); !errors.Is(err, ERR_Timeout) || !strings.HasPrefix(err.Error(), "NOOP") {
+		t.Fatal("Invalid reply timeout error.", err)
+	}
+
+	if r.IsReady() {
+		t.Fatal("Requester still ready after a reply timeout.")
+	}
+}
+
+func TestDataConnIdle(t *testing.T) {
+	local, remote := Net.Pipe()
+	defer remote.Close()
+
+	conn := &dataConn{Conn: local, idle: 100 * time.Millisecond}
+	go remote.Write([]byte("data"))
+
+	/* Reads extend the deadline, idle connections time out */
+	if n, err := conn.Read(make([]byte, 4)); n != 4 || err != nil {
+		t.Fatal("Unable to read from the data connection.", n, err)
+	}
+
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 4)); !isTimeout(err) || time.Since(start) > time.Second {
+		t.Fatal("Idle data connection did not time out.", err)
+	}
+
+	/* Interrupted connections fail right away */
+	conn.interrupt()
+	if _, err := conn.Write([]byte("data")); !isTimeout(err) {
+		t.Fatal("Interrupted data connection still writable.", err)
+	}
+}
+
+func TestScriptedReplies(t *testing.T) {
+	server, err := TestServer.NewServer()
+	if err != nil {
+		t.Fatal("Unable to start the test server.", err)
+	}
+	defer server.Close()
+
+	server.Greeting("220-Welcome.\r\n220 Ready.")
+	server.Reply("HELP", "214-The following commands are recognized:\r\n USER PASS QUIT\r\n214 Help OK.")
+	server.Reply("CWD", "450 Directory busy.")
+	server.Reply("CWD", "250 Directory changed.")
+
+	r, err := NewRequester(server.URL("user", "secret", ""))
+	if err != nil || !r.IsReady() {
+		t.Fatal("Multiple lines greeting not accepted.", err)
+	}
+
+	if command := r.Request(Command.NewCommand("help", "", []int{214})); !command.Success() || !strings.Contains(command.Response().Message(), "USER PASS QUIT") {
+		t.Fatal("Invalid multiple lines reply.", command.LastError())
+	}
+
+	/* Transient failures are retried */
+	if command := r.Request(Command.NewCommand("cwd", "/pub", []int{250})); !command.Success() {
+		t.Fatal("Transient failure not retried.", command.LastError())
+	}
+
+	/* Transfers without a data address fail before anything is sent */
+	if command, _ := r.RequestData(Command.NewCommand("list", "", []int{226})); command.Success() {
+		t.Fatal("Transfer succeeded without a data connection.")
+	}
+
+	if commands := server.Commands(); !reflect.DeepEqual(commands, []string{"HELP", "CWD /pub", "CWD /pub"}) {
+		t.Fatal("Invalid commands sequence.", commands)
+	}
+}
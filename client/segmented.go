@@ -0,0 +1,187 @@
+package client
+
+import (
+	ClientCommands "github.com/ghepesdoru/bookwormFTP/client/commands"
+	Requester "github.com/ghepesdoru/bookwormFTP/client/requester"
+	FilePath "path/filepath"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+/* Data transfer progress: bytes read from the data connections, and bytes written locally */
+type DataTransferStatus = Requester.DataTransferStatus
+
+var (
+	ERR_InvalidSegments		= fmt.Errorf("Invalid number of segments. Please specify at least one segment.")
+	ERRF_SegmentFailed		= "Download error: Unable to download %d bytes of %s at offset %d. Original error: %w"
+)
+
+/* Aggregated progress of a segmented download, shared by all of it's segments */
+type segmentedProgress struct {
+	status		DataTransferStatus
+	report		func(*DataTransferStatus)
+	mutex		sync.Mutex
+}
+
+/* Local file writer of a segment, updating the aggregated progress */
+type segmentWriter struct {
+	file		io.Writer
+	progress	*segmentedProgress
+}
+
+/* Downloads the specified remote file to the specified local path (relative to the client's local directory, or
+ absolute) using up to n parallel segments. Each segment is downloaded by a new session to the server (reusing the
+ client's credentials, features and data connection preferences) restarting the transfer at the segment's offset
+ (REST + RETR), and written at it's offset in the local file. Existing local files are overwritten. The aggregated
+ progress of all the segments is reported as the data arrives, when a progress function is specified */
+func (c *Client) DownloadSegmented(path string, local string, n int, progress ...func(*DataTransferStatus)) (ok bool, err error) {
+	var file *os.File
+	var size int64
+
+	if n < 1 {
+		return false, ERR_InvalidSegments
+	}
+
+	/* Check connection ready state before executing command */
+	if ok, err = c.isReady(); !ok {
+		return
+	}
+
+	path = c.remotePath(path)
+	if size, err = c.remoteSize(path); err != nil {
+		return false, err
+	}
+
+	/* Small files are not split below one byte per segment, and rounding the segment size up may require less segments
+	 (ex: 10 bytes in 9 segments are covered by 5 segments of 2 bytes). Empty files require none */
+	segment := max((size + int64(n) - 1) / int64(n), 1)
+	n = int((size + segment - 1) / segment)
+
+	if n > 1 && !c.features.SupportsParameter("REST", "STREAM") {
+		return false, ERR_RESTNotImplemented
+	}
+
+	if !FilePath.IsAbs(local) {
+		local = FilePath.Join(c.localFM.CurrentDir(), local)
+	}
+
+	if file, err = os.OpenFile(local, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, 0644); err != nil {
+		return false, fmt.Errorf("Download error: Unable to create local file %s. Original error: %w", local, err)
+	}
+	defer file.Close()
+
+	if err = file.Truncate(size); err != nil {
+		return false, fmt.Errorf("Download error: Unable to allocate local file %s. Original error: %w", local, err)
+	}
+
+	shared := &segmentedProgress{DataTransferStatus{}, nil, sync.Mutex{}}
+	if len(progress) > 0 {
+		shared.report = progress[0]
+	}
+
+	failures := make([]error, n)
+	var wait sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		offset := int64(i) * segment
+		length := min(segment, size - offset)
+
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			failures[i] = c.downloadSegment(path, &segmentWriter{io.NewOffsetWriter(file, offset), shared}, offset, length)
+		}(i)
+	}
+
+	wait.Wait()
+	if err = errors.Join(failures...); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+/* Downloads the specified segment of the remote file using a new session */
+func (c *Client) downloadSegment(path string, w io.Writer, offset int64, length int64) error {
+	var n int64
+
+	session, err := c.newSession()
+	if err == nil {
+		defer session.close()
+
+		if n, err = session.retrieveRange(path, offset, length, w); err == nil && n < length {
+			/* The remote file got shorter */
+			err = io.ErrUnexpectedEOF
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf(ERRF_SegmentFailed, length, path, offset, err)
+	}
+
+	return nil
+}
+
+/* Opens a new authenticated session to the same server, reusing the client's credentials, security, features, system
+ type and data connection preferences */
+func (c *Client) newSession() (session *Client, err error) {
+	var requester *Requester.Requester
+
+	if requester, err = c.requester.NewSession(); err != nil {
+		return nil, err
+	}
+
+	commands := ClientCommands.NewCommands()
+	if _, err = commands.AttachRequester(requester); err == nil {
+		session, err = fromRequester(commands, requester)
+	}
+
+	if err != nil {
+		requester.Close()
+		return nil, err
+	}
+
+	session.features = c.features.Copy()
+	session.settings.Get(OPT_System).Set(c.settings.Get(OPT_System).ToString())
+	session.UseActiveMode(c.settings.Get(OPT_ActiveMode).Is(true))
+
+	if c.settings.Get(OPT_Secure).Is(true) {
+		if !requester.IsSecure() {
+			/* Explicit FTPS, the requester already holds the TLS configuration */
+			if _, err = session.Commands.AUTH(ClientCommands.AUTH_TLS); err != nil {
+				requester.Close()
+				return nil, err
+			}
+		}
+
+		session.settings.Get(OPT_Secure).Set(true)
+	}
+
+	if _, err = session.LogIn(c.credentials); err != nil {
+		requester.Close()
+		return nil, err
+	}
+
+	return session, nil
+}
+
+/* Writes the segment data at it's offset, reporting the aggregated progress */
+func (w *segmentWriter) Write(p []byte) (n int, err error) {
+	n, err = w.file.Write(p)
+
+	w.progress.mutex.Lock()
+	defer w.progress.mutex.Unlock()
+
+	w.progress.status.Read += len(p)
+	w.progress.status.Written += n
+
+	if w.progress.report != nil {
+		status := w.progress.status
+		w.progress.report(&status)
+	}
+
+	return
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	ClientCommands "github.com/ghepesdoru/bookwormFTP/client/commands"
+	"context"
+	"io"
+	"sync"
+)
+
+/* Streamed transfer running in the background. Keeps the control connection busy until closed */
+type transfer struct {
+	client		*Client
+	cancel		context.CancelFunc
+	started		chan struct{}
+	done		chan struct{}
+	once		sync.Once
+	err			error
+}
+
+/* Remote file download (RETR) streamed through a pipe */
+type download struct {
+	transfer	*transfer
+	reader		*io.PipeReader
+	ended		bool
+	closed		bool
+}
+
+/* Remote file upload (STOR) streamed through a pipe */
+type upload struct {
+	transfer	*transfer
+	writer		*io.PipeWriter
+	closed		bool
+}
+
+/* Data connection writer marking the transfer as started on the first write */
+type startWriter struct {
+	transfer	*transfer
+	w			io.Writer
+}
+
+/* Data connection reader marking the transfer as started on the first read */
+type startReader struct {
+	transfer	*transfer
+	r			io.Reader
+}
+
+/* Opens the specified remote file for reading (in binary mode). The data connection is held open while reading, and
+ the client can not be used until the reader is closed. Close waits for the transfer completion reply, aborting (ABOR)
+ transfers closed before reading all the data */
+func (c *Client) Open(path string) (io.ReadCloser, error) {
+	d, err := c.retrieve(c.remotePath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+/* Creates (or overwrites) the specified remote file, writing it's contents in binary mode. The data connection is held
+ open while writing, and the client can not be used until the writer is closed. Close ends the data and waits for the
+ transfer completion reply */
+func (c *Client) Create(path string) (io.WriteCloser, error) {
+	u, err := c.store(c.remotePath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+/* Starts downloading the specified remote file. Fails if the server refuses the transfer */
+func (c *Client) retrieve(path string) (*download, error) {
+	reader, writer := io.Pipe()
+
+	t, err := c.startTransfer(func(ctx context.Context, t *transfer) error {
+		_, err := c.Commands.RETRContext(ctx, path, &startWriter{t, writer})
+		writer.CloseWithError(err)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &download{t, reader, false, false}, nil
+}
+
+/* Starts uploading the specified remote file. Fails if the server refuses the transfer */
+func (c *Client) store(path string) (*upload, error) {
+	reader, writer := io.Pipe()
+
+	t, err := c.startTransfer(func(ctx context.Context, t *transfer) error {
+		_, err := c.Commands.STORContext(ctx, path, &startReader{t, reader})
+
+		/* Fail the pending and future writes */
+		if err != nil {
+			reader.CloseWithError(err)
+		} else {
+			reader.CloseWithError(io.ErrClosedPipe)
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &upload{t, writer, false}, nil
+}
+
+/* Runs the specified transfer in the background, in binary mode, waiting for the first data or it's early end */
+func (c *Client) startTransfer(run func(ctx context.Context, t *transfer) error) (t *transfer, err error) {
+	var restore bool
+
+	/* Check connection ready state before executing command */
+	if ok, err := c.isReady(); !ok {
+		return nil, err
+	}
+
+	c.RepresentationType(ClientCommands.TYPE_Image, nil)
+	if !c.inDataMode() {
+		if _, err = c.dataMode(); err != nil {
+			c.RestoreConnections()
+			return nil, err
+		}
+
+		restore = true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t = &transfer{c, cancel, make(chan struct{}), make(chan struct{}), sync.Once{}, nil}
+	c.streaming = true
+
+	go func() {
+		t.err = run(ctx, t)
+
+		if restore {
+			c.RestoreConnections()
+		}
+
+		close(t.done)
+	}()
+
+	select {
+	case <-t.started:
+	case <-t.done:
+		if t.err != nil {
+			/* Refused, or failed before any data */
+			err = t.finish()
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+/* Marks the transfer as started */
+func (t *transfer) start() {
+	t.once.Do(func() {
+		close(t.started)
+	})
+}
+
+/* Waits for the transfer completion, releasing the client */
+func (t *transfer) finish() error {
+	<-t.done
+	t.cancel()
+	t.client.streaming = false
+
+	return t.err
+}
+
+/* Reads the downloaded data as it arrives */
+func (d *download) Read(p []byte) (n int, err error) {
+	if n, err = d.reader.Read(p); err != nil {
+		d.ended = true
+	}
+
+	return
+}
+
+/* Ends the download, waiting for the transfer completion reply. Downloads closed before reading all the data are
+ aborted */
+func (d *download) Close() (err error) {
+	if d.closed {
+		return nil
+	}
+
+	d.closed = true
+	d.reader.Close()
+
+	if !d.ended {
+		/* Not interested in the remaining data */
+		d.transfer.cancel()
+		d.transfer.finish()
+		return nil
+	}
+
+	return d.transfer.finish()
+}
+
+/* Sends the specified data to the server */
+func (u *upload) Write(p []byte) (int, error) {
+	return u.writer.Write(p)
+}
+
+/* Ends the uploaded data, waiting for the transfer completion reply */
+func (u *upload) Close() error {
+	if u.closed {
+		return nil
+	}
+
+	u.closed = true
+	u.writer.Close()
+
+	return u.transfer.finish()
+}
+
+func (w *startWriter) Write(p []byte) (int, error) {
+	w.transfer.start()
+	return w.w.Write(p)
+}
+
+func (r *startReader) Read(p []byte) (int, error) {
+	r.transfer.start()
+	return r.r.Read(p)
+}
@@ -0,0 +1,390 @@
+package testserver
+
+import (
+	Address "github.com/ghepesdoru/bookwormFTP/core/addr"
+	Net "net"
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	/* Generic constants */
+	DefaultGreeting		= "220 BookwormFTP test server ready."
+	DefaultReply		= "502 Command not implemented."
+	DefaultNetwork		= "tcp4"
+	LoopbackAddr		= "127.0.0.1:0"
+	DataTimeout			= 5 * time.Second
+	EmptyString			= ""
+	EOL					= "\r\n"
+)
+
+/* Error definitions */
+var (
+	ERR_NoDataConnection	= fmt.Errorf("No data connection. Please use PASV, EPSV, PORT or EPRT first.")
+)
+
+/* Commands receiving data from the client */
+var UploadCommands map[string]bool = map[string]bool {
+	"APPE": true, "STOR": true, "STOU": true,
+}
+
+/* Scripted answer to a command */
+type exchange struct {
+	replies		[]string	/* Data exchanges send the first reply before the transfer, and the rest after it */
+	payload		[]byte
+	isTransfer	bool
+}
+
+/* Fake FTP server replaying scripted replies on localhost. Every received command line is recorded, in order */
+type Server struct {
+	listener	Net.Listener
+	greeting	string
+	scripts		map[string][]*exchange
+	received	[]string
+	uploads		[][]byte
+	conns		map[Net.Conn]bool
+	wait		sync.WaitGroup
+	mutex		sync.Mutex
+}
+
+/* Control connection session state */
+type session struct {
+	server		*Server
+	conn		Net.Conn
+	passive		Net.Listener
+	data		chan Net.Conn
+	active		*Address.Addr
+}
+
+/* Instantiates a new Server listening on a localhost port */
+func NewServer() (*Server, error) {
+	listener, err := Net.Listen(DefaultNetwork, LoopbackAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener, DefaultGreeting, make(map[string][]*exchange), nil, nil, make(map[Net.Conn]bool), sync.WaitGroup{}, sync.Mutex{}}
+
+	s.wait.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+/* Listening address getter (host:port) */
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+/* Builds a client URL for the server, with the specified credentials and path */
+func (s *Server) URL(username string, password string, path string) string {
+	return "ftp://" + username + ":" + password + "@" + s.Addr() + "/" + strings.TrimPrefix(path, "/")
+}
+
+/* Replaces the greeting sent to new connections */
+func (s *Server) Greeting(reply string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.greeting = reply
+}
+
+/* Queues a control reply for the specified command (ex: "FEAT" -> "211-Features:\r\n MLST size*;\r\n211 End"). Queued
+ replies are consumed in order, the last one answers all the following commands */
+func (s *Server) Reply(command string, reply string) {
+	s.queue(command, &exchange{[]string{reply}, nil, false})
+}
+
+/* Queues a data transfer for the specified command. The preliminary reply is sent first, the payload is written on the
+ data connection (uploads are read and recorded instead), and the final reply (if not empty) is sent once the data
+ connection is closed */
+func (s *Server) Transfer(command string, preliminary string, payload []byte, final string) {
+	s.queue(command, &exchange{[]string{preliminary, final}, payload, true})
+}
+
+/* Received command lines getter, in the order they were received */
+func (s *Server) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string{}, s.received...)
+}
+
+/* Uploaded data getter, in the order it was received */
+func (s *Server) Uploads() [][]byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([][]byte{}, s.uploads...)
+}
+
+/* Stops listening and closes all the active connections */
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mutex.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.wait.Wait()
+	return err
+}
+
+/* Accepts connections until the server is closed */
+func (s *Server) serve() {
+	defer s.wait.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		s.conns[conn] = true
+		s.mutex.Unlock()
+
+		s.wait.Add(1)
+		go func() {
+			defer s.wait.Done()
+			(&session{s, conn, nil, nil, nil}).serve()
+		}()
+	}
+}
+
+/* Queues an exchange for the specified command */
+func (s *Server) queue(command string, e *exchange) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	command = strings.ToUpper(command)
+	s.scripts[command] = append(s.scripts[command], e)
+}
+
+/* Records the received command line, and gets the next scripted exchange for it (nil if none) */
+func (s *Server) next(line string, command string) *exchange {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.received = append(s.received, line)
+
+	queue := s.scripts[command]
+	if len(queue) == 0 {
+		return nil
+	}
+
+	if len(queue) > 1 {
+		s.scripts[command] = queue[1:]
+	}
+
+	return queue[0]
+}
+
+/* Records uploaded data */
+func (s *Server) upload(data []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.uploads = append(s.uploads, data)
+}
+
+/* Greets the client and answers it's commands until QUIT or a read failure */
+func (s *session) serve() {
+	defer s.closePassive()
+	defer func() {
+		s.server.mutex.Lock()
+		delete(s.server.conns, s.conn)
+		s.server.mutex.Unlock()
+
+		s.conn.Close()
+	}()
+
+	s.server.mutex.Lock()
+	greeting := s.server.greeting
+	s.server.mutex.Unlock()
+
+	s.reply(greeting)
+
+	reader := bufio.NewReader(s.conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, EOL)
+		name, param, _ := strings.Cut(line, " ")
+		name = strings.ToUpper(name)
+
+		if name == "PORT" || name == "EPRT" {
+			/* Active mode addresses are registered whether the reply is scripted or not */
+			s.registerActive(name, param)
+		}
+
+		if e := s.server.next(line, name); e != nil {
+			s.play(name, e)
+		} else if !s.builtin(name) {
+			s.reply(DefaultReply)
+		}
+
+		if name == "QUIT" {
+			return
+		}
+	}
+}
+
+/* Answers the unscripted commands the server knows about: PASV, EPSV, PORT, EPRT and QUIT */
+func (s *session) builtin(name string) bool {
+	switch name {
+	case "PASV", "EPSV":
+		addr, err := s.listenPassive()
+		if err != nil {
+			s.reply("425 Can not open passive connection.")
+		} else if name == "PASV" {
+			s.reply("227 Entering Passive Mode (" + addr.ToPortSpecifier() + ").")
+		} else {
+			s.reply(fmt.Sprintf("229 Entering Extended Passive Mode (|||%d|).", addr.Port))
+		}
+	case "PORT", "EPRT":
+		if s.active == nil {
+			s.reply("501 Invalid data address.")
+		} else {
+			s.reply("200 Command okay.")
+		}
+	case "QUIT":
+		s.reply("221 Goodbye.")
+	default:
+		return false
+	}
+
+	return true
+}
+
+/* Plays the specified scripted exchange */
+func (s *session) play(name string, e *exchange) {
+	if !e.isTransfer {
+		s.reply(e.replies[0])
+		return
+	}
+
+	if s.data == nil && s.active == nil {
+		s.reply("425 " + ERR_NoDataConnection.Error())
+		return
+	}
+
+	s.reply(e.replies[0])
+
+	conn, err := s.openData()
+	if err != nil {
+		s.reply("425 Can not open data connection.")
+		return
+	}
+
+	if UploadCommands[name] {
+		data, _ := io.ReadAll(conn)
+		s.server.upload(data)
+	} else {
+		conn.Write(e.payload)
+	}
+
+	conn.Close()
+	s.reply(e.replies[1])
+}
+
+/* Writes a reply on the control connection, terminating it if required. Empty replies are not sent */
+func (s *session) reply(reply string) {
+	if reply == EmptyString {
+		return
+	}
+
+	if !strings.HasSuffix(reply, EOL) {
+		reply += EOL
+	}
+
+	io.WriteString(s.conn, reply)
+}
+
+/* Registers the client's active mode data address (PORT, EPRT), dropping any passive mode listener */
+func (s *session) registerActive(name string, param string) {
+	s.closePassive()
+
+	if name == "PORT" {
+		s.active = Address.FromPortSpecifier(param)
+	} else {
+		s.active = Address.FromExtendedPortSpecifier(param)
+	}
+}
+
+/* Opens a new passive mode listener, replacing the previous one. The data connection is accepted in the background, as
+ clients connect before sending the transfer command */
+func (s *session) listenPassive() (*Address.Addr, error) {
+	s.closePassive()
+	s.active = nil
+
+	listener, err := Net.Listen(DefaultNetwork, LoopbackAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(chan Net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			data <- conn
+		}
+
+		close(data)
+	}()
+
+	s.passive = listener
+	s.data = data
+
+	local := listener.Addr().(*Net.TCPAddr)
+	return &Address.Addr{IP: &local.IP, Port: local.Port, IPFamily: Address.IPv4}, nil
+}
+
+/* Opens the data connection: connects to the client's active mode address, or waits for the passive mode data connection,
+ up to DataTimeout */
+func (s *session) openData() (Net.Conn, error) {
+	if active := s.active; active != nil {
+		s.active = nil
+		return Net.DialTimeout(active.Network(), active.String(), DataTimeout)
+	}
+
+	if s.data == nil {
+		return nil, ERR_NoDataConnection
+	}
+
+	defer s.closePassive()
+
+	select {
+	case conn, ok := <-s.data:
+		if ok {
+			return conn, nil
+		}
+	case <-time.After(DataTimeout):
+	}
+
+	return nil, ERR_NoDataConnection
+}
+
+/* Closes the passive mode listener, along with any unused data connection */
+func (s *session) closePassive() {
+	if s.passive == nil {
+		return
+	}
+
+	s.passive.Close()
+	if conn, ok := <-s.data; ok {
+		conn.Close()
+	}
+
+	s.passive = nil
+	s.data = nil
+}
@@ -0,0 +1,140 @@
+package testserver
+
+import (
+	Address "github.com/ghepesdoru/bookwormFTP/core/addr"
+	Net "net"
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+/* Sends a command line and reads a single line reply */
+func exchangeLine(t *testing.T, conn Net.Conn, reader *bufio.Reader, line string) string {
+	if line != EmptyString {
+		io.WriteString(conn, line + EOL)
+	}
+
+	reply, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal("Unable to read the reply.", line, err)
+	}
+
+	return strings.TrimRight(reply, EOL)
+}
+
+func TestServer(t *testing.T) {
+	server, err := NewServer()
+	if err != nil {
+		t.Fatal("Unable to start the test server.", err)
+	}
+	defer server.Close()
+
+	server.Reply("noop", "200 First.")
+	server.Reply("NOOP", "200 Again.")
+	server.Transfer("RETR", "150 Sending.", []byte("payload"), "226 Done.")
+
+	conn, err := Net.Dial(DefaultNetwork, server.Addr())
+	if err != nil {
+		t.Fatal("Unable to connect to the test server.", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	if reply := exchangeLine(t, conn, reader, EmptyString); reply != DefaultGreeting {
+		t.Fatal("Invalid greeting.", reply)
+	}
+
+	/* The last queued reply repeats */
+	for _, expected := range []string{"200 First.", "200 Again.", "200 Again."} {
+		if reply := exchangeLine(t, conn, reader, "NOOP"); reply != expected {
+			t.Fatal("Invalid scripted reply.", reply)
+		}
+	}
+
+	if reply := exchangeLine(t, conn, reader, "SYST"); reply != DefaultReply {
+		t.Fatal("Invalid unscripted reply.", reply)
+	}
+
+	if reply := exchangeLine(t, conn, reader, "RETR file"); !strings.HasPrefix(reply, "425 ") {
+		t.Fatal("Transfer without a passive mode data connection.", reply)
+	}
+
+	addr := Address.FromPortSpecifier(exchangeLine(t, conn, reader, "PASV"))
+	if addr == nil {
+		t.Fatal("Invalid passive mode reply.")
+	}
+
+	data, err := Net.Dial(DefaultNetwork, addr.String())
+	if err != nil {
+		t.Fatal("Unable to connect to the data port.", err)
+	}
+
+	if reply := exchangeLine(t, conn, reader, "RETR file"); reply != "150 Sending." {
+		t.Fatal("Invalid preliminary reply.", reply)
+	}
+
+	if payload, _ := io.ReadAll(data); string(payload) != "payload" {
+		t.Fatal("Invalid payload.", string(payload))
+	}
+
+	if reply := exchangeLine(t, conn, reader, EmptyString); reply != "226 Done." {
+		t.Fatal("Invalid final reply.", reply)
+	}
+
+	exchangeLine(t, conn, reader, "QUIT")
+	if commands := server.Commands(); len(commands) != 8 || commands[4] != "RETR file" || commands[7] != "QUIT" {
+		t.Fatal("Invalid received commands.", commands)
+	}
+}
+
+func TestActiveMode(t *testing.T) {
+	server, err := NewServer()
+	if err != nil {
+		t.Fatal("Unable to start the test server.", err)
+	}
+	defer server.Close()
+
+	server.Transfer("STOR", "150 Ok to send data.", nil, "226 Done.")
+
+	conn, err := Net.Dial(DefaultNetwork, server.Addr())
+	if err != nil {
+		t.Fatal("Unable to connect to the test server.", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	exchangeLine(t, conn, reader, EmptyString)
+
+	listener, err := Net.Listen(DefaultNetwork, LoopbackAddr)
+	if err != nil {
+		t.Fatal("Unable to open a data listener.", err)
+	}
+	defer listener.Close()
+
+	local := listener.Addr().(*Net.TCPAddr)
+	addr := &Address.Addr{IP: &local.IP, Port: local.Port, IPFamily: Address.IPv4}
+
+	if reply := exchangeLine(t, conn, reader, "EPRT " + addr.ToExtendedPortSpecifier()); !strings.HasPrefix(reply, "200 ") {
+		t.Fatal("Active mode address refused.", reply)
+	}
+
+	if reply := exchangeLine(t, conn, reader, "STOR upload"); reply != "150 Ok to send data." {
+		t.Fatal("Invalid preliminary reply.", reply)
+	}
+
+	data, err := listener.Accept()
+	if err != nil {
+		t.Fatal("The server did not open the data connection.", err)
+	}
+
+	io.WriteString(data, "uploaded")
+	data.Close()
+
+	if reply := exchangeLine(t, conn, reader, EmptyString); reply != "226 Done." {
+		t.Fatal("Invalid final reply.", reply)
+	}
+
+	if uploads := server.Uploads(); len(uploads) != 1 || string(uploads[0]) != "uploaded" {
+		t.Fatal("Invalid uploaded data.", uploads)
+	}
+}
@@ -0,0 +1,85 @@
+package transcript
+
+import (
+	TestServer "github.com/ghepesdoru/bookwormFTP/client/testserver"
+	"io"
+	"strings"
+)
+
+/* Successful data connection setup replies. Replayed servers open their own data connections instead */
+var DataSetupReplies map[string]string = map[string]string {
+	"EPRT": "200", "EPSV": "229", "PASV": "227", "PORT": "200",
+}
+
+/* Recorded command, along with everything that happened up to the next command */
+type exchange struct {
+	name		string
+	replies		[]string
+	data		[]byte
+}
+
+/* Starts a local server replaying the JSON-lines transcript read from the specified source */
+func Replay(source io.Reader) (*TestServer.Server, error) {
+	entries, err := Read(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReplayServer(entries)
+}
+
+/* Starts a local server replaying the specified transcript entries. Replies are served per command, in the recorded
+ order, and downloads serve the recorded data. Data connections are opened on the replaying server, whatever the
+ recorded addresses */
+func NewReplayServer(entries []*Entry) (*TestServer.Server, error) {
+	var greeting []string
+	var exchanges []*exchange
+	var current *exchange
+
+	for _, entry := range entries {
+		switch entry.Kind {
+		case KIND_Command:
+			name, _, _ := strings.Cut(entry.Text, " ")
+			current = &exchange{strings.ToUpper(name), nil, nil}
+			exchanges = append(exchanges, current)
+		case KIND_Reply:
+			if current == nil {
+				greeting = append(greeting, entry.Text)
+			} else {
+				current.replies = append(current.replies, entry.Text)
+			}
+		case KIND_DataRead:
+			if current != nil {
+				current.data = append(current.data, entry.Data...)
+			}
+		}
+	}
+
+	server, err := TestServer.NewServer()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(greeting) > 0 {
+		server.Greeting(strings.Join(greeting, EmptyString))
+	}
+
+	for _, e := range exchanges {
+		if len(e.replies) == 0 {
+			/* The session ended without a reply */
+			continue
+		}
+
+		if status, ok := DataSetupReplies[e.name]; ok && strings.HasPrefix(e.replies[0], status) {
+			continue
+		}
+
+		if strings.HasPrefix(e.replies[0], "1") {
+			server.Transfer(e.name, e.replies[0], e.data, strings.Join(e.replies[1:], EmptyString))
+		} else {
+			server.Reply(e.name, strings.Join(e.replies, EmptyString))
+		}
+	}
+
+	return server, nil
+}
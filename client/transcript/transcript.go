@@ -0,0 +1,158 @@
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	/* Entry kinds */
+	KIND_Command		= "command"		/* Command line sent on the control connection */
+	KIND_Reply			= "reply"		/* Raw reply read on the control connection */
+	KIND_DataRead		= "data-read"	/* Data read on a data connection */
+	KIND_DataWritten	= "data-written"	/* Data written on a data connection */
+
+	/* Generic constants */
+	RedactedSecret		= "****"
+	MaxEntrySize		= 64 * 1024 * 1024
+	EmptyString			= ""
+)
+
+/* Error definitions */
+var (
+	ERRF_InvalidEntry	= "Invalid transcript entry on line %d: %s"
+)
+
+/* Commands with secret parameters, never recorded as is */
+var SecretCommands map[string]bool = map[string]bool {
+	"ACCT": true, "PASS": true,
+}
+
+/* Single transcript entry, one per line in the JSON-lines session log. Data is base64 encoded */
+type Entry struct {
+	Time		time.Time	`json:"time"`
+	Kind		string		`json:"kind"`
+	Text		string		`json:"text,omitempty"`
+	Data		[]byte		`json:"data,omitempty"`
+}
+
+/* Session transcript recorder. Entries are written as they happen, as JSON lines */
+type Recorder struct {
+	encoder		*json.Encoder
+	err			error
+	mutex		sync.Mutex
+}
+
+/* Instantiates a new Recorder writing the transcript to the specified writer */
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{json.NewEncoder(w), nil, sync.Mutex{}}
+}
+
+/* Records a command line sent to the server. Secret parameters (PASS, ACCT) are redacted */
+func (r *Recorder) Command(line string) {
+	name, param, _ := strings.Cut(line, " ")
+
+	if SecretCommands[strings.ToUpper(name)] && param != EmptyString {
+		line = name + " " + RedactedSecret
+	}
+
+	r.record(&Entry{time.Now(), KIND_Command, line, nil})
+}
+
+/* Records a raw reply read from the server */
+func (r *Recorder) Reply(raw []byte) {
+	r.record(&Entry{time.Now(), KIND_Reply, string(raw), nil})
+}
+
+/* Records data read on a data connection */
+func (r *Recorder) DataRead(data []byte) {
+	r.record(&Entry{time.Now(), KIND_DataRead, EmptyString, append([]byte{}, data...)})
+}
+
+/* Records data written on a data connection */
+func (r *Recorder) DataWritten(data []byte) {
+	r.record(&Entry{time.Now(), KIND_DataWritten, EmptyString, append([]byte{}, data...)})
+}
+
+/* Gets the first error met while writing the transcript. Recording stops on the first error */
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.err
+}
+
+/* Wraps the specified data connection reader, recording all reads */
+func (r *Recorder) Reader(source io.Reader) io.Reader {
+	return &recordingReader{source, r}
+}
+
+/* Wraps the specified data connection writer, recording all writes */
+func (r *Recorder) Writer(destination io.Writer) io.Writer {
+	return &recordingWriter{destination, r}
+}
+
+/* Writes the specified entry, unless a previous write failed */
+func (r *Recorder) record(entry *Entry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err == nil {
+		r.err = r.encoder.Encode(entry)
+	}
+}
+
+/* Reads all the entries of a JSON-lines transcript */
+func Read(source io.Reader) (entries []*Entry, err error) {
+	var scanner *bufio.Scanner = bufio.NewScanner(source)
+	scanner.Buffer(nil, MaxEntrySize)
+
+	for n := 1; scanner.Scan(); n += 1 {
+		var entry Entry
+
+		if strings.TrimSpace(scanner.Text()) == EmptyString {
+			continue
+		}
+
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf(ERRF_InvalidEntry, n, err.Error())
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+/* Data connection reader, recording everything read */
+type recordingReader struct {
+	source		io.Reader
+	recorder	*Recorder
+}
+
+func (r *recordingReader) Read(p []byte) (n int, err error) {
+	if n, err = r.source.Read(p); n > 0 {
+		r.recorder.DataRead(p[:n])
+	}
+
+	return
+}
+
+/* Data connection writer, recording everything written */
+type recordingWriter struct {
+	destination	io.Writer
+	recorder	*Recorder
+}
+
+func (w *recordingWriter) Write(p []byte) (n int, err error) {
+	if n, err = w.destination.Write(p); n > 0 {
+		w.recorder.DataWritten(p[:n])
+	}
+
+	return
+}
@@ -0,0 +1,135 @@
+package transcript_test
+
+import (
+	Client "github.com/ghepesdoru/bookwormFTP/client"
+	Transcript "github.com/ghepesdoru/bookwormFTP/client/transcript"
+	Credentials "github.com/ghepesdoru/bookwormFTP/core/credentials"
+	Server "github.com/ghepesdoru/bookwormFTP/server"
+	Net "net"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/* Starts a BookwormFTP server over an in memory driver, with a "user" user */
+func startServer(t *testing.T) string {
+	driver := Server.NewMemoryDriver()
+	driver.WriteFile("/hello.txt", []byte("hello world"))
+
+	authenticator := Server.NewStaticAuthenticator()
+	credentials, _ := Credentials.NewCredentials("user", "secret")
+	authenticator.AddUser(credentials, nil)
+
+	server := Server.NewServer("127.0.0.1:0", driver, authenticator)
+	listener, err := Net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Unable to open a loopback listener.", err)
+	}
+
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	return listener.Addr().String()
+}
+
+/* Runs the same session on the specified client: lists the root directory and downloads a file */
+func runSession(t *testing.T, client *Client.Client) (names []string, content string) {
+	var buffer bytes.Buffer
+
+	listing, err := client.List()
+	if err != nil {
+		t.Fatal("Unable to list the root directory.", err)
+	}
+
+	for _, resource := range listing.Content {
+		names = append(names, resource.Name)
+	}
+
+	if ok, err := client.PassiveMode(); !ok {
+		t.Fatal("Unable to enter passive mode.", err)
+	}
+
+	if _, err = client.Commands.RETR("hello.txt", &buffer); err != nil {
+		t.Fatal("Unable to download a file.", err)
+	}
+
+	client.Quit()
+	return names, buffer.String()
+}
+
+/* Extracts the command names of a transcript */
+func commandNames(entries []*Transcript.Entry) (names []string) {
+	for _, entry := range entries {
+		if entry.Kind == Transcript.KIND_Command {
+			name, _, _ := strings.Cut(entry.Text, " ")
+			names = append(names, name)
+		}
+	}
+
+	return
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var log bytes.Buffer
+
+	recorder := Transcript.NewRecorder(&log)
+	client, err := Client.NewClientRecorded("ftp://user:secret@" + startServer(t) + "/", recorder)
+	if err != nil {
+		t.Fatal("Unable to connect to the server.", err)
+	}
+
+	names, content := runSession(t, client)
+	if content != "hello world" {
+		t.Fatal("Invalid downloaded content.", content)
+	}
+
+	if err = recorder.Err(); err != nil {
+		t.Fatal("Unable to record the transcript.", err)
+	}
+
+	/* Passwords are never recorded */
+	if strings.Contains(log.String(), "secret") || !strings.Contains(log.String(), "PASS ****") {
+		t.Fatal("Password recorded as is.", log.String())
+	}
+
+	entries, err := Transcript.Read(bytes.NewReader(log.Bytes()))
+	if err != nil || len(entries) == 0 || entries[0].Kind != Transcript.KIND_Reply || !strings.HasPrefix(entries[0].Text, "220") {
+		t.Fatal("Invalid transcript, expecting the server greeting first.", err)
+	}
+
+	/* Replay the transcript, and run the same session against it */
+	replay, err := Transcript.NewReplayServer(entries)
+	if err != nil {
+		t.Fatal("Unable to start the replay server.", err)
+	}
+	defer replay.Close()
+
+	client, err = Client.NewClient(replay.URL("user", "secret", ""))
+	if err != nil {
+		t.Fatal("Unable to connect to the replay server.", err)
+	}
+
+	replayedNames, replayedContent := runSession(t, client)
+	if content != replayedContent || !reflect.DeepEqual(names, replayedNames) {
+		t.Fatal("Replayed session differs from the recorded one.", replayedNames, replayedContent)
+	}
+
+	var replayed []string
+	for _, line := range replay.Commands() {
+		name, _, _ := strings.Cut(line, " ")
+		replayed = append(replayed, name)
+	}
+
+	if recorded := commandNames(entries); !reflect.DeepEqual(recorded, replayed) {
+		t.Fatal("Replayed commands differ from the recorded ones.", recorded, replayed)
+	}
+}
+
+func TestReadInvalidTranscript(t *testing.T) {
+	if _, err := Transcript.Read(strings.NewReader("{\"kind\":\"reply\",\"text\":\"220 Ready.\\r\\n\"}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatal("Invalid transcript accepted.", err)
+	}
+}
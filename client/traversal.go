@@ -0,0 +1,198 @@
+package client
+
+import (
+	Resources "github.com/ghepesdoru/bookwormFTP/core/parsers/resource"
+	Path "path"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+/* Maximum depth of the recursive operations (guards against link cycles that can not be resolved) */
+const MaxTraversalDepth = 64
+
+var ERR_MaxDepth = fmt.Errorf("Maximum traversal depth (%d) exceeded.", MaxTraversalDepth)
+
+/* Walk function results skipping the current directory (or the remaining resources of the current directory, when
+ returned for a file), or all the remaining resources */
+var (
+	SkipDir = fs.SkipDir
+	SkipAll = fs.SkipAll
+)
+
+/* Function called by Walk for each visited resource, with the same semantics as fs.WalkDirFunc: the path is the
+ walked root joined with the resource name, and err reports a failed root lookup (nil res) or directory listing */
+type WalkFunc func(path string, res *Resources.Resource, err error) error
+
+/* Walks the remote tree rooted at the specified path, calling fn for each resource (directories before their content,
+ in lexical order). Directories are listed with MLSD when available, otherwise LIST. The current directory is left
+ unchanged, and symbolic links are not followed */
+func (c *Client) Walk(root string, fn WalkFunc) error {
+	var originalResources *Resources.Resource = c.Resources
+
+	/* Check connection ready state before executing command */
+	if ok, err := c.isReady(); !ok {
+		return err
+	}
+	defer func() {
+		c.Resources = originalResources
+	}()
+
+	abs := c.remotePath(root)
+	res, err := c.stat(abs)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = c.walk(root, abs, res, fn)
+	}
+
+	if err == SkipDir || err == SkipAll {
+		return nil
+	}
+
+	return err
+}
+
+/* Walks the specified resource (and it's content for directories) */
+func (c *Client) walk(path string, abs string, res *Resources.Resource, fn WalkFunc) error {
+	if err := fn(path, res, nil); err != nil || !res.IsDir() {
+		if err == SkipDir && res.IsDir() {
+			/* Skip the directory content */
+			err = nil
+		}
+
+		return err
+	}
+
+	listing, err := c.list(abs, false)
+	if err != nil {
+		/* Report the listing failure */
+		if err = fn(path, res, err); err == SkipDir {
+			err = nil
+		}
+
+		return err
+	}
+
+	var content []*Resources.Resource
+	for _, r := range listing.Content {
+		if nil != r && r.IsChild() {
+			content = append(content, r)
+		}
+	}
+
+	sort.Slice(content, func(i, j int) bool {
+		return content[i].Name < content[j].Name
+	})
+
+	for _, r := range content {
+		if err = c.walk(Path.Join(path, r.Name), Path.Join(abs, r.Name), r, fn); err != nil {
+			if err == SkipDir {
+				/* Skip the remaining resources of the current directory */
+				break
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+/* Gets the absolute remote path of the specified path (relative to the current directory, or absolute) */
+func (c *Client) remotePath(path string) string {
+	if Path.IsAbs(path) {
+		return Path.Clean(path)
+	}
+
+	return Path.Join(RootDir + c.path.GetCurrentDir(), path)
+}
+
+/* Describes the resource at the specified absolute remote path, as listed in it's container (the root directory
+ describes itself). Replaces the client's Resources with the container's listing */
+func (c *Client) stat(path string) (*Resources.Resource, error) {
+	if path == RootDir {
+		return c.list(RootDir, false)
+	}
+
+	container, err := c.list(Path.Dir(path), false)
+	if err != nil {
+		return nil, err
+	}
+
+	if res := container.GetContentByName(Path.Base(path)); res != nil {
+		return res, nil
+	}
+
+	return nil, ERR_UnableToLocateRes
+}
+
+/* Real (symbolic links resolved) remote paths of the directories a recursive operation is currently in, ancestors of
+ the starting directory included */
+type traversal struct {
+	dirs		[]string
+}
+
+/* Starts a new traversal in the specified remote directory */
+func newTraversal(root string) *traversal {
+	var dirs []string = []string{RootDir}
+	var current string = RootDir
+
+	for _, dir := range strings.Split(Path.Clean(RootDir + root), RootDir) {
+		if dir != EmptyString {
+			current = Path.Join(current, dir)
+			dirs = append(dirs, current)
+		}
+	}
+
+	return &traversal{dirs}
+}
+
+/* Gets the real remote path of the specified subdirectory or link, from the current directory */
+func (t *traversal) resolve(res *Resources.Resource) string {
+	current := t.dirs[len(t.dirs) - 1]
+
+	if res.IsLink() && res.Target != EmptyString {
+		if Path.IsAbs(res.Target) {
+			return Path.Clean(res.Target)
+		}
+
+		return Path.Join(current, res.Target)
+	}
+
+	return Path.Join(current, res.Name)
+}
+
+/* Checks if entering the specified subdirectory or link would loop back into a directory already being traversed */
+func (t *traversal) isCycle(res *Resources.Resource) bool {
+	real := t.resolve(res)
+
+	for _, dir := range t.dirs {
+		if dir == real {
+			return true
+		}
+	}
+
+	return false
+}
+
+/* Enters the specified subdirectory or link. Cycles are not entered, and fail with ERR_MaxDepth past the maximum
+ traversal depth */
+func (t *traversal) enter(res *Resources.Resource) (ok bool, err error) {
+	if t.isCycle(res) {
+		return false, nil
+	}
+
+	if len(t.dirs) >= MaxTraversalDepth {
+		return false, ERR_MaxDepth
+	}
+
+	t.dirs = append(t.dirs, t.resolve(res))
+	return true, nil
+}
+
+/* Leaves the last entered directory */
+func (t *traversal) leave() {
+	t.dirs = t.dirs[:len(t.dirs) - 1]
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	Resources "github.com/ghepesdoru/bookwormFTP/core/parsers/resource"
+	"testing"
+)
+
+/* Builds a listed resource of the specified type */
+func listed(name string, resType Resources.ResourceType, target string) *Resources.Resource {
+	res := Resources.NewResource(name, 0, nil, nil, resType, EmptyString, nil, EmptyString, Resources.MIME_Unknown, EmptyString)
+	res.Target = target
+
+	return res
+}
+
+func TestTraversalCycles(t *testing.T) {
+	tr := newTraversal("/pub/")
+
+	if ok, _ := tr.enter(listed("releases", Resources.TYPE_Dir, EmptyString)); !ok {
+		t.Fatal("Unable to enter a subdirectory.")
+	}
+
+	/* Links to the directories being traversed (ancestors of the starting directory included) loop */
+	for _, target := range []string{"/", "..", "../../pub", "/pub/releases/"} {
+		if !tr.isCycle(listed("loop", Resources.TYPE_Link, target)) {
+			t.Fatal("Link cycle not detected.", target)
+		}
+	}
+
+	if ok, _ := tr.enter(listed("latest", Resources.TYPE_Link, "../archive/v2")); !ok || tr.dirs[len(tr.dirs) - 1] != "/pub/archive/v2" {
+		t.Fatal("Invalid link resolution.", tr.dirs)
+	}
+
+	tr.leave()
+	tr.leave()
+	if len(tr.dirs) != 2 || tr.isCycle(listed("releases", Resources.TYPE_Dir, EmptyString)) {
+		t.Fatal("Invalid traversal after leaving the entered directories.", tr.dirs)
+	}
+
+	if ok, err := tr.enter(listed("loop", Resources.TYPE_Link, "..")); ok || err != nil {
+		t.Fatal("Cycle entered.", err)
+	}
+
+	/* Unresolvable link chains are bounded, and reported apart from cycles */
+	for i := 0; ; i++ {
+		ok, err := tr.enter(listed("next", Resources.TYPE_Link, EmptyString))
+		if !ok {
+			if err != ERR_MaxDepth {
+				t.Fatal("Maximum traversal depth not reported.", err)
+			}
+
+			break
+		}
+
+		if i > MaxTraversalDepth {
+			t.Fatal("Unbounded traversal depth.")
+		}
+	}
+}
@@ -61,3 +61,16 @@ func (a *AccessRights) Contains (perm Perm) bool {
 
 	return false
 }
+
+/* Generates the MLSx perm fact representation of the current AccessRights (RFC3659 7.5.5) */
+func (a *AccessRights) ToPermString() string {
+	var perm []byte
+
+	for _, c := range []byte("acdeflmprw") {
+		if a.Contains(KnownPermissions[c]) {
+			perm = append(perm, c)
+		}
+	}
+
+	return string(perm)
+}
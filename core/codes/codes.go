@@ -28,6 +28,8 @@ const (
 	UserLoggedIn 					= 230
 	UserLogOff_Termination 			= 231
 	UserLogOff_QueuedTermination 	= 232
+	SecurityDataExchangeComplete	= 234
+	SecurityDataExchangeSuccess		= 235
 	FileActionOk 					= 250
 	Pathname		 				= 257
 	/* 3xy - The command has been accepted, but the requested action is on hold, pending receipt of
@@ -35,6 +37,9 @@ const (
 	PositiveIntermediate			= 300
 	UserNameOk 						= 331
 	AccountForLogin 				= 332
+	SecurityMechanismOk				= 334
+	SecurityDataAcceptable			= 335
+	UserNameOkChallenge				= 336
 	FileActionPending 				= 350
 	/* 4xy - The command was not accepted and the requested action did not take place, but the error
 	condition is temporary and the action may be requested again. */
@@ -43,6 +48,7 @@ const (
 	DataConnectionFail 				= 425
 	ConnectionClose					= 426
 	InvalidAuthenticationData		= 430
+	SecurityResourceUnavailable		= 431
 	UnavailableHost					= 434
 	ActionNotTaken					= 450
 	ProcessingError					= 451
@@ -54,12 +60,18 @@ const (
 	NotImplemented					= 502
 	BadSequence						= 503
 	WrongParameters					= 504
+	NetworkProtocolNotSupported		= 522
 	NotLoggedIn						= 530
 	AuthenticationRequired			= 532
+	CommandProtectionDenied			= 533
+	PolicyDenied					= 534
+	FailedSecurityCheck				= 535
+	ProtectionLevelNotSupported		= 536
 	FileUnavailable					= 550
 	ActionAborted					= 551
 	FileActionAborted				= 552
 	BadFileName						= 553
+	InvalidRestParameter			= 554
 	/* 6xy - Replies regarding confidentiality and integrity */
 	ProtectedReply					= 600
 	ProtectedIntegrity				= 631
@@ -77,10 +89,11 @@ const (
 var KnownStatusCodes map[int]bool = map[int]bool {
 	100: true, 110: true, 120: true, 125: true, 150: true,
 	200: true, 202: true, 211: true, 212: true, 213: true, 214: true, 215: true, 220: true, 221: true, 225: true,
-	226: true, 227: true, 228: true, 229: true, 230: true, 231: true, 232: true, 250: true, 257: true,
-	300: true, 331: true, 332: true, 350: true,
-	400: true, 421: true, 425: true, 426: true, 430: true, 434: true, 450: true, 451: true, 452: true,
-	500: true, 501: true, 502: true, 503: true, 504: true, 530: true, 532: true, 550: true, 551: true, 552: true, 553: true,
+	226: true, 227: true, 228: true, 229: true, 230: true, 231: true, 232: true, 234: true, 235: true, 250: true, 257: true,
+	300: true, 331: true, 332: true, 334: true, 335: true, 336: true, 350: true,
+	400: true, 421: true, 425: true, 426: true, 430: true, 431: true, 434: true, 450: true, 451: true, 452: true,
+	500: true, 501: true, 502: true, 503: true, 504: true, 522: true, 530: true, 532: true, 533: true, 534: true, 535: true,
+	536: true, 550: true, 551: true, 552: true, 553: true, 554: true,
 	600: true, 631: true, 632: true, 633: true,
 	10000: true, 10054: true, 10060: true, 10061: true, 10066: true, 10068: true,
 }
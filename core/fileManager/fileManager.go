@@ -34,8 +34,8 @@ var (
 		SELECT_ReadOnly:		selectionType{os.O_RDONLY,	os.ModePerm},
 		SELECT_WriteOnly:		selectionType{os.O_WRONLY, 	os.ModePerm},
 		SELECT_ReadWrite:		selectionType{os.O_RDWR, 	os.ModePerm},
-		SELECT_Append:			selectionType{os.O_APPEND,	os.ModePerm},
-		SELECT_Truncate:		selectionType{os.O_TRUNC,	os.ModePerm},
+		SELECT_Append:			selectionType{os.O_WRONLY | os.O_APPEND,	os.ModePerm},
+		SELECT_Truncate:		selectionType{os.O_WRONLY | os.O_TRUNC,		os.ModePerm},
 		SELECT_CreateNew:		selectionType{os.O_WRONLY,	os.ModePerm},
 	}
 )
@@ -127,6 +127,20 @@ func (fm *FileManager) CreateFile(fileName string) (ok bool, err error) {
 	return err == nil, err
 }
 
+/* Get the absolute path of the current directory */
+func (fm *FileManager) CurrentDir() string {
+	return fm.path.GetCurrentDir()
+}
+
+/* Get the current size of the specified file, or -1 if the file does not exist */
+func (fm *FileManager) FileSize(fileName string) int64 {
+	if s, err := os.Stat(fm.path.ToCurrentDir(fileName)); err == nil && !s.IsDir() {
+		return s.Size()
+	}
+
+	return -1
+}
+
 /* Get the file currently in focus */
 func (fm *FileManager) GetSelection() *os.File {
 	if fm.focus != nil {
@@ -136,6 +150,11 @@ func (fm *FileManager) GetSelection() *os.File {
 	return nil
 }
 
+/* Gets the FileInfo list of the current directory contents */
+func (fm *FileManager) Listing() []os.FileInfo {
+	return fm.listing
+}
+
 /* Lists the contents of the current directory */
 func (fm *FileManager) List() []string {
 	var list []string
@@ -182,6 +201,29 @@ func (fm *FileManager) MakeDir(dir string) (ok bool, err error) {
 	return err == nil, err
 }
 
+/* Creates a symbolic link to the specified target in the current directory. Existing links are replaced */
+func (fm *FileManager) MakeSymlink(name string, target string) (ok bool, err error) {
+	link := fm.path.ToCurrentDir(name)
+
+	if s, e := os.Lstat(link); e == nil && s.Mode() & os.ModeSymlink != 0 {
+		if err = os.Remove(link); err != nil {
+			return false, err
+		}
+
+		fm.RefreshList()
+	}
+
+	if err = os.Symlink(target, link); err == nil {
+		if s, e := os.Lstat(link); e == nil {
+			fm.listing = append(fm.listing, s)
+		} else {
+			err = e
+		}
+	}
+
+	return err == nil, err
+}
+
 /* Refreshes the current directory FileInfo list */
 func (fm *FileManager) RefreshList() (ok bool, err error) {
 	var f *os.File
@@ -237,7 +279,7 @@ func (fm *FileManager) _select(fileName string, se selection) (f *os.File, err e
 				}
 			}
 		}
-	} else if se != SELECT_ReadOnly && se != SELECT_Append && se != SELECT_Truncate {
+	} else if se != SELECT_ReadOnly && se != SELECT_Append {
 		fmt.Println("Before creating file:")
 		/* Create the new file */
 		_, err = fm.CreateFile(fm.path.ToCurrentDir(fileName))
@@ -283,7 +325,7 @@ func (fm *FileManager) SelectForWriteNew(fileName string) (f *os.File, err error
 	return fm.Select(fileName, SELECT_CreateNew)
 }
 
-/* Specialized selection: Reading only */
+/* Specialized selection: Write at the end of an existing file */
 func (fm *FileManager) SelectForAppend(fileName string) (f *os.File, err error) {
 	return fm.Select(fileName, SELECT_Append)
 }
@@ -294,6 +336,8 @@ func (fm *FileManager) SelectionClear() (err error) {
 		if err = fm.focus.Sync(); err == nil {
 			err = fm.focus.Close()
 		}
+
+		fm.focus = nil
 	}
 
 	return
@@ -4,6 +4,8 @@ import (
 	BaseParser "github.com/ghepesdoru/bookwormFTP/core/parsers/base"
 	Commands "github.com/ghepesdoru/bookwormFTP/core/commands"
 	"fmt"
+	"strings"
+	"unicode"
 )
 
 const (
@@ -23,11 +25,12 @@ var (
 type Features struct{
 	features map[string]string
 	hasFeatures bool
+	removed map[string]bool
 }
 
 /* Build a new features instance (it supposes MLST as supported by default) */
 func NewFeatures() *Features {
-	return &Features{make(map[string]string), false}
+	return &Features{make(map[string]string), false, make(map[string]bool)}
 }
 
 /* Given a FEAT reply, generates a new Features instance with parsed data. */
@@ -52,17 +55,42 @@ func FromFeaturesList(list []byte) *Features {
 func (f *Features) AddFeature(feature string, params string) {
 	feature = Commands.ToStandardCommand(feature)
 
-	if len(feature) > 0 {
-		if Commands.IsValid(feature) && !Commands.IsMandatory(feature) && !f.Supports(feature) {
-			f.features[feature] = params
+	params = strings.TrimSpace(params)
 
-			if f.hasFeatures == false {
-				f.hasFeatures = true
+	if len(feature) > 0 && Commands.IsValid(feature) {
+		if current, listed := f.features[feature]; listed {
+			/* Features implied by a standard version can be later advertised with their parameters */
+			if current != EmptyString || params == EmptyString {
+				return
 			}
+		} else if !Commands.IsMandatory(feature) && f.Supports(feature) {
+			return
+		}
+
+		/* Mandatory features are always supported, but their parameters are still relevant (e.g. REST STREAM) */
+		f.features[feature] = params
+
+		if f.hasFeatures == false {
+			f.hasFeatures = true
 		}
 	}
 }
 
+/* Copies the features, including the removed ones */
+func (f *Features) Copy() *Features {
+	copied := &Features{make(map[string]string), f.hasFeatures, make(map[string]bool)}
+
+	for feature, params := range f.features {
+		copied.features[feature] = params
+	}
+
+	for feature, removed := range f.removed {
+		copied.removed[feature] = removed
+	}
+
+	return copied
+}
+
 /* Get the specified feature parameters */
 func (f *Features) GetParameters(feature string) (params string, err error) {
 	feature = Commands.ToStandardCommand(feature)
@@ -101,9 +129,28 @@ func (f *Features) GetLanguagesList() (languages []string) {
 	return
 }
 
+/* Gets the facts advertised by the MLST feature (ex: "type*;size*;UNIX.mode;"), without the "*" marking the facts
+ enabled by default */
+func (f *Features) GetMLSTFacts() (facts []string) {
+	params, _ := f.GetParameters("MLST")
+
+	for _, fact := range strings.Split(params, ";") {
+		if fact = strings.TrimSuffix(strings.TrimSpace(fact), "*"); fact != EmptyString {
+			facts = append(facts, fact)
+		}
+	}
+
+	return
+}
+
 /* Removes the specified feature from the current features set */
 func (f *Features) RemoveFeature(feature string) {
 	if f.Supports(feature) {
+		/* Remember optional base commands the server does not implement */
+		if Commands.IsBase(feature) && !Commands.IsMandatory(feature) {
+			f.removed[feature] = true
+		}
+
 		delete(f.features, feature)
 
 		if len(f.features) == 0 {
@@ -116,6 +163,9 @@ func (f *Features) RemoveFeature(feature string) {
 func (f *Features) Supports(feature string) bool {
 	if Commands.IsMandatory(feature) {
 		return true
+	} else if Commands.IsBase(feature) {
+		/* Optional base commands are not advertised by FEAT. Assume support until proven otherwise */
+		return !f.removed[feature]
 	} else if f.HasFeatures() {
 		_, ok := f.features[feature]
 		return ok
@@ -124,6 +174,28 @@ func (f *Features) Supports(feature string) bool {
 	return false
 }
 
+/* Checks if the specified feature was advertised using the specified parameter (e.g. REST STREAM) */
+func (f *Features) SupportsParameter(feature string, param string) bool {
+	params, err := f.GetParameters(feature)
+	if err != nil {
+		return false
+	}
+
+	param = strings.ToUpper(strings.TrimSpace(param))
+	for _, p := range strings.FieldsFunc(strings.ToUpper(params), isParameterSeparator) {
+		if p == param {
+			return true
+		}
+	}
+
+	return false
+}
+
+/* Checks if the specified character separates two feature parameters */
+func isParameterSeparator(c rune) bool {
+	return c == ';' || c == ',' || unicode.IsSpace(c)
+}
+
 /* Extracts the feature from specified line and adds it to the features list */
 func (f *Features) extractFeature(line []byte) {
 	var feature, params []byte
@@ -0,0 +1,53 @@
+package features
+
+import (
+	"testing"
+)
+
+var (
+	FEATReply = []byte("211-Features:\r\n EPSV\r\n MDTM\r\n MLST size*;type*;perm*;modify*;\r\n REST STREAM\r\n SIZE\r\n UTF8\r\n211 End\r\n")
+)
+
+func TestMandatoryFeatureParameters(t *testing.T) {
+	f := FromFeaturesList(FEATReply)
+
+	if !f.Supports("REST") {
+		t.Fatal("Mandatory feature not supported.")
+	}
+
+	if !f.SupportsParameter("REST", "STREAM") || !f.SupportsParameter("rest", "stream") {
+		t.Fatal("Mandatory feature parameters not registered.")
+	}
+
+	if f.SupportsParameter("REST", "BLOCK") {
+		t.Fatal("Unadvertised feature parameter supported.")
+	}
+}
+
+func TestOptionalFeatureParameters(t *testing.T) {
+	f := FromFeaturesList(FEATReply)
+
+	if !f.SupportsParameter("MLST", "size*") || !f.SupportsParameter("MLST", "modify*") {
+		t.Fatal("Invalid MLST facts list.")
+	}
+
+	if f.SupportsParameter("SIZE", "size*") {
+		t.Fatal("Feature without parameters supports a parameter.")
+	}
+
+	if f.SupportsParameter("LANG", "EN") {
+		t.Fatal("Unsupported feature reports supported parameters.")
+	}
+}
+
+func TestMLSTFacts(t *testing.T) {
+	f := FromFeaturesList([]byte("211-Features:\r\n MLST type*;size*;UNIX.mode;media-type;\r\n211 End\r\n"))
+
+	if facts := f.GetMLSTFacts(); len(facts) != 4 || facts[0] != "type" || facts[2] != "UNIX.mode" {
+		t.Fatal("Invalid MLST facts.", facts)
+	}
+
+	if facts := NewFeatures().GetMLSTFacts(); len(facts) != 0 {
+		t.Fatal("Facts listed without a MLST feature.", facts)
+	}
+}
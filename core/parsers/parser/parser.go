@@ -158,7 +158,7 @@ func (r *Parser) parse(raw []byte) (resp *response.Response, consumed int, err e
 				line = line[j:]
 
 				/* Ignore empty lines */
-				if j < (lineLength - 1) {
+				if j < lineLength {
 					rawContent = append(rawContent, append(line, []byte{10}...)...)
 					linesCount += 1
 				}
@@ -132,3 +132,14 @@ func TestInvalidResponseLineStatus(t *testing.T) {
 		t.Fatal("Invalid parsing. Invalid status input does not generate error.", parser.Get())
 	}
 }
+
+func TestSingleCharacterMessage(t *testing.T) {
+	parser := NewParser()
+	parser.ParseBlock([]byte("213 5\r\n"))
+
+	if parser.HasErrors() {
+		t.Fatal("Parsing of valid single character message triggering errors.", parser.LastError())
+	}
+
+	test(parser.Get(), Response.NewResponse(213, []byte("5\n"), false), "single character message", t)
+}
@@ -0,0 +1,461 @@
+package resource
+
+import (
+	Access "github.com/ghepesdoru/bookwormFTP/core/access"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* LIST formats */
+const (
+	LIST_Unix	= "unix"
+	LIST_DOS	= "dos"
+	LIST_EPLF	= "eplf"
+	LIST_VMS	= "vms"
+)
+
+const (
+	/* Listed dates more than this in the future belong to the previous year (ls omits the year of recent files) */
+	FutureDateTolerance	= 24 * time.Hour
+	VMSBlockSize		= 512
+)
+
+/* Error definitions */
+var (
+	ERRF_UnrecognizedListLine = "Unrecognized LIST line: %s"
+)
+
+/* Parses a single LIST line. Lines in a different format are not recognized (ok = false), while recognized lines
+ without any resource (ex: "total 12") give a nil resource. Dates without a year are inferred relative to now */
+type ListParser func(line string, now time.Time) (res *Resource, ok bool)
+
+var (
+	/* Registered LIST parsers, and the order they are tried in when auto detecting the format */
+	listParsers		= map[string]ListParser {
+		LIST_Unix: parseUnixLine, LIST_DOS: parseDOSLine, LIST_EPLF: parseEPLFLine, LIST_VMS: parseVMSLine,
+	}
+	listOrder		= []string{LIST_EPLF, LIST_Unix, LIST_DOS, LIST_VMS}
+	listMutex		sync.RWMutex
+
+	/* SYST reply markers of each LIST format */
+	SystemToListFormat = map[string]string {
+		"UNIX": LIST_Unix, "WINDOWS": LIST_DOS, "VMS": LIST_VMS,
+	}
+
+	MatchUnixLine	= regexp.MustCompile(`^([-bcdlps])([-rwxsStT]{9})[+.@]?\s+(?:\d+\s+)?(?:(\S+)\s+)?(?:(\S+)\s+)?(\d+)\s+([A-Za-z]{3})\s+(\d{1,2})\s+(\d{1,2}:\d{2}|\d{4})\s+(.+)$`)
+	MatchDOSLine	= regexp.MustCompile(`^(\d{2})-(\d{2})-(\d{2}|\d{4})\s+(\d{1,2}):(\d{2})\s*([AaPp][Mm])?\s+(<DIR>|\d+)\s+(.+)$`)
+	MatchVMSLine	= regexp.MustCompile(`^(\S+);(\d+)\s+(\d+)(?:/\d+)?\s+(\d{1,2})-([A-Za-z]{3})-(\d{4})\s+(\d{1,2}):(\d{2})(?::(\d{2}))?(?:\s+\[([^\]]*)\])?(?:\s+\(([^)]*)\))?`)
+	MatchVMSName	= regexp.MustCompile(`^\S+;\d+$`)
+
+	Months = map[string]time.Month {
+		"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April, "may": time.May, "jun": time.June,
+		"jul": time.July, "aug": time.August, "sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+	}
+)
+
+/* Registers (or replaces) a LIST parser. New formats are tried last when auto detecting the format */
+func RegisterListParser(format string, parser ListParser) {
+	listMutex.Lock()
+	defer listMutex.Unlock()
+
+	if _, ok := listParsers[format]; !ok {
+		listOrder = append(listOrder, format)
+	}
+
+	listParsers[format] = parser
+}
+
+/* Gets the LIST format matching the specified SYST reply (ex: "UNIX Type: L8"), or an empty string if unknown */
+func ListFormatFromSystem(system string) string {
+	system = strings.ToUpper(system)
+
+	for marker, format := range SystemToListFormat {
+		if strings.Contains(system, marker) {
+			return format
+		}
+	}
+
+	return EmptyString
+}
+
+/* Extracts the resources from a LIST listing, auto detecting the listing format */
+func FromList(list []byte) (*Resource, error) {
+	return FromListWithSystem(list, EmptyString)
+}
+
+/* Extracts the resources from a LIST listing, trying the format of the specified system (SYST reply) first. Lines in any
+ other format are auto detected */
+func FromListWithSystem(list []byte, system string) (res *Resource, err error) {
+	var now time.Time = time.Now().UTC()
+	var preferred string = ListFormatFromSystem(system)
+	var lines []string
+
+	/* Define a virtual container for resource functionality uniformity. */
+	res = &Resource{".", 0, &UnknownTime, &UnknownTime, TYPE_Dir, EmptyString, Access.NewEmptyAccessRights(), EmptyString, MIME_Unknown, EmptyString, EmptyString, EmptyString, os.ModeDir, EmptyString, nil, nil, nil}
+
+	for _, line := range bytes.Split(list, []byte{'\n'}) {
+		if line := strings.TrimRight(string(line), "\r"); strings.TrimSpace(line) != EmptyString {
+			lines = append(lines, line)
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		/* Long VMS names push the rest of the entry on the following line */
+		if MatchVMSName.MatchString(strings.TrimSpace(line)) && i + 1 < len(lines) {
+			line, i = strings.TrimSpace(line) + " " + strings.TrimSpace(lines[i + 1]), i + 1
+		}
+
+		r, format := parseListLine(line, preferred, now)
+		if format == EmptyString {
+			if err == nil {
+				err = fmt.Errorf(ERRF_UnrecognizedListLine, line)
+			}
+
+			continue
+		}
+
+		/* Keep using the detected format */
+		preferred = format
+
+		if r != nil && r.Name != "." && r.Name != ".." {
+			r.Parent = res
+			res.Content = append(res.Content, r)
+		}
+	}
+
+	if len(res.Content) > 0 {
+		/* Partially recognized listings are still usable */
+		err = nil
+	}
+
+	return
+}
+
+/* Parses a single LIST line with the preferred format first, then with all the other formats in order. Gets the
+ recognizing format, or an empty string */
+func parseListLine(line string, preferred string, now time.Time) (*Resource, string) {
+	listMutex.RLock()
+	defer listMutex.RUnlock()
+
+	if parser, ok := listParsers[preferred]; ok {
+		if r, ok := parser(line, now); ok {
+			return r, preferred
+		}
+	}
+
+	for _, format := range listOrder {
+		if format == preferred {
+			continue
+		}
+
+		if r, ok := listParsers[format](line, now); ok {
+			return r, format
+		}
+	}
+
+	return nil, EmptyString
+}
+
+/* Builds a listed resource */
+func newListedResource(name string, size int, modify time.Time, resType ResourceType, mode os.FileMode) *Resource {
+	var mime MIMEType = MIME_Unknown
+
+	if resType == TYPE_File {
+		mime = MIMEFromName(name)
+	}
+
+	return &Resource{name, size, &modify, &UnknownTime, resType, EmptyString, Access.NewEmptyAccessRights(), EmptyString, mime, EmptyString, EmptyString, EmptyString, mode, EmptyString, nil, nil, nil}
+}
+
+/* Unix ls format: drwxr-xr-x 2 user group 4096 Mar 10 14:22 name (symbolic links: name -> target) */
+func parseUnixLine(line string, now time.Time) (*Resource, bool) {
+	var resType ResourceType = TYPE_Other
+	var modify time.Time
+
+	if strings.HasPrefix(strings.ToLower(line), "total ") {
+		return nil, true
+	}
+
+	m := MatchUnixLine.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	month, ok := Months[strings.ToLower(m[6])]
+	if !ok {
+		return nil, false
+	}
+
+	day, _ := strconv.Atoi(m[7])
+	if hour, minute, found := strings.Cut(m[8], ":"); found {
+		h, _ := strconv.Atoi(hour)
+		min, _ := strconv.Atoi(minute)
+		modify = inferYear(month, day, h, min, now)
+	} else {
+		year, _ := strconv.Atoi(m[8])
+		modify = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	mode := parseUnixMode(m[1], m[2])
+	switch m[1] {
+	case "-":
+		resType = TYPE_File
+	case "d":
+		resType = TYPE_Dir
+	case "l":
+		resType = TYPE_Link
+	}
+
+	size, _ := strconv.Atoi(m[5])
+	name, target := m[9], EmptyString
+	if resType == TYPE_Link {
+		name, target, _ = strings.Cut(name, " -> ")
+	}
+
+	r := newListedResource(name, size, modify, resType, mode)
+	r.Owner, r.Group, r.Target = m[3], m[4], target
+
+	if r.Group == EmptyString && m[3] != EmptyString {
+		/* Listings without a group column */
+		r.Group = r.Owner
+	}
+
+	return r, true
+}
+
+/* DOS/IIS format: 03-10-24  02:22PM  <DIR>  name, or 03-10-2024  14:22  1024  name */
+func parseDOSLine(line string, now time.Time) (*Resource, bool) {
+	var resType ResourceType = TYPE_File
+	var mode os.FileMode = 0644
+	var size int
+
+	m := MatchDOSLine.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	month, _ := strconv.Atoi(m[1])
+	day, _ := strconv.Atoi(m[2])
+	year, _ := strconv.Atoi(m[3])
+	hour, _ := strconv.Atoi(m[4])
+	minute, _ := strconv.Atoi(m[5])
+
+	if len(m[3]) == 2 {
+		/* Two digits years (POSIX strptime pivot) */
+		if year < 70 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+
+	if period := strings.ToUpper(m[6]); period == "PM" && hour < 12 {
+		hour += 12
+	} else if period == "AM" && hour == 12 {
+		hour = 0
+	}
+
+	if month < 1 || month > 12 {
+		return nil, false
+	}
+
+	if m[7] == "<DIR>" {
+		resType, mode = TYPE_Dir, os.ModeDir | 0755
+	} else {
+		size, _ = strconv.Atoi(m[7])
+	}
+
+	return newListedResource(m[8], size, time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC), resType, mode), true
+}
+
+/* EPLF format (https://cr.yp.to/ftp/list/eplf.html): +i8388621.29609,m824255902,/,\tname */
+func parseEPLFLine(line string, now time.Time) (*Resource, bool) {
+	var resType ResourceType = TYPE_Other
+	var mode os.FileMode
+	var unique string
+	var size int
+	var modify time.Time = UnknownTime
+
+	if !strings.HasPrefix(line, "+") {
+		return nil, false
+	}
+
+	facts, name, found := strings.Cut(line[1:], "\t")
+	if !found || name == EmptyString {
+		return nil, false
+	}
+
+	for _, fact := range strings.Split(facts, ",") {
+		if fact == EmptyString {
+			continue
+		}
+
+		switch fact[0] {
+		case '/':
+			resType = TYPE_Dir
+		case 'r':
+			resType = TYPE_File
+		case 's':
+			size, _ = strconv.Atoi(fact[1:])
+		case 'm':
+			if seconds, err := strconv.ParseInt(fact[1:], 10, 64); err == nil {
+				modify = time.Unix(seconds, 0).UTC()
+			}
+		case 'i':
+			unique = fact[1:]
+		case 'u':
+			if strings.HasPrefix(fact, "up") {
+				if perm, err := strconv.ParseUint(fact[2:], 8, 32); err == nil {
+					mode = os.FileMode(perm) & os.ModePerm
+				}
+			}
+		}
+	}
+
+	if resType == TYPE_Dir {
+		mode |= os.ModeDir
+	}
+
+	r := newListedResource(name, size, modify, resType, mode)
+	r.Unique = unique
+
+	return r, true
+}
+
+/* VMS format: NAME.EXT;1  213/216  29-JAN-1996 03:33:12  [GROUP,OWNER]  (RWED,RWED,RE,) */
+func parseVMSLine(line string, now time.Time) (*Resource, bool) {
+	var resType ResourceType = TYPE_File
+	var seconds int
+
+	upper := strings.ToUpper(line)
+	if strings.HasPrefix(upper, "DIRECTORY ") || strings.HasPrefix(upper, "TOTAL OF ") || strings.HasPrefix(upper, "GRAND TOTAL ") {
+		return nil, true
+	}
+
+	m := MatchVMSLine.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	month, ok := Months[strings.ToLower(m[5])]
+	if !ok {
+		return nil, false
+	}
+
+	day, _ := strconv.Atoi(m[4])
+	year, _ := strconv.Atoi(m[6])
+	hour, _ := strconv.Atoi(m[7])
+	minute, _ := strconv.Atoi(m[8])
+	if m[9] != EmptyString {
+		seconds, _ = strconv.Atoi(m[9])
+	}
+
+	blocks, _ := strconv.Atoi(m[3])
+	name := m[1]
+	if strings.HasSuffix(strings.ToUpper(name), ".DIR") {
+		resType, name = TYPE_Dir, name[:len(name) - 4]
+	}
+
+	mode := parseVMSProtection(m[11])
+	if resType == TYPE_Dir {
+		mode |= os.ModeDir
+	}
+
+	r := newListedResource(name, blocks * VMSBlockSize, time.Date(year, month, day, hour, minute, seconds, 0, time.UTC), resType, mode)
+	if group, owner, found := strings.Cut(m[10], ","); found {
+		r.Owner, r.Group = owner, group
+	} else {
+		r.Owner = m[10]
+	}
+
+	return r, true
+}
+
+/* Builds the file mode of an ls type character and permissions string (rwxr-xr-x, setuid, setgid and sticky bits
+ included) */
+func parseUnixMode(kind string, perms string) (mode os.FileMode) {
+	switch kind {
+	case "d":
+		mode = os.ModeDir
+	case "l":
+		mode = os.ModeSymlink
+	case "p":
+		mode = os.ModeNamedPipe
+	case "s":
+		mode = os.ModeSocket
+	case "b":
+		mode = os.ModeDevice
+	case "c":
+		mode = os.ModeDevice | os.ModeCharDevice
+	}
+
+	for i, c := range perms {
+		bit := os.FileMode(1) << uint(8 - i)
+
+		switch c {
+		case 'r', 'w', 'x':
+			mode |= bit
+		case 's', 't':
+			mode |= bit | specialBit(i)
+		case 'S', 'T':
+			mode |= specialBit(i)
+		}
+	}
+
+	return
+}
+
+/* Gets the setuid, setgid or sticky bit shown in place of the execute permission at the specified position */
+func specialBit(position int) os.FileMode {
+	switch position {
+	case 2:
+		return os.ModeSetuid
+	case 5:
+		return os.ModeSetgid
+	case 8:
+		return os.ModeSticky
+	}
+
+	return 0
+}
+
+/* Builds the file mode of a VMS protection (system, owner, group, world). System permissions are ignored */
+func parseVMSProtection(protection string) (mode os.FileMode) {
+	classes := strings.Split(protection, ",")
+
+	for i := 1; i < len(classes) && i < 4; i++ {
+		shift := uint(3 * (3 - i))
+
+		for _, c := range strings.ToUpper(classes[i]) {
+			switch c {
+			case 'R':
+				mode |= 4 << shift
+			case 'W', 'D':
+				mode |= 2 << shift
+			case 'E':
+				mode |= 1 << shift
+			}
+		}
+	}
+
+	return
+}
+
+/* Builds a date listed without a year: the current year, unless that would place it in the future */
+func inferYear(month time.Month, day int, hour int, minute int, now time.Time) time.Time {
+	t := time.Date(now.Year(), month, day, hour, minute, 0, 0, time.UTC)
+
+	if t.After(now.Add(FutureDateTolerance)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+
+	return t
+}
@@ -0,0 +1,131 @@
+package resource
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+func TestUnixLine(t *testing.T) {
+	r, ok := parseUnixLine("-rwxr-sr-x   1 ftp      ftp          1024 Mar 10 14:22 report final.pdf", now)
+	if !ok || r == nil {
+		t.Fatal("Unix line not recognized.")
+	}
+
+	if r.Name != "report final.pdf" || r.Size != 1024 || r.Type != TYPE_File || r.Owner != "ftp" || r.Group != "ftp" {
+		t.Fatal("Invalid Unix resource.", r.Name, r.Size, r.Type, r.Owner, r.Group)
+	}
+
+	if r.Mode != os.ModeSetgid | 0755 || !r.Modify.Equal(time.Date(2024, time.March, 10, 14, 22, 0, 0, time.UTC)) || r.MIME != MIME_Binary {
+		t.Fatal("Invalid Unix mode, date or MIME type.", r.Mode, r.Modify, r.MIME)
+	}
+
+	/* Dates in the future belong to the previous year */
+	r, _ = parseUnixLine("drwxr-xr-t   2 ftp      4096 Dec 24 09:00 pub", now)
+	if r.Type != TYPE_Dir || r.Owner != "ftp" || r.Mode != os.ModeDir | os.ModeSticky | 0755 || r.Modify.Year() != 2023 {
+		t.Fatal("Invalid Unix directory without a group.", r.Type, r.Owner, r.Mode, r.Modify)
+	}
+
+	r, _ = parseUnixLine("lrwxrwxrwx   1 root     root            7 Jan  2  2019 latest -> v1.2.3", now)
+	if r.Type != TYPE_Link || r.Name != "latest" || r.Target != "v1.2.3" || r.Mode & os.ModeSymlink == 0 || r.Modify.Year() != 2019 {
+		t.Fatal("Invalid Unix symbolic link.", r.Type, r.Name, r.Target, r.Mode, r.Modify)
+	}
+
+	if r, ok = parseUnixLine("total 12", now); !ok || r != nil {
+		t.Fatal("Unix listing total not skipped.")
+	}
+}
+
+func TestDOSLine(t *testing.T) {
+	r, ok := parseDOSLine("03-10-24  02:22PM       <DIR>          Shared Docs", now)
+	if !ok || r.Type != TYPE_Dir || r.Name != "Shared Docs" || !r.Modify.Equal(time.Date(2024, time.March, 10, 14, 22, 0, 0, time.UTC)) {
+		t.Fatal("Invalid DOS directory.", ok, r)
+	}
+
+	r, ok = parseDOSLine("12-31-1999  23:59               2048 setup.exe", now)
+	if !ok || r.Type != TYPE_File || r.Size != 2048 || r.Modify.Year() != 1999 || r.Modify.Hour() != 23 {
+		t.Fatal("Invalid DOS file.", ok, r)
+	}
+}
+
+func TestEPLFLine(t *testing.T) {
+	r, ok := parseEPLFLine("+i8388621.48594,m825718503,r,s280,up644,\tdjb.html", now)
+	if !ok || r.Type != TYPE_File || r.Size != 280 || r.Unique != "8388621.48594" || r.Mode != 0644 || r.Modify.Unix() != 825718503 {
+		t.Fatal("Invalid EPLF file.", ok, r)
+	}
+
+	if _, ok = parseEPLFLine("-rw-r--r-- 1 ftp ftp 1 Mar 10 14:22 a", now); ok {
+		t.Fatal("Unix line recognized as EPLF.")
+	}
+}
+
+func TestVMSLine(t *testing.T) {
+	r, ok := parseVMSLine("CII-MANUAL.TEX;1  213/216  29-JAN-1996 03:33:12  [ANONYMOU,ANONYMOUS]   (RWED,RWED,,)", now)
+	if !ok || r.Name != "CII-MANUAL.TEX" || r.Size != 213 * VMSBlockSize || r.Owner != "ANONYMOUS" || r.Group != "ANONYMOU" || r.Mode != 0700 {
+		t.Fatal("Invalid VMS file.", ok, r)
+	}
+
+	r, _ = parseVMSLine("SUBDIR.DIR;1  1  1-FEB-2001 10:00:00", now)
+	if r.Type != TYPE_Dir || r.Name != "SUBDIR" || r.Modify.Month() != time.February {
+		t.Fatal("Invalid VMS directory.", r)
+	}
+}
+
+func TestFromList(t *testing.T) {
+	listing := "total 8\r\n" +
+		"drwxr-xr-x   2 ftp      ftp          4096 Mar 10 14:22 .\r\n" +
+		"drwxr-xr-x   2 ftp      ftp          4096 Mar 10 14:22 ..\r\n" +
+		"-rw-r--r--   1 ftp      ftp            12 Mar 10 14:22 hello.txt\r\n" +
+		"lrwxrwxrwx   1 ftp      ftp             9 Mar 10 14:22 hi -> hello.txt\r\n"
+
+	res, err := FromList([]byte(listing))
+	if err != nil || len(res.Content) != 2 || res.Content[0].Name != "hello.txt" || res.Content[1].Target != "hello.txt" {
+		t.Fatal("Invalid Unix listing.", err, res)
+	}
+
+	if res.Content[0].Parent != res {
+		t.Fatal("Listed resources without a parent.")
+	}
+
+	/* Long VMS names wrap the entry on two lines */
+	listing = "Directory DISK$USER:[ANONYMOUS]\r\n\r\n" +
+		"A_VERY_LONG_FILE_NAME_THAT_WRAPS.TXT;3\r\n" +
+		"                   4/6  12-MAR-2024 08:15:00  [USERS,JOE]  (RWED,RWED,RE,R)\r\n" +
+		"TOOLS.DIR;1        1/3  12-MAR-2024 08:15:00  [USERS,JOE]  (RWE,RWE,RE,RE)\r\n\r\n" +
+		"Total of 2 files, 5/9 blocks.\r\n"
+
+	res, err = FromListWithSystem([]byte(listing), "VMS V7.3 is the operating system of this server.")
+	if err != nil || len(res.Content) != 2 || res.Content[0].Name != "A_VERY_LONG_FILE_NAME_THAT_WRAPS.TXT" || !res.Content[1].IsDir() {
+		t.Fatal("Invalid VMS listing.", err, res)
+	}
+
+	if _, err = FromList([]byte("not a listing\r\n")); err == nil || !strings.Contains(err.Error(), "not a listing") {
+		t.Fatal("Invalid listing accepted.", err)
+	}
+}
+
+func TestListFormatFromSystem(t *testing.T) {
+	for system, format := range map[string]string {"UNIX Type: L8": LIST_Unix, "Windows_NT": LIST_DOS, "VMS": LIST_VMS, "OS/2": EmptyString} {
+		if detected := ListFormatFromSystem(system); detected != format {
+			t.Fatal("Invalid LIST format for system.", system, detected)
+		}
+	}
+}
+
+func TestRegisterListParser(t *testing.T) {
+	RegisterListParser("custom", func(line string, now time.Time) (*Resource, bool) {
+		if name, found := strings.CutPrefix(line, "FILE "); found {
+			return newListedResource(name, 0, now, TYPE_File, 0), true
+		}
+
+		return nil, false
+	})
+
+	res, err := FromList([]byte("FILE custom.bin\n"))
+	if err != nil || len(res.Content) != 1 || res.Content[0].Name != "custom.bin" {
+		t.Fatal("Registered LIST parser not used.", err, res)
+	}
+}
@@ -3,8 +3,11 @@ package resource
 import (
 	Access "github.com/ghepesdoru/bookwormFTP/core/access"
 	BaseParser "github.com/ghepesdoru/bookwormFTP/core/parsers/base"
+	"bytes"
 	"fmt"
-	"regexp"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,6 +19,7 @@ const(
 	TYPE_CDir
 	TYPE_PDir
 	TYPE_Other
+	TYPE_Link
 )
 
 type MIMEType int
@@ -29,15 +33,18 @@ const (
 	Comma = 59
 	Equal = 61
 	EmptyString = ""
-	DateFormat = "Jan 2 2008"
+	TimeValFormat = "20060102150405"
 )
 
 var (
-	ExtractListParts = regexp.MustCompile(`(?:^[ldrwx-]*[[:blank:]]*[[:digit:]]*[[:blank:]]*[[:alpha:]]*[[:blank:]]*[[:alpha:]]*[[:blank:]]*)([0-9]*)(?: *)([A-Za-z]*.[0-9]*(?: *)[0-9]*)(?: *)([A-Za-z*_.-]+)*(?:.*)`)
+	ERR_NoMLSTEntry = fmt.Errorf("No resource facts found in the MLST reply.")
 	UnknownTime = time.Unix(0, 0)
 	StringToTYPEMap = map[string]ResourceType {
 		"file": TYPE_File,	"dir": TYPE_Dir,	"cdir": TYPE_CDir,	"pdir": TYPE_PDir,
 	}
+	TYPEToStringMap = map[ResourceType]string {
+		TYPE_File: "file",	TYPE_Dir: "dir",	TYPE_CDir: "cdir",	TYPE_PDir: "pdir",
+	}
 )
 
 /* File type definition */
@@ -52,13 +59,18 @@ type Resource struct {
 	Language	string
 	MIME		MIMEType
 	Charset		string
+	Owner		string
+	Group		string
+	Mode		os.FileMode	/* Unix like permissions and type bits, when listed */
+	Target		string		/* Symbolic link target */
+	Facts		map[string]string	/* All MLSx facts, by lower case fact name (os specific facts included) */
 	Parent		*Resource
 	Content		[]*Resource
 }
 
 /* Instantiates a new resource */
 func NewResource(name string,size int, modify *time.Time, create *time.Time, rType ResourceType, unique string, access *Access.AccessRights, lang string, mime MIMEType, charset string) *Resource {
-	return &Resource{name, size, modify, create, rType, unique, access, lang, mime, charset, nil, nil}
+	return &Resource{name, size, modify, create, rType, unique, access, lang, mime, charset, EmptyString, EmptyString, 0, EmptyString, nil, nil, nil}
 }
 
 /* Extracts the resource from a MLSx formatted list */
@@ -71,6 +83,10 @@ func FromMLSxList(list []byte) (res *Resource, err error) {
 	lines = BaseParser.SplitLines(list)
 
 	for _, l := range lines {
+		if l = BaseParser.Trim(l); len(l) == 0 {
+			continue
+		}
+
 		r, err = parseMLSx(l)
 
 		if err != nil {
@@ -99,68 +115,93 @@ func FromMLSxList(list []byte) (res *Resource, err error) {
 	return
 }
 
-/* Extracts the resource from a List formatted list */
-func FromList(list []byte) (res *Resource, err error) {
-	var lines [][]byte
-	var r *Resource
-	var length, size int
-	var name string
-	var modified time.Time
+/* Extracts the resource from a MLST reply. The facts line is the only reply line starting with facts (RFC3659 7.2) */
+func FromMLST(reply []byte) (*Resource, error) {
+	for _, line := range BaseParser.SplitLines(reply) {
+		line = BaseParser.Trim(line)
+
+		if i := bytes.IndexByte(line, ' '); i > 0 && line[i - 1] == ';' {
+			return parseMLSx(line)
+		}
+	}
+
+	return nil, ERR_NoMLSTEntry
+}
+
+/* Instantiates a new resource describing a local file or directory, with the specified access rights */
+func FromFileInfo(info os.FileInfo, access *Access.AccessRights) *Resource {
 	var resType ResourceType = TYPE_Other
 	var mime MIMEType = MIME_Unknown
+	var size int
+	modify := info.ModTime().UTC()
+
+	if info.IsDir() {
+		resType = TYPE_Dir
+	} else if info.Mode().IsRegular() {
+		resType = TYPE_File
+		size = int(info.Size())
+		mime = MIMEFromName(info.Name())
+	}
 
-	/* Define a virtual container for resource functionality uniformity. */
-	res = &Resource{".", size, &UnknownTime, &UnknownTime, TYPE_Dir, EmptyString, Access.NewEmptyAccessRights(), EmptyString, mime, EmptyString, nil, nil}
-	lines = BaseParser.SplitLines(list)
-	for _, l := range lines {
-		/* Extract current line's content */
-		parts := ExtractListParts.FindAllSubmatch(l, -1)
-		for _, matches := range parts {
-			length = len(matches)
-
-			if length == 4 {
-				size = BaseParser.ToInt(matches[1])
-				modified, err = time.Parse(DateFormat, string(matches[2]))
-				name = string(matches[3])
-
-				if nil == err {
-					/* Extract mime type and normalize resource type using available information */
-					mime = determineMIME(name)
-
-					if mime != MIME_Unknown {
-						resType = TYPE_File
-					} else {
-						resType = TYPE_Dir
-					}
-
-					r = &Resource{name, size, &modified, &UnknownTime, resType, EmptyString, Access.NewEmptyAccessRights(), EmptyString, mime, EmptyString, nil, nil}
-					res.Content = append(res.Content, r)
-				}
-			}
-		}
+	if access == nil {
+		access = Access.NewEmptyAccessRights()
 	}
 
-	return
+	return &Resource{info.Name(), size, &modify, &UnknownTime, resType, EmptyString, access, EmptyString, mime, EmptyString, EmptyString, EmptyString, info.Mode(), EmptyString, nil, nil, nil}
+}
+
+/* Generates the MLSx line representation of the current resource (facts followed by the resource name, RFC3659 7.2) */
+func (r *Resource) ToMLSx() []byte {
+	var line []byte
+
+	if t, ok := TYPEToStringMap[r.Type]; ok {
+		line = append(line, fmt.Sprintf("type=%s;", t)...)
+	}
+
+	if r.IsFile() {
+		line = append(line, fmt.Sprintf("size=%d;", r.Size)...)
+	}
+
+	if r.Modify != nil && !r.Modify.Equal(UnknownTime) {
+		line = append(line, fmt.Sprintf("modify=%s;", r.Modify.UTC().Format(TimeValFormat))...)
+	}
+
+	if r.Permissions != nil {
+		line = append(line, fmt.Sprintf("perm=%s;", r.Permissions.ToPermString())...)
+	}
+
+	if r.Unique != EmptyString {
+		line = append(line, fmt.Sprintf("unique=%s;", r.Unique)...)
+	}
+
+	return append(append(line, ' '), r.Name...)
 }
 
 /* Brakes a single MLSx response line into it's component parts and fills a resource with found information */
 func parseMLSx (line []byte) (res *Resource, err error) {
-	var length int = len(line) - 1
 	var start, end, fEnd int = 0, -1, -1
-	var name, unique, language, charset string
+	var name, unique, language, charset, mediaType, target string
 	var fact, value []byte
 	var size int
 	var modify, create *time.Time = &UnknownTime, &UnknownTime
 	var resType ResourceType = TYPE_Other
 	var perms *Access.AccessRights
 	var mime MIMEType = MIME_Unknown
+	var facts map[string]string = make(map[string]string)
 
 	for i, c := range line {
 		if c == Comma {
 			/* End of resource fact */
 			end = i
+			if fEnd < start {
+				/* Fact without a value */
+				fEnd = end
+				value = nil
+			} else {
+				value = line[fEnd+1:end]
+			}
 			fact = BaseParser.ToLower(line[start:fEnd])
-			value = line[fEnd+1:end]
+			facts[string(fact)] = string(value)
 
 			switch string(fact) {
 			case "size":
@@ -170,11 +211,19 @@ func parseMLSx (line []byte) (res *Resource, err error) {
 			case "create":
 				create, err = BaseParser.ParseTimeVal(value)
 			case "type":
-				if v, ok := StringToTYPEMap[string(value)]; ok {
+				if v, ok := StringToTYPEMap[string(BaseParser.ToLower(value))]; ok {
 					resType = v
+				} else if t, ok := linkTarget(string(value)); ok {
+					resType, target = TYPE_Link, t
 				}
-			case "unique", "lang", "charset":
+			case "unique":
 				unique = string(value)
+			case "lang":
+				language = string(value)
+			case "charset":
+				charset = string(value)
+			case "media-type":
+				mediaType = string(value)
 			case "perm":
 				perms = Access.FromPermString(value)
 			}
@@ -183,10 +232,9 @@ func parseMLSx (line []byte) (res *Resource, err error) {
 		} else if BaseParser.IsWhitespace(c) {
 			/* Start of resource name */
 			start = i + 1
-			end = length
-			name = string(BaseParser.Trim(line[start:end]))
+			name = string(BaseParser.Trim(line[start:]))
 			break
-		} else if c == Equal {
+		} else if c == Equal && fEnd < start {
 			fEnd = i
 		}
 
@@ -196,18 +244,21 @@ func parseMLSx (line []byte) (res *Resource, err error) {
 	}
 
 	if resType == TYPE_File && len(name) > 0 {
-		ext := BaseParser.SplitOnSeparator([]byte(name), []byte{BaseParser.CONST_Dot})
-		length = len(ext)
-
-		if length > 0 {
-			mime = determineMIME(string(ext[length - 1]))
+		if mediaType != EmptyString {
+			mime = mimeFromMediaType(mediaType)
 		} else {
-			mime = determineMIME("")
+			mime = MIMEFromName(name)
 		}
 	}
 
 	if err == nil {
-		res = &Resource{name, size, modify, create, resType, unique, perms, language, mime, charset, nil, nil}
+		res = &Resource{name, size, modify, create, resType, unique, perms, language, mime, charset, EmptyString, EmptyString, 0, target, facts, nil, nil}
+		res.Owner = res.firstFact("unix.ownername", "unix.owner", "unix.uid")
+		res.Group = res.firstFact("unix.groupname", "unix.group", "unix.gid")
+
+		if mode, ok := res.UnixMode(); ok {
+			res.Mode = mode
+		}
 	} else {
 		/* Debug point */
 //		fmt.Println("Resource build error:", err)
@@ -216,6 +267,124 @@ func parseMLSx (line []byte) (res *Resource, err error) {
 	return
 }
 
+/* Gets the value of the specified MLSx fact (case insensitive fact name, ex: "UNIX.mode") */
+func (r *Resource) Fact(name string) (value string, ok bool) {
+	if r.Facts != nil {
+		value, ok = r.Facts[strings.ToLower(name)]
+	}
+
+	return
+}
+
+/* Gets the file mode described by the UNIX.mode fact (permissions, setuid, setgid and sticky bits), including the
+ resource type bits */
+func (r *Resource) UnixMode() (mode os.FileMode, ok bool) {
+	value, ok := r.Fact("UNIX.mode")
+	if !ok {
+		return
+	}
+
+	bits, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	mode = os.FileMode(bits) & os.ModePerm
+	if bits & 04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+
+	if bits & 02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+
+	if bits & 01000 != 0 {
+		mode |= os.ModeSticky
+	}
+
+	switch r.Type {
+	case TYPE_Dir, TYPE_CDir, TYPE_PDir:
+		mode |= os.ModeDir
+	case TYPE_Link:
+		mode |= os.ModeSymlink
+	}
+
+	return mode, true
+}
+
+/* Gets the numeric owner id (UNIX.uid or numeric UNIX.owner fact) */
+func (r *Resource) UID() (int, bool) {
+	return r.numericFact("UNIX.uid", "UNIX.owner")
+}
+
+/* Gets the numeric group id (UNIX.gid or numeric UNIX.group fact) */
+func (r *Resource) GID() (int, bool) {
+	return r.numericFact("UNIX.gid", "UNIX.group")
+}
+
+/* Gets the IANA media type of the resource (media-type fact, ex: "text/plain"), or an empty string if unknown */
+func (r *Resource) MediaType() string {
+	mediaType, _ := r.Fact("media-type")
+	return mediaType
+}
+
+/* Gets the value of the first available fact of the specified ones */
+func (r *Resource) firstFact(names ...string) string {
+	for _, name := range names {
+		if value, ok := r.Fact(name); ok && value != EmptyString {
+			return value
+		}
+	}
+
+	return EmptyString
+}
+
+/* Gets the first available numeric fact of the specified ones */
+func (r *Resource) numericFact(names ...string) (int, bool) {
+	for _, name := range names {
+		if value, ok := r.Fact(name); ok {
+			if id, err := strconv.Atoi(value); err == nil {
+				return id, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+/* Extracts the target of a symbolic link MLSx type (ex: "OS.unix=slink:/target"). Links without a listed target
+ ("OS.unix=symlink") have an empty target */
+func linkTarget(resType string) (target string, ok bool) {
+	kind, target, _ := strings.Cut(resType, ":")
+
+	switch strings.ToLower(kind) {
+	case "os.unix=slink", "os.unix=symlink":
+		return target, true
+	}
+
+	return EmptyString, false
+}
+
+/* Determine a file's MIME type based on it's IANA media type */
+func mimeFromMediaType(mediaType string) MIMEType {
+	if strings.HasPrefix(strings.ToLower(mediaType), "text/") {
+		return MIME_Text
+	}
+
+	return MIME_Binary
+}
+
+/* Determine a file's MIME type based on it's name extension */
+func MIMEFromName(name string) MIMEType {
+	ext := BaseParser.SplitOnSeparator([]byte(name), []byte{BaseParser.CONST_Dot})
+
+	if length := len(ext); length > 0 {
+		return determineMIME(string(ext[length - 1]))
+	}
+
+	return determineMIME("")
+}
+
 /* Determine a file's MIME type (reused for data connection configuration) */
 func determineMIME(fileExtension string) (mime MIMEType) {
 	switch fileExtension {
@@ -289,7 +458,7 @@ func (r *Resource) CanBeRemoved() bool {
 
 /* Checks if the current resource can be downloaded */
 func (r *Resource) CanBeRetrieved() bool {
-	return (r.IsFile() || r.Type == TYPE_Other) && r.Permissions.Contains(Access.PERM_Retrievable)
+	return (r.IsFile() || r.Type == TYPE_Other || r.Type == TYPE_Link) && r.Permissions.Contains(Access.PERM_Retrievable)
 }
 
 /* Checks if the current resource can be stored (STOR) */
@@ -341,7 +510,7 @@ func (r *Resource) IsBinary() bool {
 
 /* Checks if the current resource represents the child of a parent resource (excludes self and parent dir) */
 func (r *Resource) IsChild() bool {
-	return r.Type == TYPE_Dir || r.Type == TYPE_File || r.Type == TYPE_Other
+	return r.Type == TYPE_Dir || r.Type == TYPE_File || r.Type == TYPE_Other || r.Type == TYPE_Link
 }
 
 /* Checks if the current resource is the current container directory */
@@ -359,6 +528,11 @@ func (r *Resource) IsFile() bool {
 	return r.Type == TYPE_File
 }
 
+/* Checks if the current resource is a symbolic link (see Target) */
+func (r *Resource) IsLink() bool {
+	return r.Type == TYPE_Link
+}
+
 /* Checks if the current resource is a the container directory */
 func (r *Resource) IsParentDir() bool {
 	return r.Type == TYPE_PDir
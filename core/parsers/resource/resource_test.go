@@ -0,0 +1,61 @@
+package resource
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMLSxFacts(t *testing.T) {
+	listing := "type=cdir;perm=flcdmpe;UNIX.mode=0755;UNIX.owner=1000;UNIX.group=100; .\r\n" +
+		"Type=file;Size=42;modify=20240310142200;UNIX.mode=4750;UNIX.ownername=joe;UNIX.uid=1000;UNIX.groupname=staff;UNIX.gid=50;media-type=text/x-script;lang=en;charset=UTF-8;unique=801U1A;OS.unix=slink:/x=y; run.sh\r\n"
+
+	res, err := FromMLSxList([]byte(listing))
+	if err != nil || len(res.Content) != 1 {
+		t.Fatal("Unable to parse the MLSx listing.", err, res)
+	}
+
+	if mode, ok := res.UnixMode(); !ok || mode != os.ModeDir | 0755 || res.Mode != mode || res.Owner != "1000" {
+		t.Fatal("Invalid container mode or owner.", mode, res.Owner)
+	}
+
+	r := res.Content[0]
+	if r.Name != "run.sh" || r.Size != 42 || r.Language != "en" || r.Charset != "UTF-8" || r.Unique != "801U1A" || r.MIME != MIME_Text {
+		t.Fatal("Invalid resource facts.", r.Name, r.Size, r.Language, r.Charset, r.Unique, r.MIME)
+	}
+
+	if r.Mode != os.ModeSetuid | 0750 || r.Owner != "joe" || r.Group != "staff" || r.MediaType() != "text/x-script" {
+		t.Fatal("Invalid UNIX facts.", r.Mode, r.Owner, r.Group, r.MediaType())
+	}
+
+	if uid, ok := r.UID(); !ok || uid != 1000 {
+		t.Fatal("Invalid owner id.", uid)
+	}
+
+	if gid, ok := r.GID(); !ok || gid != 50 {
+		t.Fatal("Invalid group id.", gid)
+	}
+
+	/* Os specific facts values can contain an equal sign */
+	if value, ok := r.Fact("os.UNIX"); !ok || value != "slink:/x=y" {
+		t.Fatal("Invalid os specific fact.", value)
+	}
+}
+
+func TestMLSxLink(t *testing.T) {
+	listing := "type=cdir;perm=el; /pub\r\n" +
+		"type=OS.unix=slink:/pub/releases;UNIX.mode=0777; latest\r\n" +
+		"type=OS.unix=symlink; other\r\n"
+
+	res, err := FromMLSxList([]byte(listing))
+	if err != nil || len(res.Content) != 2 {
+		t.Fatal("Unable to parse the MLSx listing.", err, res)
+	}
+
+	if r := res.Content[0]; !r.IsLink() || r.Target != "/pub/releases" || r.Mode != os.ModeSymlink | 0777 || !r.IsChild() {
+		t.Fatal("Invalid symbolic link.", r.Type, r.Target, r.Mode)
+	}
+
+	if r := res.Content[1]; !r.IsLink() || r.Target != EmptyString {
+		t.Fatal("Invalid symbolic link without a target.", r.Type, r.Target)
+	}
+}
@@ -100,6 +100,10 @@ func (p *PathManager) ChangeCurrentDir(currentDir string) (ok bool, err error) {
 		currentDir = p.Clean(d)
 	}
 
+	sep := "."
+	pSep := p.GetSeparator()
+	sep1 := sep + pSep
+
 	if p.IsAbs(currentDir) {
 		/* Make the current directory relative */
 		currentDir, err = p.Rel(p.rootDir, currentDir)
@@ -109,13 +113,14 @@ func (p *PathManager) ChangeCurrentDir(currentDir string) (ok bool, err error) {
 		}
 
 		/* Replace the current directory for absolute paths */
-		p.currentDir = currentDir
+		if currentDir == sep || sep1 == currentDir {
+			p.currentDir = EmptyString
+		} else {
+			p.currentDir = strings.TrimRight(currentDir, pSep) + pSep
+		}
 	} else {
 		currentDir = p.Clean(currentDir)
 		p.currentDir = p.Clean(p.currentDir)
-		sep := "."
-		pSep := p.GetSeparator()
-		sep1 := sep + pSep
 
 		if currentDir == sep || sep1 == currentDir {
 			currentDir = EmptyString
@@ -125,12 +130,9 @@ func (p *PathManager) ChangeCurrentDir(currentDir string) (ok bool, err error) {
 			p.currentDir = EmptyString
 		}
 
-		if p.currentDir != EmptyString {
-			/* Build the current path based on the existing current dir */
-			p.currentDir = p.currentDir + p.Clean(currentDir) + pSep
-		} else {
+		if currentDir != EmptyString {
 			/* Build the current path based on the existing current dir */
-			p.currentDir = p.Clean(currentDir) + pSep
+			p.currentDir = p.currentDir + strings.TrimRight(currentDir, pSep) + pSep
 		}
 	}
 
@@ -139,8 +141,8 @@ func (p *PathManager) ChangeCurrentDir(currentDir string) (ok bool, err error) {
 
 /* Changes the root directory resetting the current directory */
 func (p *PathManager) ChangeRoot(rootDir string) (ok bool, err error) {
-	rootDir, _ = p.Split(rootDir)
-	rootDir = p.Clean(rootDir)
+	/* The root is always a directory, even if specified without a trailing separator */
+	rootDir = p.Clean(strings.TrimRight(rootDir, p.GetSeparator()) + p.GetSeparator())
 	if !p.IsAbs(rootDir) {
 		/* Consider the specified root directory as being relative to the current working directory */
 		rootDir, err = p.Abs(rootDir)
@@ -173,7 +175,11 @@ func (p *PathManager) Clean(path string) string {
 		}
 	} else {
 		if f == EmptyString {
-			return FilePath.Clean(d) + string(FilePath.Separator)
+			if d == string(FilePath.Separator) {
+				return d
+			} else {
+				return FilePath.Clean(d) + string(FilePath.Separator)
+			}
 		} else {
 			return FilePath.Clean(path)
 		}
@@ -226,8 +232,8 @@ func (p *PathManager) ExtractSubPath(path string) []string {
 		l2 := len(path)
 
 		/* If it's the same directory ignore the case, we have nothing to return */
-		if l1 != l2 {
-			return p.SplitDirList(path[l2:])
+		if l1 < l2 {
+			return p.SplitDirList(path[l1:])
 		}
 	}
 
@@ -238,9 +244,11 @@ func (p *PathManager) ExtractSubPath(path string) []string {
 func (p *PathManager) _getCurrentDir() string {
 	if p.currentDir == EmptyString {
 		return EmptyString
-	} else {
-		return p.Clean(p.currentDir)
+	} else if dir := p.Clean(p.currentDir); dir != ("." + p.GetSeparator()) {
+		return dir
 	}
+
+	return EmptyString
 }
 
 /* Current directory path getter. It will always return an absolute path. */
@@ -304,7 +312,7 @@ func (p *PathManager) InCurrentDir(path string) bool {
 
 		if l1 < l2 {
 			/* The current path has to be either a subdirectory or a non related path at this point */
-			if path[:l1 - 1] == curr {
+			if path[:l1] == curr {
 				/* This is a path descending from the current path */
 				return true
 			}
@@ -316,7 +324,7 @@ func (p *PathManager) InCurrentDir(path string) bool {
 
 /* Checks if the current dir is the root dir */
 func (p *PathManager) InRootDir() bool {
-	return p.rootDir == p.currentDir
+	return p._getCurrentDir() == EmptyString
 }
 
 /* Iterate the specified file name */
@@ -465,7 +473,7 @@ func (p *PathManager) ToCurrentDir(fileName string) string {
 	}
 
 	/* Remove local path from directory concatenation */
-	if dir == ("." + p.GetSeparator()) {
+	if dir == "." || dir == ("." + p.GetSeparator()) {
 		dir = ""
 	}
 
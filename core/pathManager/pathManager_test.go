@@ -0,0 +1,55 @@
+package pathManager
+
+import (
+	"testing"
+)
+
+func TestUnixNavigation(t *testing.T) {
+	p, err := NewUnixPathManagerAt("/")
+	if err != nil {
+		t.Fatal("Unable to instantiate a unix path manager.", err)
+	}
+
+	if p.GetCurrentDir() != "/" || !p.InRootDir() {
+		t.Fatal("Invalid root current directory.", p.GetCurrentDir())
+	}
+
+	p.ChangeCurrentDir("/up")
+	if p.GetCurrentDir() != "/up/" {
+		t.Fatal("Invalid current directory after absolute navigation.", p.GetCurrentDir())
+	}
+
+	if p.ToCurrentDir("sub") != "/up/sub" || p.ToCurrentDir("/up/sub") != "/up/sub" {
+		t.Fatal("Invalid path attachment to the current directory.", p.ToCurrentDir("sub"), p.ToCurrentDir("/up/sub"))
+	}
+
+	p.ChangeCurrentDir("sub")
+	if p.GetCurrentDir() != "/up/sub/" {
+		t.Fatal("Invalid current directory after relative navigation.", p.GetCurrentDir())
+	}
+
+	if !p.InCurrentDir("/up/sub/file") {
+		t.Fatal("Descendant path not recognised as part of the current directory.")
+	}
+
+	p.ChangeCurrentDir("./../")
+	p.ChangeCurrentDir("./../")
+	if p.GetCurrentDir() != "/" || !p.InRootDir() {
+		t.Fatal("Invalid current directory after navigating to the parent directory.", p.GetCurrentDir())
+	}
+}
+
+func TestRootWithoutSeparator(t *testing.T) {
+	p, err := NewPathManagerAt("/tmp")
+	if err != nil {
+		t.Fatal("Unable to instantiate a path manager.", err)
+	}
+
+	if p.GetCurrentDir() != "/tmp/" {
+		t.Fatal("The last root directory segment was dropped.", p.GetCurrentDir())
+	}
+
+	if p.ToCurrentDir("file.txt") != "/tmp/file.txt" {
+		t.Fatal("Invalid file path in the root directory.", p.ToCurrentDir("file.txt"))
+	}
+}
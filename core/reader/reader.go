@@ -1,249 +1,191 @@
 package reader
 
 import (
-	"fmt"
-	"io"
-	"bytes"
 	"bufio"
-	"time"
-)
-
-type SIG_Status int
-const (
-	SIG_WaitingForData	SIG_Status = iota
-	SIG_DataRead
-	SIG_Done
+	"bytes"
+	"io"
+	"sync/atomic"
 )
 
 const (
-	MAX_RESPONSE_SIZE         = 24
-	READING_ACCEPTED_FAILURES = 4
+	ControlBufferSize	= 4 * 1024	/* Control connections read buffer size */
+	DataBufferSize		= 64 * 1024	/* Data connections read buffer size */
+	ReplyCodeLength		= 3
+	MultiLineReplyMark	= '-'
 )
 
-/* Read delay constant */
-var (
-	DELAY_READ             time.Duration = 100 * time.Millisecond
-	DELAY_WAIT_FOR_READ    time.Duration = 100 * time.Millisecond
-	ConnectionClosed               		 = "use of closed network connection"
-	ERROR_EOF                            = io.EOF
-	STATUS                               = map[SIG_Status]string{
-		SIG_WaitingForData: "Waiting for data",
-		SIG_DataRead:       "Data read",
-		SIG_Done:           "Action completed successfully",
-	}
-)
-
-/* Bookworm FTP reader */
+/* Bookworm FTP reader. Control readers block until a complete server reply is available, while data readers stream the
+ data connection contents to their destination on a separate goroutine */
 type Reader struct {
 	source		io.Reader
-	destination	*bufio.Writer
+	lines		*bufio.Reader
+	partialLine	[]byte
+	reply		[]byte
+	replyCode	[]byte
+	destination	io.Writer
 	buffer		*bytes.Buffer
-	nRBytes		int
-	nWBytes		int
+	nRBytes		int64
+	nWBytes		int64
 	err			error
-	status		SIG_Status
-	sourceOk	bool
-	active		bool
-	outsource	bool
+	done		chan struct{}
 }
 
-/* Instantiate a new Reader */
-func NewReader(source io.Reader) (reader *Reader) {
-	destination := new(bytes.Buffer)
-	reader = &Reader{source, nil, destination, 0, 0, nil, SIG_Done, true, false, false}
-	reader.attachDestination(destination)
-	return
+/* Instantiate a new control connection Reader */
+func NewReader(source io.Reader) *Reader {
+	return &Reader{source: source, lines: bufio.NewReaderSize(source, ControlBufferSize)}
 }
 
-/* Attach a destination writer to the current reader */
-func (r *Reader) AttachDestination(w interface{}) {
-	r.attachDestination(w)
-	r.outsource = true
-}
+/* Instantiate a new data connection Reader, and start streaming the source contents to the destination writer. Without a
+ destination writer, the contents are collected in memory (see Get) */
+func NewDataReader(source io.Reader, destination io.Writer) (reader *Reader) {
+	reader = &Reader{source: source, destination: destination, done: make(chan struct{})}
 
-/* Data getter */
-func (r *Reader) Get() []byte {
-	if !r.outsource {
-		return r.getRaw(0, READING_ACCEPTED_FAILURES, true)
+	if destination == nil {
+		reader.buffer = new(bytes.Buffer)
+		reader.destination = reader.buffer
 	}
 
-	return []byte{}
+	go reader.stream()
+	return
 }
 
-/* Data get blocking for a longer time */
-func (r *Reader) GetBlock() []byte {
-	if !r.outsource {
-		return r.getRaw(0, READING_ACCEPTED_FAILURES*READING_ACCEPTED_FAILURES, true)
+/* Signals the end of the data stream (end of file or error) */
+func (r *Reader) Done() <-chan struct{} {
+	return r.done
+}
+
+/* Data getter. Returns the collected data once the data stream ended */
+func (r *Reader) Get() []byte {
+	if r.buffer != nil && !r.IsActive() {
+		return r.buffer.Bytes()
 	}
 
 	return []byte{}
 }
 
-/* Last encountered error getter */
+/* Last encountered error getter (end of file excluded) */
 func (r *Reader) GetError() error {
-	return r.err
-}
-
-/* Data getter. No extra delays */
-func (r *Reader) GetNow() []byte {
-	if !r.outsource {
-		return r.getRaw(0, 0, true)
+	if r.IsActive() {
+		return nil
 	}
 
-	return []byte{}
+	return r.err
 }
 
 /* Get the number of read bytes */
 func (r *Reader) GetReadBytes() int {
-	return r.nRBytes
+	return int(atomic.LoadInt64(&r.nRBytes))
 }
 
 /* Get the number of written bytes */
 func (r *Reader) GetWrittenBytes() int {
-	return r.nWBytes
-}
-
-/* Checks if the reader encountered any errors */
-func (r *Reader) HasErrors() bool {
-	return r.err == nil
+	return int(atomic.LoadInt64(&r.nWBytes))
 }
 
-/* Checks if the reader is active */
+/* Checks if the reader is still streaming data */
 func (r *Reader) IsActive() bool {
-	return r.active && r.sourceOk
-}
-
-/* Peek to the raw data contents at any given time without flushing the buffer */
-func (r *Reader) Peek() []byte {
-	if !r.outsource {
-		return r.getRaw(0, READING_ACCEPTED_FAILURES, false)
+	if r.done == nil {
+		return false
 	}
 
-	return []byte{}
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
 }
 
-/* io.Reader interface implementation */
-func (r *Reader) Read(p []byte) (n int, err error) {
-	n, err = r.source.Read(p)
-	if err != nil {
-		if err == ERROR_EOF || ConnectionClosed == err.Error() {
-			r.sourceOk = false
+/* Blocks until a complete server reply is read, including all the lines of multiple line replies (RFC959 4.2). Partially
+ read replies are kept on errors (expired deadlines), and completed by the following call */
+func (r *Reader) ReadReply() (reply []byte, err error) {
+	var line []byte
+
+	for {
+		if line, err = r.readLine(); err != nil {
+			r.err = err
+			return nil, err
 		}
 
-		r.err = err
-		r.StopReading()
-	} else {
-		r.nRBytes += n
-	}
+		r.reply = append(r.reply, line...)
 
-	return
-}
+		if r.replyCode == nil {
+			if len(line) <= ReplyCodeLength || line[ReplyCodeLength] != MultiLineReplyMark {
+				/* Single line reply (invalid replies included, the parser reports them) */
+				break
+			}
 
-/* Reset the internal buffer if the current reader is not outsourcing */
-func (r *Reader) Reset() {
-	if !r.outsource {
-		r.buffer.Reset()
+			r.replyCode = line[:ReplyCodeLength]
+		} else if len(line) > ReplyCodeLength && bytes.HasPrefix(line, r.replyCode) && line[ReplyCodeLength] != MultiLineReplyMark {
+			/* Last line of a multiple line reply */
+			break
+		}
 	}
 
-	r.nRBytes = 0
-	r.nWBytes = 0
+	reply, r.reply, r.replyCode = r.reply, nil, nil
+	return reply, nil
 }
 
-/* Returns a human readable description of the current reader's status */
-func (r *Reader) Status() string {
-	if s, ok := STATUS[r.status]; ok {
-		return s
+/* Wait for the data stream to end. Returns the encountered error, if any */
+func (r *Reader) Wait() error {
+	if r.done != nil {
+		<-r.done
 	}
 
-	return ""
+	return r.err
 }
 
-/* Returns the code associated with the current status */
-func (r *Reader) StatusCode() int {
-	return int(r.status)
-}
+/* Reads a complete line (CRLF terminated) */
+func (r *Reader) readLine() (line []byte, err error) {
+	var chunk []byte
 
-/* Stops the reader from reading any other input */
-func (r *Reader) StopReading() {
-	r.active = false
-}
+	for {
+		chunk, err = r.lines.ReadSlice('\n')
+		r.partialLine = append(r.partialLine, chunk...)
+		atomic.AddInt64(&r.nRBytes, int64(len(chunk)))
 
-/* io.Writer interface implementation */
-func (r *Reader) Write(p []byte) (n int, err error) {
-	n, err = r.destination.Write(p)
-	if err != nil {
-		r.err = err
-		r.StopReading()
-	} else {
-		err = r.destination.Flush()
-
-		if err == nil {
-			r.nWBytes += n
-		} else {
-			r.err = err
-			r.StopReading()
-			fmt.Println("Could not flush due to: ", err)
+		if err != bufio.ErrBufferFull {
+			break
 		}
 	}
 
-	return
-}
-
-/* Attach a new destination io.Writer to the current infinite reading process */
-func (r *Reader) attachDestination(w interface{}) {
-	/* Stop reading if active */
-	if r.IsActive() {
-		r.StopReading()
+	if err != nil {
+		return nil, err
 	}
 
-	/* Start listening for content */
-	r.destination = bufio.NewWriter(w.(io.Writer))
-	go r.listen()
-	time.Sleep(DELAY_WAIT_FOR_READ)
+	line, r.partialLine = r.partialLine, nil
+	return line, nil
 }
 
-/* Raw data getter, supports a number of failures and imposes delays */
-func (r *Reader) getRaw(n int, READING_ACCEPTED_FAILURES int, flush bool) []byte {
-	if n < READING_ACCEPTED_FAILURES {
-		if r.status == SIG_WaitingForData {
-			/* If the reader is waiting for data, offer it a delay of DELAY_WAIT_FOR_READ before restart */
-			time.Sleep(DELAY_WAIT_FOR_READ)
-			return r.getRaw(n+1, READING_ACCEPTED_FAILURES, flush)
-		} else {
-			/* If the reader has read some data, offer it a few more milliseconds, maybe the response
-			spawns on multiple lines */
-			time.Sleep(DELAY_READ)
-			return r.getRaw(n+1, READING_ACCEPTED_FAILURES, flush)
-		}
-	} else if !r.outsource {
-		data := r.buffer.Bytes()
-		if flush {
-			r.Reset()
-		}
+/* Streams the source contents to the destination until the end of file or the first error */
+func (r *Reader) stream() {
+	var buffer []byte = make([]byte, DataBufferSize)
+	var n int
+	var err error
 
-		return data
-	}
+	defer close(r.done)
 
-	return []byte{}
-}
+	for {
+		n, err = r.source.Read(buffer)
 
-/* Listen for data */
-func (r *Reader) listen() {
-	var data []byte = make([]byte, MAX_RESPONSE_SIZE)
-	var n int
-	var err error
+		if n > 0 {
+			atomic.AddInt64(&r.nRBytes, int64(n))
 
-	r.active = true
+			written, writeErr := r.destination.Write(buffer[:n])
+			atomic.AddInt64(&r.nWBytes, int64(written))
 
-	for r.IsActive() {
-		r.status = SIG_WaitingForData
-		if n, err = r.Read(data); err == nil {
-			r.status = SIG_DataRead
-			n, err = r.Write(data[:n])
+			if writeErr != nil {
+				r.err = writeErr
+				return
+			}
+		}
 
-			if r.outsource {
-//				fmt.Println("Read: ", r.nRBytes, "Wrote: ", r.nWBytes)
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
 			}
+
+			return
 		}
 	}
 }
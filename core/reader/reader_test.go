@@ -1,92 +1,120 @@
 package reader
 
 import (
-	"testing"
+	"bytes"
+	"errors"
 	"io"
+	"strings"
+	"testing"
+	"time"
 )
 
 /* Definition of global variables */
 var (
-	testGlobals struct {
-		pipeRead	*io.PipeReader
-		pipeWrite	*io.PipeWriter
-		reader		*Reader
-	}
-	WELCOME = []byte("200 Server Ready \r\n")
+	WELCOME = []byte("220 Server Ready \r\n")
+	FEATURES = []byte("211-Features:\r\n MDTM\r\n211-Not the last line\r\n SIZE\r\n211 End\r\n")
 )
 
-/* Initializes a new "server" to serve our FTP like messages */
-func TestInitFakeServer(t *testing.T) {
-	testGlobals.pipeRead, testGlobals.pipeWrite = io.Pipe()
+/* Tests single line reply reading */
+func TestReadReply(t *testing.T) {
+	reader := NewReader(bytes.NewReader(append(WELCOME, "200 NOOP ok\r\n"...)))
 
-	if testGlobals.pipeWrite == nil || testGlobals.pipeRead == nil {
-		t.Log("Unable to establish test pipe", testGlobals.pipeRead, testGlobals.pipeWrite)
-		t.Fail()
+	if reply, err := reader.ReadReply(); err != nil || string(reply) != string(WELCOME) {
+		t.Fatal("Invalid reading: ", string(reply), err)
 	}
-}
-
-/* Tests reader instantiation based on a local tcp connection - same machine */
-func TestReaderInstantiation(t *testing.T) {
-	reader := NewReader(testGlobals.pipeRead)
 
-	if !reader.IsActive() {
-		t.Fatal("Unable to initialize a Reader.")
+	if reply, err := reader.ReadReply(); err != nil || string(reply) != "200 NOOP ok\r\n" {
+		t.Fatal("Invalid reading: ", string(reply), err)
 	}
 
-	if reader.Status() != STATUS[SIG_Done] {
-		t.Fatal("Invalid reader status. Status: ", reader.Status() + "end of status")
+	if _, err := reader.ReadReply(); err != io.EOF {
+		t.Fatal("Invalid end of file error: ", err)
 	}
 
-	testGlobals.reader = reader
+	if reader.IsActive() {
+		t.Fatal("Control readers should never be active.")
+	}
 }
 
-/* Tests get capabilities of the current reader */
-func TestGet(t *testing.T) {
-	testGlobals.pipeWrite.Write(WELCOME)
-	data := testGlobals.reader.Get()
+/* Tests multiple line replies, including inner lines starting with the reply code */
+func TestReadMultiLineReply(t *testing.T) {
+	reader := NewReader(bytes.NewReader(append(FEATURES, WELCOME...)))
+
+	if reply, err := reader.ReadReply(); err != nil || string(reply) != string(FEATURES) {
+		t.Fatal("Invalid multiple line reading: ", string(reply), err)
+	}
 
-	if len(data) == 0 {
-		t.Fatal("No data read from pipe")
+	if reply, err := reader.ReadReply(); err != nil || string(reply) != string(WELCOME) {
+		t.Fatal("Reply following a multiple line reply not read: ", string(reply), err)
 	}
 
-	if string(data) != string(WELCOME) {
-		t.Fatal("Invalid reading: ", string(data))
+	if reader.GetReadBytes() != len(FEATURES) + len(WELCOME) {
+		t.Fatal("Invalid number of read bytes: ", reader.GetReadBytes())
 	}
 }
 
-/* Test read capabilities */
-func TestRead(t *testing.T) {
-	testGlobals.pipeWrite.Write(WELCOME) /* Ignored message */
+/* Tests replies arriving in multiple chunks */
+func TestReadPartialReply(t *testing.T) {
+	pipeRead, pipeWrite := io.Pipe()
+	reader := NewReader(pipeRead)
+	replies := make(chan []byte)
+
+	go func() {
+		reply, _ := reader.ReadReply()
+		replies <- reply
+	}()
+
+	for _, chunk := range [][]byte{FEATURES[:3], FEATURES[3:20], FEATURES[20:]} {
+		pipeWrite.Write(chunk)
+	}
 
-	/* Block until the line to be ignored is read */
-	for {
-		data := testGlobals.reader.Peek()
-		if len(data) > 0 {
-			break
+	select {
+	case reply := <-replies:
+		if string(reply) != string(FEATURES) {
+			t.Fatal("Invalid reading: ", string(reply))
 		}
+	case <-time.After(time.Second):
+		t.Fatal("The reply was not read.")
 	}
 
-	go func () {
-		testGlobals.pipeWrite.Write([]byte{'a'})
+	/* Errors while reading keep the partially read reply */
+	go func() {
+		pipeWrite.Write([]byte("200 NO"))
+		pipeWrite.CloseWithError(errors.New("interrupted"))
 	}()
 
-	testGlobals.reader.Reset()
-	data := testGlobals.reader.Get()
-
-	if len(data) == 0 {
-		t.Fatal("No data read from pipe")
+	if _, err := reader.ReadReply(); err == nil {
+		t.Fatal("Incomplete reply read.")
 	}
 
-	if string(data) != "a" {
-		t.Fatal("Invalid reading: ", string(data))
+	if len(reader.partialLine) == 0 {
+		t.Fatal("Partially read reply dropped.")
 	}
 }
 
-/* Tests the reader stop reading functionality */
-func TestReaderStop(t *testing.T) {
-	testGlobals.reader.StopReading()
+/* Tests data connections streaming */
+func TestDataReader(t *testing.T) {
+	var destination bytes.Buffer
+	var content string = strings.Repeat("data", DataBufferSize)
+
+	reader := NewDataReader(strings.NewReader(content), &destination)
+	if err := reader.Wait(); err != nil {
+		t.Fatal("Unexpected data reader error: ", err)
+	}
+
+	if reader.IsActive() || destination.String() != content {
+		t.Fatal("Invalid data streaming.", reader.IsActive(), destination.Len())
+	}
+
+	if reader.GetReadBytes() != len(content) || reader.GetWrittenBytes() != len(content) {
+		t.Fatal("Invalid number of read or written bytes: ", reader.GetReadBytes(), reader.GetWrittenBytes())
+	}
+
+	/* Without a destination, the data is collected in memory */
+	reader = NewDataReader(strings.NewReader(content), nil)
+	<-reader.Done()
 
-	if testGlobals.reader.IsActive() {
-		t.Fatal("The reader activity flag was not updated successfully.")
+	if string(reader.Get()) != content || reader.GetError() != nil {
+		t.Fatal("Invalid data collection.", len(reader.Get()), reader.GetError())
 	}
 }
@@ -0,0 +1,147 @@
+package server
+
+import (
+	Access "github.com/ghepesdoru/bookwormFTP/core/access"
+	Credentials "github.com/ghepesdoru/bookwormFTP/core/credentials"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+/* Authentication errors. Authenticators reject logins with ERR_LoginIncorrect (530), or ask for an account with
+ ERR_AccountRequired (332). Any other error is reported as a failed login */
+var (
+	ERR_LoginIncorrect	= fmt.Errorf("Login incorrect.")
+	ERR_AccountRequired	= fmt.Errorf("Account required for login.")
+)
+
+var (
+	/* Permissions of authenticated users, and of anonymous users */
+	FullRights			= []Access.Perm{Access.PERM_Append, Access.PERM_Create, Access.PERM_Delete, Access.PERM_Execute, Access.PERM_Rename, Access.PERM_List, Access.PERM_Make, Access.PERM_Purge, Access.PERM_Retrievable, Access.PERM_Storable}
+	ReadOnlyRights		= []Access.Perm{Access.PERM_Execute, Access.PERM_List, Access.PERM_Retrievable}
+)
+
+/* Usernames of anonymous logins (RFC1635) */
+var AnonymousUsers map[string]bool = map[string]bool {
+	"anonymous": true, "ftp": true,
+}
+
+/* Authentication backend of a Server. The account and virtual host are empty unless specified by the client (ACCT, HOST).
+ Authenticators are shared by all sessions, and have to be safe for concurrent use */
+type Authenticator interface {
+	Authenticate(credentials *Credentials.Credentials, account string, host string) (*User, error)
+}
+
+/* Authenticated user. Sessions are confined to the user's root directory (a driver path) */
+type User struct {
+	RootDir		string
+	Rights		*Access.AccessRights
+}
+
+/* Authenticators tried in order, until one of them does not reject the login with ERR_LoginIncorrect */
+type AuthenticatorChain []Authenticator
+
+func (c AuthenticatorChain) Authenticate(credentials *Credentials.Credentials, account string, host string) (user *User, err error) {
+	err = ERR_LoginIncorrect
+
+	for _, authenticator := range c {
+		if user, err = authenticator.Authenticate(credentials, account, host); !errors.Is(err, ERR_LoginIncorrect) {
+			break
+		}
+	}
+
+	return
+}
+
+/* Authenticator over a fixed set of users */
+type StaticAuthenticator struct {
+	users		map[string]staticUser
+	mutex		sync.RWMutex
+}
+
+/* Registered user of a StaticAuthenticator */
+type staticUser struct {
+	password	string
+	account		string
+	user		*User
+}
+
+/* Instantiates a new StaticAuthenticator without any user */
+func NewStaticAuthenticator() *StaticAuthenticator {
+	return &StaticAuthenticator{make(map[string]staticUser), sync.RWMutex{}}
+}
+
+/* Registers (or replaces) a user. A nil User gets full rights over the whole driver */
+func (a *StaticAuthenticator) AddUser(credentials *Credentials.Credentials, user *User) {
+	a.AddUserWithAccount(credentials, EmptyString, user)
+}
+
+/* Registers (or replaces) a user that has to specify the account as well (ACCT) */
+func (a *StaticAuthenticator) AddUserWithAccount(credentials *Credentials.Credentials, account string, user *User) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if user == nil {
+		user = &User{RootDir, Access.NewAccessRights(FullRights)}
+	}
+
+	a.users[credentials.Username()] = staticUser{credentials.Password(), account, user}
+}
+
+/* Removes a registered user */
+func (a *StaticAuthenticator) RemoveUser(username string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	delete(a.users, username)
+}
+
+func (a *StaticAuthenticator) Authenticate(credentials *Credentials.Credentials, account string, host string) (*User, error) {
+	a.mutex.RLock()
+	entry, ok := a.users[credentials.Username()]
+	a.mutex.RUnlock()
+
+	if !ok || !equalSecrets(entry.password, credentials.Password()) {
+		return nil, ERR_LoginIncorrect
+	}
+
+	if entry.account != EmptyString {
+		if account == EmptyString {
+			return nil, ERR_AccountRequired
+		}
+
+		if !equalSecrets(entry.account, account) {
+			return nil, ERR_LoginIncorrect
+		}
+	}
+
+	return entry.user, nil
+}
+
+/* Authenticator accepting anonymous logins (RFC1635) with any password, with read only access */
+type AnonymousAuthenticator struct {
+	rootDir		string
+}
+
+/* Instantiates a new AnonymousAuthenticator confined to the specified root directory (RootDir if empty) */
+func NewAnonymousAuthenticator(rootDir string) *AnonymousAuthenticator {
+	if rootDir == EmptyString {
+		rootDir = RootDir
+	}
+
+	return &AnonymousAuthenticator{rootDir}
+}
+
+func (a *AnonymousAuthenticator) Authenticate(credentials *Credentials.Credentials, account string, host string) (*User, error) {
+	if !AnonymousUsers[credentials.Username()] {
+		return nil, ERR_LoginIncorrect
+	}
+
+	return &User{a.rootDir, Access.NewAccessRights(ReadOnlyRights)}, nil
+}
+
+/* Compares two secrets in constant time */
+func equalSecrets(expected string, actual string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	Access "github.com/ghepesdoru/bookwormFTP/core/access"
+	Credentials "github.com/ghepesdoru/bookwormFTP/core/credentials"
+	"golang.org/x/crypto/bcrypt"
+	FilePath "path/filepath"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+/* Builds credentials, failing the test on invalid input */
+func credentials(t *testing.T, username string, password string) *Credentials.Credentials {
+	c, err := Credentials.NewCredentials(username, password)
+	if err != nil {
+		t.Fatal("Invalid credentials.", err)
+	}
+
+	return c
+}
+
+func TestStaticAuthenticator(t *testing.T) {
+	a := NewStaticAuthenticator()
+	a.AddUser(credentials(t, "user", "secret"), &User{"/home/user", Access.NewAccessRights(ReadOnlyRights)})
+	a.AddUserWithAccount(credentials(t, "billed", "secret"), "sales", nil)
+
+	if user, err := a.Authenticate(credentials(t, "user", "secret"), EmptyString, EmptyString); err != nil || user.RootDir != "/home/user" || user.Rights.Contains(Access.PERM_Storable) {
+		t.Fatal("Invalid authenticated user.", user, err)
+	}
+
+	if _, err := a.Authenticate(credentials(t, "user", "wrong"), EmptyString, EmptyString); err != ERR_LoginIncorrect {
+		t.Fatal("Wrong password accepted.", err)
+	}
+
+	if _, err := a.Authenticate(credentials(t, "billed", "secret"), EmptyString, EmptyString); err != ERR_AccountRequired {
+		t.Fatal("Missing account accepted.", err)
+	}
+
+	if _, err := a.Authenticate(credentials(t, "billed", "secret"), "support", EmptyString); err != ERR_LoginIncorrect {
+		t.Fatal("Wrong account accepted.", err)
+	}
+
+	if user, err := a.Authenticate(credentials(t, "billed", "secret"), "sales", EmptyString); err != nil || user.RootDir != RootDir || !user.Rights.Contains(Access.PERM_Storable) {
+		t.Fatal("Invalid authenticated user.", user, err)
+	}
+
+	a.RemoveUser("user")
+	if _, err := a.Authenticate(credentials(t, "user", "secret"), EmptyString, EmptyString); err != ERR_LoginIncorrect {
+		t.Fatal("Removed user accepted.", err)
+	}
+}
+
+func TestAuthenticatorChain(t *testing.T) {
+	static := NewStaticAuthenticator()
+	static.AddUser(credentials(t, "user", "secret"), nil)
+	chain := AuthenticatorChain{static, NewAnonymousAuthenticator("/pub")}
+
+	if user, err := chain.Authenticate(credentials(t, "anonymous", "guest@"), EmptyString, EmptyString); err != nil || user.RootDir != "/pub" || user.Rights.Contains(Access.PERM_Storable) {
+		t.Fatal("Invalid anonymous user.", user, err)
+	}
+
+	if user, err := chain.Authenticate(credentials(t, "user", "secret"), EmptyString, EmptyString); err != nil || !user.Rights.Contains(Access.PERM_Storable) {
+		t.Fatal("Invalid authenticated user.", user, err)
+	}
+
+	if _, err := chain.Authenticate(credentials(t, "user", "wrong"), EmptyString, EmptyString); err != ERR_LoginIncorrect {
+		t.Fatal("Wrong password accepted.", err)
+	}
+
+	if _, err := (AuthenticatorChain{}).Authenticate(credentials(t, "user", "secret"), EmptyString, EmptyString); err != ERR_LoginIncorrect {
+		t.Fatal("Empty chain accepted a login.", err)
+	}
+}
+
+func TestHtpasswdAuthenticator(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := FilePath.Join(t.TempDir(), "htpasswd")
+	content := "# FTP users\n\nalice:" + string(hash) + "\n"
+	if err = os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewHtpasswdAuthenticator(path, "/home/" + UserPlaceholder, nil)
+	if err != nil {
+		t.Fatal("Unable to load the htpasswd file.", err)
+	}
+
+	if user, err := a.Authenticate(credentials(t, "alice", "secret"), EmptyString, EmptyString); err != nil || user.RootDir != "/home/alice" {
+		t.Fatal("Invalid authenticated user.", user, err)
+	}
+
+	if _, err = a.Authenticate(credentials(t, "alice", "wrong"), EmptyString, EmptyString); err != ERR_LoginIncorrect {
+		t.Fatal("Wrong password accepted.", err)
+	}
+
+	/* Users are replaced on reload */
+	os.WriteFile(path, []byte("bob:" + string(hash) + "\n"), 0600)
+	if err = a.Reload(); err != nil {
+		t.Fatal("Unable to reload the htpasswd file.", err)
+	}
+
+	if _, err = a.Authenticate(credentials(t, "alice", "secret"), EmptyString, EmptyString); err != ERR_LoginIncorrect {
+		t.Fatal("Dropped user accepted.", err)
+	}
+
+	/* Only bcrypt hashes are supported */
+	os.WriteFile(path, []byte("carol:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n"), 0600)
+	if _, err = NewHtpasswdAuthenticator(path, EmptyString, nil); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatal("Unsupported hash accepted.", err)
+	}
+
+	if _, err = NewHtpasswdAuthenticator(FilePath.Join(t.TempDir(), "missing"), EmptyString, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("Missing htpasswd file accepted.", err)
+	}
+}
+
+func TestLoginSequence(t *testing.T) {
+	driver := NewMemoryDriver()
+	driver.MakeDir("/home")
+	driver.MakeDir("/home/billed")
+	driver.WriteFile("/home/billed/report.txt", []byte("report"))
+	driver.WriteFile("/secret.txt", []byte("secret"))
+
+	authenticator := NewStaticAuthenticator()
+	authenticator.AddUserWithAccount(credentials(t, "billed", "secret"), "sales", &User{"/home/billed", Access.NewAccessRights(FullRights)})
+	authenticator.AddUser(credentials(t, "homeless", "secret"), &User{"/home/missing", nil})
+
+	server := startServerWith(t, driver, authenticator)
+	c := dial(t, server.Addr)
+
+	c.send("ACCT sales", 503)
+	c.send("HOST ftp.example.com", 220)
+
+	c.send("USER billed", 331)
+	c.send("HOST ftp.example.com", 503)
+	c.send("PASS secret", 332)
+	c.send("PWD", 530)
+	c.send("ACCT support", 530)
+
+	c.send("USER billed", 331)
+	c.send("PASS secret", 332)
+	c.send("ACCT sales", 230)
+	c.send("ACCT sales", 202)
+
+	/* The session is confined to the user's root directory */
+	if message := c.send("PWD", 257).Message(); !strings.Contains(message, "\"/\"") {
+		t.Fatal("Invalid current directory.", message)
+	}
+
+	c.send("SIZE report.txt", 213)
+	c.send("SIZE /secret.txt", 550)
+	c.send("CWD ..", 250)
+	c.send("SIZE ../../secret.txt", 550)
+	c.send("RMD /", 550)
+	c.send("RNFR /", 550)
+
+	c.send("MKD reports", 257)
+	if info, err := driver.Stat("/home/billed/reports"); err != nil || !info.IsDir() {
+		t.Fatal("Directory created outside of the user's root directory.", info, err)
+	}
+
+	/* Users without an available root directory are rejected */
+	c.send("USER homeless", 331)
+	c.send("PASS secret", 530)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+/* Error definitions */
+var (
+	ERR_OutsideRoot		= fmt.Errorf("Path outside of the served root directory.")
+	ERR_DirNotEmpty		= fmt.Errorf("Directory not empty.")
+	ERR_NotADirectory	= fmt.Errorf("Not a directory.")
+	ERR_IsADirectory	= fmt.Errorf("Is a directory.")
+)
+
+/* Storage backend of a Server. All paths are absolute, clean and slash separated, as resolved by the session against
+ it's current directory. Drivers are shared by all sessions, and have to be safe for concurrent use */
+type Driver interface {
+	/* Describes the specified file or directory */
+	Stat(path string) (os.FileInfo, error)
+	/* Lists the contents of the specified directory */
+	List(path string) ([]os.FileInfo, error)
+	/* Opens the specified file for reading */
+	OpenRead(path string) (io.ReadCloser, error)
+	/* Opens the specified file for writing, creating it if required. Existing files are truncated, or appended to */
+	OpenWrite(path string, append bool) (io.WriteCloser, error)
+	/* Creates a new directory */
+	MakeDir(path string) error
+	/* Removes an empty directory */
+	RemoveDir(path string) error
+	/* Renames (or moves) a file or directory */
+	Rename(from string, to string) error
+	/* Removes a file */
+	Delete(path string) error
+	/* Changes the permission bits of a file or directory */
+	Chmod(path string, mode os.FileMode) error
+}
+
+/* Wraps a driver error with the failed operation and path */
+func pathError(op string, path string, err error) error {
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
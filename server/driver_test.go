@@ -0,0 +1,156 @@
+package server
+
+import (
+	FilePath "path/filepath"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+/* Exercises the common Driver behavior */
+func testDriver(t *testing.T, driver Driver) {
+	if err := driver.MakeDir("/docs"); err != nil {
+		t.Fatal("Unable to create a directory.", err)
+	}
+
+	if err := driver.MakeDir("/missing/docs"); err == nil {
+		t.Fatal("Directory created in a missing parent directory.")
+	}
+
+	for _, append := range []bool{false, true} {
+		w, err := driver.OpenWrite("/docs/notes.txt", append)
+		if err != nil {
+			t.Fatal("Unable to open a file for writing.", err)
+		}
+
+		w.Write([]byte("notes"))
+		w.Close()
+	}
+
+	r, err := driver.OpenRead("/docs/notes.txt")
+	if err != nil {
+		t.Fatal("Unable to open a file for reading.", err)
+	}
+
+	if content, _ := io.ReadAll(r); string(content) != "notesnotes" {
+		t.Fatal("Invalid file content.", string(content))
+	}
+	r.Close()
+
+	if _, err = driver.OpenRead("/docs"); err == nil {
+		t.Fatal("Directory opened for reading.")
+	}
+
+	if infos, err := driver.List("/docs"); err != nil || len(infos) != 1 || infos[0].Name() != "notes.txt" || infos[0].Size() != 10 {
+		t.Fatal("Invalid directory listing.", infos, err)
+	}
+
+	if err = driver.Chmod("/docs/notes.txt", 0600); err != nil {
+		t.Fatal("Unable to change the file mode.", err)
+	}
+
+	if info, err := driver.Stat("/docs/notes.txt"); err != nil || info.Mode().Perm() != 0600 || info.IsDir() {
+		t.Fatal("Invalid file description.", info, err)
+	}
+
+	if err = driver.RemoveDir("/docs"); err == nil {
+		t.Fatal("Non empty directory removed.")
+	}
+
+	if err = driver.Rename("/docs", "/archive"); err != nil {
+		t.Fatal("Unable to rename a directory.", err)
+	}
+
+	if _, err = driver.Stat("/archive/notes.txt"); err != nil {
+		t.Fatal("Directory contents not moved.", err)
+	}
+
+	if err = driver.Delete("/archive"); err == nil {
+		t.Fatal("Directory removed as a file.")
+	}
+
+	if err = driver.Delete("/archive/notes.txt"); err != nil {
+		t.Fatal("Unable to delete a file.", err)
+	}
+
+	if err = driver.RemoveDir("/archive"); err != nil {
+		t.Fatal("Unable to remove an empty directory.", err)
+	}
+
+	if _, err = driver.Stat("/archive"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Removed directory still available.", err)
+	}
+
+	if err = driver.RemoveDir(RootDir); err == nil {
+		t.Fatal("Root directory removed.")
+	}
+}
+
+func TestMemoryDriver(t *testing.T) {
+	testDriver(t, NewMemoryDriver())
+}
+
+func TestLocalDriver(t *testing.T) {
+	driver, err := NewLocalDriver(t.TempDir())
+	if err != nil {
+		t.Fatal("Unable to instantiate a local driver.", err)
+	}
+
+	testDriver(t, driver)
+}
+
+func TestLocalDriverSandbox(t *testing.T) {
+	outside := t.TempDir()
+	root := t.TempDir()
+
+	if err := os.WriteFile(FilePath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(outside, FilePath.Join(root, "escape")); err != nil {
+		t.Skip("Symbolic links not supported.", err)
+	}
+
+	driver, err := NewLocalDriver(root)
+	if err != nil {
+		t.Fatal("Unable to instantiate a local driver.", err)
+	}
+
+	if _, err = driver.OpenRead("/escape/secret"); !errors.Is(err, ERR_OutsideRoot) {
+		t.Fatal("Symbolic link followed outside of the root directory.", err)
+	}
+
+	if _, err = driver.OpenWrite("/escape/new", false); !errors.Is(err, ERR_OutsideRoot) {
+		t.Fatal("File created outside of the root directory.", err)
+	}
+
+	/* Dangling links are resolved to their target */
+	if err = os.Symlink(FilePath.Join(outside, "planted"), FilePath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = driver.OpenWrite("/dangling", false); !errors.Is(err, ERR_OutsideRoot) {
+		t.Fatal("File created through a dangling link outside of the root directory.", err)
+	}
+
+	if _, err = os.Lstat(FilePath.Join(outside, "planted")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("File created outside of the root directory.", err)
+	}
+
+	if err = os.Symlink("missing", FilePath.Join(root, "inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	if w, err := driver.OpenWrite("/inside", false); err != nil {
+		t.Fatal("Dangling link inside of the root directory refused.", err)
+	} else {
+		w.Close()
+	}
+
+	/* Parent references never leave the root directory */
+	if _, err = driver.Stat("/../../" + FilePath.Base(outside)); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Path resolved outside of the root directory.", err)
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	Access "github.com/ghepesdoru/bookwormFTP/core/access"
+	Credentials "github.com/ghepesdoru/bookwormFTP/core/credentials"
+	"golang.org/x/crypto/bcrypt"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	UserPlaceholder		= "{user}"
+	HtpasswdComment		= "#"
+	HtpasswdSeparator	= ":"
+)
+
+var (
+	ERRF_InvalidHtpasswdLine = "Invalid htpasswd line %d. Expected user:bcrypt-hash."
+)
+
+/* Authenticator over an htpasswd file with bcrypt hashes (htpasswd -B) */
+type HtpasswdAuthenticator struct {
+	path		string
+	rootDir		string
+	rights		*Access.AccessRights
+	hashes		map[string][]byte
+	mutex		sync.RWMutex
+}
+
+/* Instantiates a new HtpasswdAuthenticator over the specified file. Users are confined to the specified root directory,
+ where UserPlaceholder is replaced with the username (ex: /home/{user}) */
+func NewHtpasswdAuthenticator(path string, rootDir string, rights *Access.AccessRights) (a *HtpasswdAuthenticator, err error) {
+	if rootDir == EmptyString {
+		rootDir = RootDir
+	}
+
+	if rights == nil {
+		rights = Access.NewAccessRights(FullRights)
+	}
+
+	a = &HtpasswdAuthenticator{path, rootDir, rights, nil, sync.RWMutex{}}
+	if err = a.Reload(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+/* Reads the htpasswd file again, replacing all the known users */
+func (a *HtpasswdAuthenticator) Reload() error {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hashes, err := parseHtpasswd(file)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	a.hashes = hashes
+	a.mutex.Unlock()
+
+	return nil
+}
+
+func (a *HtpasswdAuthenticator) Authenticate(credentials *Credentials.Credentials, account string, host string) (*User, error) {
+	a.mutex.RLock()
+	hash, ok := a.hashes[credentials.Username()]
+	a.mutex.RUnlock()
+
+	if !ok || bcrypt.CompareHashAndPassword(hash, []byte(credentials.Password())) != nil {
+		return nil, ERR_LoginIncorrect
+	}
+
+	return &User{strings.ReplaceAll(a.rootDir, UserPlaceholder, credentials.Username()), a.rights}, nil
+}
+
+/* Parses htpasswd formatted contents. Empty lines and comments are ignored, only bcrypt hashes are supported */
+func parseHtpasswd(r io.Reader) (map[string][]byte, error) {
+	var hashes map[string][]byte = make(map[string][]byte)
+	var scanner *bufio.Scanner = bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n += 1 {
+		line := strings.TrimSpace(scanner.Text())
+		if line == EmptyString || strings.HasPrefix(line, HtpasswdComment) {
+			continue
+		}
+
+		username, hash, ok := strings.Cut(line, HtpasswdSeparator)
+		if !ok || username == EmptyString {
+			return nil, fmt.Errorf(ERRF_InvalidHtpasswdLine, n)
+		}
+
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf(ERRF_InvalidHtpasswdLine, n)
+		}
+
+		hashes[username] = []byte(hash)
+	}
+
+	return hashes, scanner.Err()
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	FileManager "github.com/ghepesdoru/bookwormFTP/core/fileManager"
+	PathManager "github.com/ghepesdoru/bookwormFTP/core/pathManager"
+	FilePath "path/filepath"
+	Path "path"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+/* Driver serving a local directory. Paths can not escape the root directory, symbolic links included */
+type LocalDriver struct {
+	path		*PathManager.PathManager
+	realRoot	string
+}
+
+/* Instantiates a new LocalDriver serving the specified directory */
+func NewLocalDriver(rootDir string) (*LocalDriver, error) {
+	/* The root directory has to exist, and be listable */
+	if _, err := FileManager.NewFileManagerAt(rootDir); err != nil {
+		return nil, ERR_InvalidRootDir
+	}
+
+	path, err := PathManager.NewPathManagerAt(rootDir)
+	if err != nil {
+		return nil, ERR_InvalidRootDir
+	}
+
+	realRoot, err := FilePath.EvalSymlinks(path.GetRootDir())
+	if err != nil {
+		return nil, ERR_InvalidRootDir
+	}
+
+	return &LocalDriver{path, realRoot}, nil
+}
+
+/* Root directory getter */
+func (d *LocalDriver) RootDir() string {
+	return d.path.GetRootDir()
+}
+
+func (d *LocalDriver) Stat(path string) (os.FileInfo, error) {
+	local, err := d.localPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Stat(local)
+}
+
+func (d *LocalDriver) List(path string) ([]os.FileInfo, error) {
+	local, err := d.localPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fm, err := FileManager.NewFileManagerAt(local)
+	if err != nil {
+		return nil, pathError("list", path, err)
+	}
+
+	return fm.Listing(), nil
+}
+
+func (d *LocalDriver) OpenRead(path string) (io.ReadCloser, error) {
+	local, err := d.localPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(local)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := file.Stat(); err != nil || info.IsDir() {
+		file.Close()
+		return nil, pathError("open", path, ERR_IsADirectory)
+	}
+
+	return file, nil
+}
+
+func (d *LocalDriver) OpenWrite(path string, append bool) (io.WriteCloser, error) {
+	var flags int = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+
+	local, err := d.localPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	return os.OpenFile(local, flags, 0666)
+}
+
+func (d *LocalDriver) MakeDir(path string) error {
+	local, err := d.localPath(path)
+	if err != nil {
+		return err
+	}
+
+	fm, err := FileManager.NewFileManagerAt(FilePath.Dir(local))
+	if err != nil {
+		return pathError("mkdir", path, err)
+	}
+
+	_, err = fm.MakeDir(FilePath.Base(local))
+	return err
+}
+
+func (d *LocalDriver) RemoveDir(path string) error {
+	if Path.Clean(RootDir + path) == RootDir {
+		return pathError("rmdir", path, fs.ErrPermission)
+	}
+
+	local, err := d.localPath(path)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Lstat(local); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return pathError("rmdir", path, ERR_NotADirectory)
+	}
+
+	return os.Remove(local)
+}
+
+func (d *LocalDriver) Rename(from string, to string) error {
+	if Path.Clean(RootDir + from) == RootDir {
+		return pathError("rename", from, fs.ErrPermission)
+	}
+
+	localFrom, err := d.localPath(from)
+	if err != nil {
+		return err
+	}
+
+	localTo, err := d.localPath(to)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(localFrom, localTo)
+}
+
+func (d *LocalDriver) Delete(path string) error {
+	local, err := d.localPath(path)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Lstat(local); err != nil {
+		return err
+	} else if info.IsDir() {
+		return pathError("delete", path, ERR_IsADirectory)
+	}
+
+	return os.Remove(local)
+}
+
+func (d *LocalDriver) Chmod(path string, mode os.FileMode) error {
+	local, err := d.localPath(path)
+	if err != nil {
+		return err
+	}
+
+	return os.Chmod(local, mode.Perm())
+}
+
+/* Maps the specified path inside the root directory. Paths resolving outside of the root directory (through symbolic
+ links) are refused */
+func (d *LocalDriver) localPath(path string) (string, error) {
+	local := d.path.Join(d.path.GetRootDir(), FilePath.FromSlash(Path.Clean(RootDir + path)))
+
+	/* Resolve the deepest existing part of the path */
+	existing := local
+	for {
+		real, err := FilePath.EvalSymlinks(existing)
+		if err == nil {
+			existing = real
+			break
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) || existing == FilePath.Dir(existing) {
+			return EmptyString, err
+		}
+
+		/* Dangling symbolic links are followed to their target, files created through them land at the target */
+		if info, err := os.Lstat(existing); err == nil && info.Mode() & fs.ModeSymlink != 0 {
+			target, err := os.Readlink(existing)
+			if err != nil {
+				return EmptyString, err
+			}
+
+			if !FilePath.IsAbs(target) {
+				target = FilePath.Join(FilePath.Dir(existing), target)
+			}
+
+			existing = FilePath.Clean(target)
+			continue
+		}
+
+		existing = FilePath.Dir(existing)
+	}
+
+	if rel, err := d.path.Rel(d.realRoot, existing); err != nil || rel == ".." || strings.HasPrefix(rel, ".." + d.path.GetSeparator()) {
+		return EmptyString, pathError("resolve", path, ERR_OutsideRoot)
+	}
+
+	return local, nil
+}
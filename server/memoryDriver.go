@@ -0,0 +1,267 @@
+package server
+
+import (
+	Path "path"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDirMode		= os.ModeDir | 0755
+	DefaultFileMode		= 0644
+)
+
+/* In memory file or directory */
+type memoryFile struct {
+	name		string
+	data		[]byte
+	mode		os.FileMode
+	modTime		time.Time
+}
+
+/* os.FileInfo interface implementation */
+func (f *memoryFile) Name() string { return f.name }
+func (f *memoryFile) Size() int64 { return int64(len(f.data)) }
+func (f *memoryFile) Mode() os.FileMode { return f.mode }
+func (f *memoryFile) ModTime() time.Time { return f.modTime }
+func (f *memoryFile) IsDir() bool { return f.mode.IsDir() }
+func (f *memoryFile) Sys() interface{} { return nil }
+
+/* Driver keeping all files in memory. Mostly useful in tests */
+type MemoryDriver struct {
+	files		map[string]*memoryFile
+	mutex		sync.Mutex
+}
+
+/* Writer appending to an in memory file */
+type memoryWriter struct {
+	driver		*MemoryDriver
+	path		string
+}
+
+/* Instantiates a new MemoryDriver, containing only the root directory */
+func NewMemoryDriver() *MemoryDriver {
+	return &MemoryDriver{map[string]*memoryFile{RootDir: {RootDir, nil, DefaultDirMode, time.Now()}}, sync.Mutex{}}
+}
+
+/* Creates or replaces a file with the specified contents */
+func (d *MemoryDriver) WriteFile(path string, data []byte) error {
+	w, err := d.OpenWrite(path, false)
+	if err != nil {
+		return err
+	}
+
+	w.Write(data)
+	return w.Close()
+}
+
+func (d *MemoryDriver) Stat(path string) (os.FileInfo, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if f, ok := d.files[path]; ok {
+		return f.snapshot(), nil
+	}
+
+	return nil, pathError("stat", path, fs.ErrNotExist)
+}
+
+func (d *MemoryDriver) List(path string) (infos []os.FileInfo, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if f, ok := d.files[path]; !ok {
+		return nil, pathError("list", path, fs.ErrNotExist)
+	} else if !f.IsDir() {
+		return nil, pathError("list", path, ERR_NotADirectory)
+	}
+
+	for p, f := range d.files {
+		if p != path && Path.Dir(p) == path {
+			infos = append(infos, f.snapshot())
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	return infos, nil
+}
+
+func (d *MemoryDriver) OpenRead(path string) (io.ReadCloser, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	f, ok := d.files[path]
+	if !ok {
+		return nil, pathError("open", path, fs.ErrNotExist)
+	} else if f.IsDir() {
+		return nil, pathError("open", path, ERR_IsADirectory)
+	}
+
+	return io.NopCloser(bytes.NewReader(bytes.Clone(f.data))), nil
+}
+
+func (d *MemoryDriver) OpenWrite(path string, append bool) (io.WriteCloser, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.checkParent("open", path); err != nil {
+		return nil, err
+	}
+
+	if f, ok := d.files[path]; !ok {
+		d.files[path] = &memoryFile{Path.Base(path), nil, DefaultFileMode, time.Now()}
+	} else if f.IsDir() {
+		return nil, pathError("open", path, ERR_IsADirectory)
+	} else if !append {
+		f.data = nil
+		f.modTime = time.Now()
+	}
+
+	return &memoryWriter{d, path}, nil
+}
+
+func (d *MemoryDriver) MakeDir(path string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.checkParent("mkdir", path); err != nil {
+		return err
+	}
+
+	if _, ok := d.files[path]; ok {
+		return pathError("mkdir", path, fs.ErrExist)
+	}
+
+	d.files[path] = &memoryFile{Path.Base(path), nil, DefaultDirMode, time.Now()}
+	return nil
+}
+
+func (d *MemoryDriver) RemoveDir(path string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if f, ok := d.files[path]; !ok {
+		return pathError("rmdir", path, fs.ErrNotExist)
+	} else if !f.IsDir() {
+		return pathError("rmdir", path, ERR_NotADirectory)
+	} else if path == RootDir {
+		return pathError("rmdir", path, fs.ErrPermission)
+	}
+
+	for p := range d.files {
+		if Path.Dir(p) == path {
+			return pathError("rmdir", path, ERR_DirNotEmpty)
+		}
+	}
+
+	delete(d.files, path)
+	return nil
+}
+
+func (d *MemoryDriver) Rename(from string, to string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.files[from]; !ok || from == RootDir {
+		return pathError("rename", from, fs.ErrNotExist)
+	}
+
+	if err := d.checkParent("rename", to); err != nil {
+		return err
+	}
+
+	if _, ok := d.files[to]; ok {
+		return pathError("rename", to, fs.ErrExist)
+	}
+
+	/* Directories can not be moved inside themselves */
+	if strings.HasPrefix(to, from + "/") {
+		return pathError("rename", to, fs.ErrInvalid)
+	}
+
+	/* Move the resource along with all it's contents */
+	for p, f := range d.files {
+		if p == from || strings.HasPrefix(p, from + "/") {
+			delete(d.files, p)
+			d.files[to + strings.TrimPrefix(p, from)] = f
+		}
+	}
+
+	d.files[to].name = Path.Base(to)
+	return nil
+}
+
+func (d *MemoryDriver) Delete(path string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if f, ok := d.files[path]; !ok {
+		return pathError("delete", path, fs.ErrNotExist)
+	} else if f.IsDir() {
+		return pathError("delete", path, ERR_IsADirectory)
+	}
+
+	delete(d.files, path)
+	return nil
+}
+
+func (d *MemoryDriver) Chmod(path string, mode os.FileMode) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	f, ok := d.files[path]
+	if !ok {
+		return pathError("chmod", path, fs.ErrNotExist)
+	}
+
+	f.mode = f.mode.Type() | mode.Perm()
+	return nil
+}
+
+/* Checks that the specified path's parent is an existing directory */
+func (d *MemoryDriver) checkParent(op string, path string) error {
+	if parent, ok := d.files[Path.Dir(path)]; !ok {
+		return pathError(op, path, fs.ErrNotExist)
+	} else if !parent.IsDir() {
+		return pathError(op, path, ERR_NotADirectory)
+	}
+
+	return nil
+}
+
+/* Gets a copy of the current file description */
+func (f *memoryFile) snapshot() *memoryFile {
+	copy := *f
+	return &copy
+}
+
+/* io.Writer interface implementation */
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	w.driver.mutex.Lock()
+	defer w.driver.mutex.Unlock()
+
+	f, ok := w.driver.files[w.path]
+	if !ok || f.IsDir() {
+		/* Removed or replaced in the meantime */
+		return 0, pathError("write", w.path, fs.ErrNotExist)
+	}
+
+	f.data = append(f.data, p...)
+	f.modTime = time.Now()
+
+	return len(p), nil
+}
+
+/* io.Closer interface implementation */
+func (w *memoryWriter) Close() error {
+	return nil
+}
@@ -1 +1,169 @@
 package server
+
+import (
+	Logger "github.com/ghepesdoru/bookwormFTP/core/logger"
+	Net "net"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	/* Generic constants */
+	DefaultServerProtocol	= "tcp"
+	DefaultAddress			= ":21"
+	DefaultIdleTimeout		= 5 * time.Minute
+	DefaultDataTimeout		= 30 * time.Second
+	MaxCommandLength		= 4096
+	SystemType				= "UNIX Type: L8"
+	RootDir					= "/"
+	EmptyString				= ""
+	EOL						= "\r\n"
+)
+
+/* Error definitions */
+var (
+	ERR_InvalidRootDir	= fmt.Errorf("Invalid root directory. Please specify an existing directory.")
+	ERR_ServerClosed	= fmt.Errorf("Server closed.")
+)
+
+/* BookwormFTP Server type definition */
+type Server struct {
+	Addr			string
+	Logger			*Logger.Logger
+	IdleTimeout		time.Duration	/* Maximum time without any command on a control connection */
+	DataTimeout		time.Duration	/* Maximum time waiting for the client to open a data connection */
+	driver			Driver
+	authenticator	Authenticator
+	listener		Net.Listener
+	sessions		map[*session]bool
+	closed			bool
+	mutex			sync.Mutex
+}
+
+/* Instantiates a new Server serving the specified driver's contents on the specified address (DefaultAddress if empty),
+ to the users accepted by the specified authenticator */
+func NewServer(addr string, driver Driver, authenticator Authenticator) *Server {
+	if addr == EmptyString {
+		addr = DefaultAddress
+	}
+
+	return &Server{addr, Logger.NewNullLogger(), DefaultIdleTimeout, DefaultDataTimeout, driver, authenticator, nil, make(map[*session]bool), false, sync.Mutex{}}
+}
+
+/* Instantiates a new Server serving the specified local directory on the specified address (DefaultAddress if empty) */
+func NewLocalServer(addr string, rootDir string, authenticator Authenticator) (*Server, error) {
+	driver, err := NewLocalDriver(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewServer(addr, driver, authenticator), nil
+}
+
+/* Stops accepting new connections and closes all the active sessions */
+func (s *Server) Close() (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+
+	for session := range s.sessions {
+		session.close()
+	}
+
+	return
+}
+
+/* Listens on the server's address and serves all incoming connections */
+func (s *Server) ListenAndServe() error {
+	listener, err := Net.Listen(DefaultServerProtocol, s.Addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+/* Storage driver getter */
+func (s *Server) Driver() Driver {
+	return s.driver
+}
+
+/* Authenticator getter */
+func (s *Server) Authenticator() Authenticator {
+	return s.authenticator
+}
+
+/* Accepts connections on the specified listener, serving each one on it's own goroutine. Always returns a non nil error */
+func (s *Server) Serve(listener Net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		listener.Close()
+		return ERR_ServerClosed
+	}
+
+	s.listener = listener
+	s.mutex.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ERR_ServerClosed
+			}
+
+			if ne, ok := err.(Net.Error); ok && ne.Timeout() {
+				/* Transient accept failure */
+				continue
+			}
+
+			return err
+		}
+
+		session := newSession(s, conn)
+		if !s.track(session) {
+			conn.Close()
+			return ERR_ServerClosed
+		}
+
+		go func() {
+			defer s.untrack(session)
+			session.serve()
+		}()
+	}
+}
+
+/* Checks if the server was closed */
+func (s *Server) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.closed
+}
+
+/* Registers an active session. Fails once the server is closed */
+func (s *Server) track(session *session) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.sessions[session] = true
+	return true
+}
+
+/* Drops a finished session */
+func (s *Server) untrack(session *session) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.sessions, session)
+}
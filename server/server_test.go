@@ -0,0 +1,353 @@
+package server
+
+import (
+	Address "github.com/ghepesdoru/bookwormFTP/core/addr"
+	Credentials "github.com/ghepesdoru/bookwormFTP/core/credentials"
+	Parser "github.com/ghepesdoru/bookwormFTP/core/parsers/parser"
+	Reader "github.com/ghepesdoru/bookwormFTP/core/reader"
+	Response "github.com/ghepesdoru/bookwormFTP/core/response"
+	FilePath "path/filepath"
+	Net "net"
+	"io"
+	"strings"
+	"testing"
+)
+
+/* Minimal control connection client */
+type testClient struct {
+	t		*testing.T
+	conn	Net.Conn
+	reader	*Reader.Reader
+}
+
+/* Starts a server over an in memory driver, with a "user" user */
+func startServer(t *testing.T) (*Server, *MemoryDriver) {
+	driver := NewMemoryDriver()
+	authenticator := NewStaticAuthenticator()
+
+	credentials, _ := Credentials.NewCredentials("user", "secret")
+	authenticator.AddUser(credentials, nil)
+
+	return startServerWith(t, driver, authenticator), driver
+}
+
+/* Starts a server over the specified driver and authenticator, on a loopback address */
+func startServerWith(t *testing.T, driver Driver, authenticator Authenticator) *Server {
+	server := NewServer("127.0.0.1:0", driver, authenticator)
+
+	listener, err := Net.Listen("tcp4", server.Addr)
+	if err != nil {
+		t.Fatal("Unable to open a loopback listener.", err)
+	}
+
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	server.Addr = listener.Addr().String()
+	return server
+}
+
+/* Connects to the specified server address and checks the greeting */
+func dial(t *testing.T, addr string) *testClient {
+	conn, err := Net.Dial("tcp4", addr)
+	if err != nil {
+		t.Fatal("Unable to connect to the server.", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	c := &testClient{t, conn, Reader.NewReader(conn)}
+	c.expect(220)
+
+	return c
+}
+
+/* Reads the next reply, and checks it's status */
+func (c *testClient) expect(status int) *Response.Response {
+	raw, err := c.reader.ReadReply()
+	if err != nil {
+		c.t.Fatal("Unable to read the server reply.", err)
+	}
+
+	parser := Parser.NewParser()
+	parser.ParseBlock(raw)
+
+	response := parser.Get()
+	if response == nil || response.Status() != status {
+		c.t.Fatalf("Expected a %d reply, got: %s", status, string(raw))
+	}
+
+	return response
+}
+
+/* Sends a command, and checks the reply status */
+func (c *testClient) send(command string, status int) *Response.Response {
+	if _, err := c.conn.Write([]byte(command + EOL)); err != nil {
+		c.t.Fatal("Unable to send the command.", err)
+	}
+
+	return c.expect(status)
+}
+
+/* Logs in with the specified credentials */
+func (c *testClient) login(username string, password string) {
+	c.send("USER " + username, 331)
+	c.send("PASS " + password, 230)
+}
+
+/* Enters passive mode and connects to the data port */
+func (c *testClient) passive(extended bool) Net.Conn {
+	var addr *Address.Addr
+
+	if extended {
+		addr = Address.FromExtendedPortSpecifier(c.send("EPSV", 229).Message())
+		ip := Net.ParseIP("127.0.0.1")
+		addr.IP = &ip
+	} else {
+		addr = Address.FromPortSpecifier(c.send("PASV", 227).Message())
+	}
+
+	if addr == nil {
+		c.t.Fatal("Invalid passive mode reply.")
+	}
+
+	conn, err := Net.Dial("tcp4", addr.String())
+	if err != nil {
+		c.t.Fatal("Unable to connect to the data port.", err)
+	}
+
+	return conn
+}
+
+func TestInvalidRootDir(t *testing.T) {
+	if _, err := NewLocalServer(EmptyString, FilePath.Join(t.TempDir(), "missing"), NewStaticAuthenticator()); err != ERR_InvalidRootDir {
+		t.Fatal("Missing root directory accepted.", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	server, _ := startServer(t)
+	c := dial(t, server.Addr)
+
+	c.send("PWD", 530)
+	c.send("PASS secret", 503)
+	c.send("USER user", 331)
+	c.send("PASS wrong", 530)
+
+	/* Anonymous logins are only accepted by the anonymous authenticator */
+	c.send("USER anonymous", 331)
+	c.send("PASS guest@", 530)
+
+	c.login("user", "secret")
+	c.send("BOGUS", 500)
+	c.send("REIN", 502)
+	c.send("QUIT", 221)
+}
+
+func TestFeatures(t *testing.T) {
+	server, _ := startServer(t)
+	c := dial(t, server.Addr)
+
+	message := c.send("FEAT", 211).Message()
+	for _, feature := range []string{"EPSV", "MLSD", "MLST type*;size*;modify*;perm*;", "REST STREAM", "SIZE"} {
+		if !strings.Contains(message, feature) {
+			t.Fatal("Feature not advertised.", feature, message)
+		}
+	}
+
+	/* Base commands are not advertised */
+	if strings.Contains(message, "PASV") || strings.Contains(message, "RETR") {
+		t.Fatal("Base command advertised.", message)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	server, driver := startServer(t)
+	c := dial(t, server.Addr)
+	c.login("user", "secret")
+
+	driver.MakeDir("/pub")
+	driver.MakeDir("/pub/docs")
+
+	c.send("CWD pub/docs", 250)
+	if message := c.send("PWD", 257).Message(); !strings.Contains(message, "\"/pub/docs\"") {
+		t.Fatal("Invalid current directory.", message)
+	}
+
+	c.send("CDUP", 250)
+	c.send("CWD missing", 550)
+
+	/* The root directory can not be escaped */
+	c.send("CWD ../../..", 250)
+	if message := c.send("XPWD", 257).Message(); !strings.Contains(message, "\"/\"") {
+		t.Fatal("Root directory escaped.", message)
+	}
+}
+
+func TestTransfers(t *testing.T) {
+	server, _ := startServer(t)
+	c := dial(t, server.Addr)
+	c.login("user", "secret")
+	c.send("TYPE I", 200)
+
+	c.send("RETR file.txt", 550)
+	c.send("STOR file.txt", 425)
+
+	/* Upload over PASV */
+	data := c.passive(false)
+	c.send("STOR file.txt", 150)
+	data.Write([]byte("file contents"))
+	data.Close()
+	c.expect(226)
+
+	c.send("SIZE file.txt", 213)
+
+	/* Download over EPSV */
+	data = c.passive(true)
+	c.send("RETR file.txt", 150)
+	if content, _ := io.ReadAll(data); string(content) != "file contents" {
+		t.Fatal("Invalid downloaded content.", string(content))
+	}
+	c.expect(226)
+
+	/* Listings */
+	data = c.passive(true)
+	c.send("MLSD", 150)
+	listing, _ := io.ReadAll(data)
+	c.expect(226)
+
+	if !strings.HasPrefix(string(listing), "type=cdir;") || !strings.Contains(string(listing), "type=file;size=13;") || !strings.Contains(string(listing), "perm=adfrw; file.txt\r\n") {
+		t.Fatal("Invalid MLSD listing.", string(listing))
+	}
+
+	data = c.passive(false)
+	c.send("LIST -l", 150)
+	listing, _ = io.ReadAll(data)
+	c.expect(226)
+
+	if !strings.HasPrefix(string(listing), "-rw") || !strings.HasSuffix(string(listing), " file.txt\r\n") {
+		t.Fatal("Invalid LIST listing.", string(listing))
+	}
+
+	c.send("MLSD file.txt", 501)
+}
+
+func TestRestart(t *testing.T) {
+	server, driver := startServer(t)
+	c := dial(t, server.Addr)
+	c.login("user", "secret")
+	driver.WriteFile("/file.txt", []byte("file contents"))
+
+	c.send("REST -1", 501)
+	c.send("ABOR", 226)
+
+	/* Download the end of the file */
+	data := c.passive(true)
+	c.send("REST 5", 350)
+	c.send("RETR file.txt", 150)
+	if content, _ := io.ReadAll(data); string(content) != "contents" {
+		t.Fatal("Invalid restarted download.", string(content))
+	}
+	c.expect(226)
+
+	/* The offset only applies to the next command */
+	c.send("REST 5", 350)
+	c.send("NOOP", 200)
+	data = c.passive(true)
+	c.send("RETR file.txt", 150)
+	if content, _ := io.ReadAll(data); string(content) != "file contents" {
+		t.Fatal("Offset applied to a later transfer.", string(content))
+	}
+	c.expect(226)
+
+	/* Downloads can not restart past the end of the file */
+	c.passive(true)
+	c.send("REST 14", 350)
+	c.send("RETR file.txt", 554)
+
+	/* Uploads restart at the end of the existing file */
+	c.passive(true)
+	c.send("REST 4", 350)
+	c.send("STOR file.txt", 554)
+
+	data = c.passive(true)
+	c.send("REST 13", 350)
+	c.send("STOR file.txt", 150)
+	data.Write([]byte(" and more"))
+	data.Close()
+	c.expect(226)
+
+	if info, _ := driver.Stat("/file.txt"); info.Size() != int64(len("file contents and more")) {
+		t.Fatal("Invalid restarted upload.", info.Size())
+	}
+}
+
+func TestAnonymousReadOnly(t *testing.T) {
+	driver := NewMemoryDriver()
+	server := startServerWith(t, driver, NewAnonymousAuthenticator(EmptyString))
+	driver.WriteFile("/readme", []byte("read me"))
+
+	c := dial(t, server.Addr)
+	c.login("anonymous", "guest@")
+
+	c.passive(true)
+	c.send("STOR upload", 550)
+	c.send("MKD incoming", 550)
+	c.send("DELE readme", 550)
+	c.send("RNFR readme", 550)
+
+	data := c.passive(true)
+	c.send("RETR readme", 150)
+	if content, _ := io.ReadAll(data); string(content) != "read me" {
+		t.Fatal("Invalid downloaded content.", string(content))
+	}
+	c.expect(226)
+}
+
+func TestFileManagement(t *testing.T) {
+	server, driver := startServer(t)
+	c := dial(t, server.Addr)
+	c.login("user", "secret")
+
+	if message := c.send("MKD docs", 257).Message(); !strings.Contains(message, "\"/docs\"") {
+		t.Fatal("Invalid created directory.", message)
+	}
+	c.send("MKD docs", 550)
+
+	/* Append to a new file, then to the existing one */
+	for _, content := range []string{"first ", "second"} {
+		data := c.passive(true)
+		c.send("APPE docs/notes.txt", 150)
+		data.Write([]byte(content))
+		data.Close()
+		c.expect(226)
+	}
+
+	if info, err := driver.Stat("/docs/notes.txt"); err != nil || info.Size() != int64(len("first second")) {
+		t.Fatal("Invalid appended file.", info, err)
+	}
+
+	c.send("SITE CHMOD 600 docs/notes.txt", 200)
+	c.send("SITE CHMOD rw docs/notes.txt", 501)
+	c.send("SITE EXEC ls", 504)
+
+	if info, _ := driver.Stat("/docs/notes.txt"); info.Mode().Perm() != 0600 {
+		t.Fatal("Invalid file mode.", info.Mode())
+	}
+
+	/* RNTO has to follow RNFR */
+	c.send("RNTO notes.bak", 503)
+	c.send("RNFR docs/notes.txt", 350)
+	c.send("NOOP", 200)
+	c.send("RNTO notes.bak", 503)
+	c.send("RNFR docs/notes.txt", 350)
+	c.send("RNTO notes.bak", 250)
+
+	c.send("RMD docs", 250)
+	c.send("RMD notes.bak", 550)
+	c.send("DELE notes.bak", 250)
+	c.send("DELE notes.bak", 550)
+}
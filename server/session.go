@@ -0,0 +1,991 @@
+package server
+
+import (
+	Access "github.com/ghepesdoru/bookwormFTP/core/access"
+	Address "github.com/ghepesdoru/bookwormFTP/core/addr"
+	Status "github.com/ghepesdoru/bookwormFTP/core/codes"
+	Commands "github.com/ghepesdoru/bookwormFTP/core/commands"
+	Credentials "github.com/ghepesdoru/bookwormFTP/core/credentials"
+	Resource "github.com/ghepesdoru/bookwormFTP/core/parsers/resource"
+	Net "net"
+	Path "path"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ListRecentFormat	= "Jan _2 15:04"
+	ListOldFormat		= "Jan _2  2006"
+	ListRecentPeriod	= 180 * 24 * time.Hour
+)
+
+var (
+	ERR_NoDataConnection	= fmt.Errorf("No data connection accepted.")
+	ERR_ForeignDataPeer		= fmt.Errorf("Data connection opened by a different host than the control connection peer.")
+
+	/* Permissions meaningful for each resource type (RFC3659 7.5.5) */
+	DirPermissions			= []Access.Perm{Access.PERM_Create, Access.PERM_Delete, Access.PERM_Execute, Access.PERM_Rename, Access.PERM_List, Access.PERM_Make, Access.PERM_Purge}
+	FilePermissions			= []Access.Perm{Access.PERM_Append, Access.PERM_Delete, Access.PERM_Rename, Access.PERM_Retrievable, Access.PERM_Storable}
+)
+
+/* Command handler definition */
+type handler struct {
+	run				func(s *session, param string)
+	loginRequired	bool
+	feature			string	/* FEAT parameters */
+}
+
+/* Registry of the implemented commands. FEAT advertises all the registered commands that are not base commands */
+var handlers map[string]*handler
+
+func init() {
+	handlers = map[string]*handler {
+		"ABOR": {(*session).abor, false, EmptyString},
+		"ACCT": {(*session).acct, false, EmptyString},
+		"APPE": {(*session).appe, true, EmptyString},
+		"CDUP": {(*session).cdup, true, EmptyString},
+		"CWD":  {(*session).cwd, true, EmptyString},
+		"DELE": {(*session).dele, true, EmptyString},
+		"EPSV": {(*session).epsv, true, EmptyString},
+		"FEAT": {(*session).feat, false, EmptyString},
+		"HOST": {(*session).host, false, EmptyString},
+		"LIST": {(*session).list, true, EmptyString},
+		"MDTM": {(*session).mdtm, true, EmptyString},
+		"MKD":  {(*session).mkd, true, EmptyString},
+		"MLSD": {(*session).mlsd, true, EmptyString},
+		"MLST": {(*session).mlst, true, "type*;size*;modify*;perm*;"},
+		"MODE": {(*session).mode, false, EmptyString},
+		"NOOP": {(*session).noop, false, EmptyString},
+		"PASS": {(*session).pass, false, EmptyString},
+		"PASV": {(*session).pasv, true, EmptyString},
+		"PWD":  {(*session).pwd, true, EmptyString},
+		"QUIT": {(*session).quit, false, EmptyString},
+		"REST": {(*session).rest, true, "STREAM"},
+		"RETR": {(*session).retr, true, EmptyString},
+		"RMD":  {(*session).rmd, true, EmptyString},
+		"RNFR": {(*session).rnfr, true, EmptyString},
+		"RNTO": {(*session).rnto, true, EmptyString},
+		"SITE": {(*session).site, true, EmptyString},
+		"SIZE": {(*session).size, true, EmptyString},
+		"STOR": {(*session).stor, true, EmptyString},
+		"STRU": {(*session).stru, false, EmptyString},
+		"SYST": {(*session).syst, false, EmptyString},
+		"TYPE": {(*session).representationType, false, EmptyString},
+		"USER": {(*session).user, false, EmptyString},
+	}
+}
+
+/* Control connection session, served on it's own goroutine */
+type session struct {
+	server			*Server
+	conn			Net.Conn
+	reader			*bufio.Reader
+	username		string
+	password		string
+	account			string
+	virtualHost		string
+	accountRequired	bool
+	loggedIn		bool
+	rights			*Access.AccessRights
+	rootDir			string	/* User's root directory, as a driver path */
+	currentDir		string	/* Current directory, relative to the user's root directory */
+	renameFrom		string
+	restart			int64	/* REST offset of the next transfer */
+	dataListener	Net.Listener
+	extendedOnly	bool
+	done			bool
+}
+
+/* Instantiates a new session over the specified control connection */
+func newSession(server *Server, conn Net.Conn) *session {
+	return &session{server, conn, bufio.NewReaderSize(conn, MaxCommandLength), EmptyString, EmptyString, EmptyString, EmptyString, false, false, Access.NewEmptyAccessRights(), RootDir, RootDir, EmptyString, 0, nil, false, false}
+}
+
+/* Greets the client and executes it's commands until QUIT, a read failure or the idle timeout */
+func (s *session) serve() {
+	defer s.closeDataListener()
+	defer s.conn.Close()
+
+	s.server.Logger.Information("Session started: " + s.conn.RemoteAddr().String())
+	s.reply(Status.Ready, "BookwormFTP server ready.")
+
+	for !s.done {
+		line, err := s.readCommand()
+		if err != nil {
+			if ne, ok := err.(Net.Error); ok && ne.Timeout() {
+				s.reply(Status.ServiceNotAvailable, "Timeout, closing control connection.")
+			}
+
+			break
+		}
+
+		if line != EmptyString {
+			s.execute(line)
+		}
+	}
+
+	s.server.Logger.Information("Session closed: " + s.conn.RemoteAddr().String())
+}
+
+/* Closes the control connection, ending the session */
+func (s *session) close() {
+	s.conn.Close()
+}
+
+/* Reads the next command line, up to the idle timeout. Command lines longer than MaxCommandLength are rejected */
+func (s *session) readCommand() (string, error) {
+	if s.server.IdleTimeout > 0 {
+		s.conn.SetReadDeadline(time.Now().Add(s.server.IdleTimeout))
+	}
+
+	line, err := s.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		/* Drop the rest of the line */
+		for err == bufio.ErrBufferFull {
+			_, err = s.reader.ReadSlice('\n')
+		}
+
+		if err == nil {
+			s.reply(Status.SyntaxError, "Command line too long.")
+		}
+
+		return EmptyString, err
+	}
+
+	if err != nil {
+		return EmptyString, err
+	}
+
+	return strings.TrimRight(string(line), EOL), nil
+}
+
+/* Executes the specified command line */
+func (s *session) execute(line string) {
+	name, param, _ := strings.Cut(line, " ")
+	name = Commands.ToStandardCommand(name)
+
+	if name == "PASS" || name == "ACCT" {
+		s.server.Logger.Information("Received: " + name + " ****")
+	} else {
+		s.server.Logger.Information("Received: " + line)
+	}
+
+	h, ok := handlers[name]
+	if !ok {
+		if name == Commands.UnknownCommand {
+			s.reply(Status.PermanentNegativeCompletion, "Syntax error, command unrecognized.")
+		} else {
+			s.reply(Status.NotImplemented, "Command not implemented.")
+		}
+
+		return
+	}
+
+	if h.loginRequired && !s.loggedIn {
+		s.reply(Status.NotLoggedIn, "Please login with USER and PASS.")
+		return
+	}
+
+	/* RNTO has to immediately follow RNFR */
+	if name != "RNTO" {
+		s.renameFrom = EmptyString
+	}
+
+	h.run(s, param)
+
+	/* The restart offset only applies to the transfer immediately following REST */
+	if name != "REST" {
+		s.restart = 0
+	}
+}
+
+/* Writes a single line reply on the control connection */
+func (s *session) reply(status int, message string) {
+	fmt.Fprintf(s.conn, "%d %s" + EOL, status, message)
+}
+
+/* Writes a multiple lines reply on the control connection (RFC959 4.2) */
+func (s *session) replyLines(status int, first string, lines []string, last string) {
+	var buffer bytes.Buffer
+
+	fmt.Fprintf(&buffer, "%d-%s" + EOL, status, first)
+	for _, line := range lines {
+		buffer.WriteString(" " + line + EOL)
+	}
+	fmt.Fprintf(&buffer, "%d %s" + EOL, status, last)
+
+	s.conn.Write(buffer.Bytes())
+}
+
+/* Resolves the specified path against the current directory, as seen by the client. The resulting path can not escape
+ the user's root directory */
+func (s *session) virtual(path string) string {
+	if !Path.IsAbs(path) {
+		path = Path.Join(s.currentDir, path)
+	}
+
+	return Path.Clean(path)
+}
+
+/* Resolves the specified path against the current directory, as a driver path inside the user's root directory */
+func (s *session) resolve(path string) string {
+	return Path.Join(s.rootDir, s.virtual(path))
+}
+
+/* Storage driver getter */
+func (s *session) driver() Driver {
+	return s.server.driver
+}
+
+/* Gets the session access rights meaningful for a file or directory */
+func (s *session) resourceRights(isDir bool) *Access.AccessRights {
+	var perm []Access.Perm
+	var candidates []Access.Perm = FilePermissions
+
+	if isDir {
+		candidates = DirPermissions
+	}
+
+	for _, p := range candidates {
+		if s.rights.Contains(p) {
+			perm = append(perm, p)
+		}
+	}
+
+	return Access.NewAccessRights(perm)
+}
+
+/* Abort the previous data transfer (ABOR). Transfers are completed (or failed) before reading the next command, so
+ there is never a transfer to abort */
+func (s *session) abor(param string) {
+	s.reply(Status.DataConnectionClose, "No transfer to abort.")
+}
+
+/* Specify the account, when required by the authenticator to complete the login (ACCT) */
+func (s *session) acct(param string) {
+	if param == EmptyString {
+		s.reply(Status.SyntaxError, "Syntax error in parameters or arguments.")
+		return
+	}
+
+	if s.loggedIn {
+		s.reply(Status.CommandNotImplemented, "Account not necessary.")
+		return
+	}
+
+	if !s.accountRequired {
+		s.reply(Status.BadSequence, "Login with USER and PASS first.")
+		return
+	}
+
+	s.account = param
+	s.login()
+}
+
+/* Receive a file from the client, appending to any existing file (APPE) */
+func (s *session) appe(param string) {
+	s.store(param, true)
+}
+
+/* Change to the parent directory (CDUP) */
+func (s *session) cdup(param string) {
+	s.cwd("..")
+}
+
+/* Change the current working directory (CWD) */
+func (s *session) cwd(param string) {
+	if param == EmptyString {
+		s.reply(Status.SyntaxError, "Syntax error in parameters or arguments.")
+		return
+	}
+
+	dir := s.virtual(param)
+	if info, err := s.driver().Stat(s.resolve(dir)); err != nil || !info.IsDir() {
+		s.reply(Status.FileUnavailable, "Failed to change directory.")
+		return
+	}
+
+	if !s.rights.Contains(Access.PERM_Execute) {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	s.currentDir = dir
+	s.reply(Status.FileActionOk, "Directory successfully changed.")
+}
+
+/* Delete a file (DELE) */
+func (s *session) dele(param string) {
+	if !s.rights.Contains(Access.PERM_Delete) {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	if err := s.driver().Delete(s.resolve(param)); err != nil {
+		s.reply(Status.FileUnavailable, "Delete operation failed.")
+		return
+	}
+
+	s.reply(Status.FileActionOk, "Delete operation successful.")
+}
+
+/* Enter extended passive mode (EPSV, RFC2428) */
+func (s *session) epsv(param string) {
+	local := Address.FromConnectionLocal(s.conn)
+
+	if strings.EqualFold(param, "ALL") {
+		/* Only EPSV is accepted from now on */
+		s.extendedOnly = true
+		s.reply(Status.PositiveCompletion, "EPSV ALL ok.")
+		return
+	}
+
+	if param != EmptyString && param != strconv.Itoa(local.IPFamily) {
+		s.reply(Status.NetworkProtocolNotSupported, fmt.Sprintf("Network protocol not supported, use (%d).", local.IPFamily))
+		return
+	}
+
+	port, err := s.listenData(local)
+	if err != nil {
+		s.reply(Status.DataConnectionFail, "Can not open passive connection.")
+		return
+	}
+
+	s.reply(Status.ExtendedPassiveMode, fmt.Sprintf("Entering Extended Passive Mode (|||%d|).", port))
+}
+
+/* List the supported extensions, as registered in the commands registry (FEAT, RFC2389) */
+func (s *session) feat(param string) {
+	var features []string
+
+	for name, h := range handlers {
+		if name == "FEAT" || (Commands.IsBase(name) && h.feature == EmptyString) {
+			continue
+		}
+
+		if h.feature != EmptyString {
+			name += " " + h.feature
+		}
+
+		features = append(features, name)
+	}
+
+	sort.Strings(features)
+	s.replyLines(Status.SystemStatus, "Extensions supported:", features, "End")
+}
+
+/* Select the virtual host, before the login sequence (HOST, RFC7151) */
+func (s *session) host(param string) {
+	if param == EmptyString {
+		s.reply(Status.SyntaxError, "Syntax error in parameters or arguments.")
+		return
+	}
+
+	if s.loggedIn || s.username != EmptyString {
+		s.reply(Status.BadSequence, "HOST has to precede the login sequence.")
+		return
+	}
+
+	s.virtualHost = param
+	s.reply(Status.Ready, "Host accepted.")
+}
+
+/* List a directory or a file in the Unix ls format (LIST) */
+func (s *session) list(param string) {
+	var infos []os.FileInfo
+
+	/* Ignore ls options (-a, -l) */
+	for strings.HasPrefix(param, "-") {
+		_, param, _ = strings.Cut(param, " ")
+	}
+
+	if !s.rights.Contains(Access.PERM_List) {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	path := s.resolve(param)
+	info, err := s.driver().Stat(path)
+	if err != nil {
+		s.reply(Status.FileUnavailable, "No such file or directory.")
+		return
+	}
+
+	if info.IsDir() {
+		if infos, err = s.driver().List(path); err != nil {
+			s.reply(Status.FileUnavailable, "Unable to list the directory.")
+			return
+		}
+	} else {
+		infos = append(infos, info)
+	}
+
+	s.transfer("Here comes the directory listing.", func(conn Net.Conn) (err error) {
+		var buffer bytes.Buffer
+
+		for _, info := range infos {
+			buffer.WriteString(listLine(info))
+		}
+
+		_, err = conn.Write(buffer.Bytes())
+		return
+	})
+}
+
+/* Get a file's last modification time (MDTM, RFC3659) */
+func (s *session) mdtm(param string) {
+	info, err := s.driver().Stat(s.resolve(param))
+	if err != nil || !info.Mode().IsRegular() {
+		s.reply(Status.FileUnavailable, "Could not get file modification time.")
+		return
+	}
+
+	s.reply(Status.FileStatus, info.ModTime().UTC().Format(Resource.TimeValFormat))
+}
+
+/* Create a directory (MKD) */
+func (s *session) mkd(param string) {
+	if param == EmptyString {
+		s.reply(Status.SyntaxError, "Syntax error in parameters or arguments.")
+		return
+	}
+
+	if !s.rights.Contains(Access.PERM_Make) {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	dir := s.virtual(param)
+	if err := s.driver().MakeDir(s.resolve(dir)); err != nil {
+		s.reply(Status.FileUnavailable, "Create directory operation failed.")
+		return
+	}
+
+	s.reply(Status.Pathname, quotePath(dir) + " created.")
+}
+
+/* List a directory in the machine readable format (MLSD, RFC3659) */
+func (s *session) mlsd(param string) {
+	if !s.rights.Contains(Access.PERM_List) {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	path := s.resolve(param)
+	info, err := s.driver().Stat(path)
+	if err != nil {
+		s.reply(Status.FileUnavailable, "No such directory.")
+		return
+	}
+
+	if !info.IsDir() {
+		s.reply(Status.SyntaxError, "Not a directory.")
+		return
+	}
+
+	infos, err := s.driver().List(path)
+	if err != nil {
+		s.reply(Status.FileUnavailable, "Unable to list the directory.")
+		return
+	}
+
+	s.transfer("Here comes the directory listing.", func(conn Net.Conn) (err error) {
+		var buffer bytes.Buffer
+
+		/* The listed directory comes first */
+		container := Resource.FromFileInfo(info, s.resourceRights(true))
+		container.Name = "."
+		container.Type = Resource.TYPE_CDir
+
+		buffer.Write(append(container.ToMLSx(), EOL...))
+		for _, info := range infos {
+			buffer.Write(append(Resource.FromFileInfo(info, s.resourceRights(info.IsDir())).ToMLSx(), EOL...))
+		}
+
+		_, err = conn.Write(buffer.Bytes())
+		return
+	})
+}
+
+/* Describe a single file or directory on the control connection (MLST, RFC3659) */
+func (s *session) mlst(param string) {
+	path := s.virtual(param)
+
+	info, err := s.driver().Stat(s.resolve(path))
+	if err != nil {
+		s.reply(Status.FileUnavailable, "No such file or directory.")
+		return
+	}
+
+	res := Resource.FromFileInfo(info, s.resourceRights(info.IsDir()))
+	res.Name = path
+
+	s.replyLines(Status.FileActionOk, "Listing " + path, []string{string(res.ToMLSx())}, "End")
+}
+
+/* Set the transfer mode (MODE). Only stream mode is supported */
+func (s *session) mode(param string) {
+	if strings.ToUpper(param) != "S" {
+		s.reply(Status.WrongParameters, "Mode not supported.")
+		return
+	}
+
+	s.reply(Status.PositiveCompletion, "Mode set to S.")
+}
+
+/* Do nothing (NOOP) */
+func (s *session) noop(param string) {
+	s.reply(Status.PositiveCompletion, "NOOP ok.")
+}
+
+/* Complete the login sequence (PASS) */
+func (s *session) pass(param string) {
+	if s.loggedIn {
+		s.reply(Status.BadSequence, "Already logged in.")
+		return
+	}
+
+	if s.username == EmptyString || s.accountRequired {
+		s.reply(Status.BadSequence, "Login with USER first.")
+		return
+	}
+
+	s.password = param
+	s.login()
+}
+
+/* Enter passive mode (PASV). Only available on IPv4 connections */
+func (s *session) pasv(param string) {
+	local := Address.FromConnectionLocal(s.conn)
+
+	if s.extendedOnly {
+		s.reply(Status.BadSequence, "Only EPSV is accepted after EPSV ALL.")
+		return
+	}
+
+	if local.IPFamily != Address.IPv4 {
+		s.reply(Status.NetworkProtocolNotSupported, "PASV is not available on IPv6 connections, use EPSV.")
+		return
+	}
+
+	port, err := s.listenData(local)
+	if err != nil {
+		s.reply(Status.DataConnectionFail, "Can not open passive connection.")
+		return
+	}
+
+	addr := &Address.Addr{IP: local.IP, Port: port, IPFamily: Address.IPv4}
+	s.reply(Status.PassiveMode, "Entering Passive Mode (" + addr.ToPortSpecifier() + ").")
+}
+
+/* Print the current working directory (PWD). Quotes are doubled as specified by RFC959 */
+func (s *session) pwd(param string) {
+	s.reply(Status.Pathname, quotePath(s.currentDir) + " is the current directory.")
+}
+
+/* End the session (QUIT) */
+func (s *session) quit(param string) {
+	s.done = true
+	s.reply(Status.ClosingControlConnection, "Goodbye.")
+}
+
+/* Set the offset the next transfer restarts at (REST STREAM, RFC3659 5) */
+func (s *session) rest(param string) {
+	offset, err := strconv.ParseInt(param, 10, 64)
+	if err != nil || offset < 0 {
+		s.reply(Status.SyntaxError, "Invalid restart offset.")
+		return
+	}
+
+	s.restart = offset
+	s.reply(Status.FileActionPending, fmt.Sprintf("Restarting at %d. Send STOR or RETR to start the transfer.", offset))
+}
+
+/* Send a file to the client (RETR), from the REST offset */
+func (s *session) retr(param string) {
+	if !s.rights.Contains(Access.PERM_Retrievable) {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	file, err := s.driver().OpenRead(s.resolve(param))
+	if err != nil {
+		s.reply(Status.FileUnavailable, "Failed to open file.")
+		return
+	}
+	defer file.Close()
+
+	if s.restart > 0 {
+		/* Downloads can not be restarted past the end of the file */
+		if info, err := s.driver().Stat(s.resolve(param)); err != nil || info.Size() < s.restart {
+			s.reply(Status.InvalidRestParameter, "Invalid REST parameter.")
+			return
+		}
+
+		if seeker, ok := file.(io.Seeker); ok {
+			_, err = seeker.Seek(s.restart, io.SeekStart)
+		} else {
+			_, err = io.CopyN(io.Discard, file, s.restart)
+		}
+
+		if err != nil {
+			s.reply(Status.InvalidRestParameter, "Invalid REST parameter.")
+			return
+		}
+	}
+
+	s.transfer("Opening data connection.", func(conn Net.Conn) (err error) {
+		_, err = io.Copy(conn, file)
+		return
+	})
+}
+
+/* Remove an empty directory (RMD) */
+func (s *session) rmd(param string) {
+	if !s.rights.Contains(Access.PERM_Delete) || s.virtual(param) == RootDir {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	if err := s.driver().RemoveDir(s.resolve(param)); err != nil {
+		s.reply(Status.FileUnavailable, "Remove directory operation failed.")
+		return
+	}
+
+	s.reply(Status.FileActionOk, "Remove directory operation successful.")
+}
+
+/* Select the file or directory to rename (RNFR) */
+func (s *session) rnfr(param string) {
+	if !s.rights.Contains(Access.PERM_Rename) || s.virtual(param) == RootDir {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	path := s.resolve(param)
+	if _, err := s.driver().Stat(path); err != nil {
+		s.reply(Status.FileUnavailable, "RNFR command failed.")
+		return
+	}
+
+	s.renameFrom = path
+	s.reply(Status.FileActionPending, "Ready for RNTO.")
+}
+
+/* Rename the file or directory selected by RNFR (RNTO) */
+func (s *session) rnto(param string) {
+	from := s.renameFrom
+	s.renameFrom = EmptyString
+
+	if from == EmptyString {
+		s.reply(Status.BadSequence, "RNFR required first.")
+		return
+	}
+
+	if err := s.driver().Rename(from, s.resolve(param)); err != nil {
+		s.reply(Status.FileUnavailable, "Rename failed.")
+		return
+	}
+
+	s.reply(Status.FileActionOk, "Rename successful.")
+}
+
+/* Site specific commands (SITE). Only CHMOD is supported */
+func (s *session) site(param string) {
+	command, args, _ := strings.Cut(param, " ")
+
+	if !strings.EqualFold(command, "CHMOD") {
+		s.reply(Status.WrongParameters, "SITE command not supported.")
+		return
+	}
+
+	mode, path, _ := strings.Cut(args, " ")
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || path == EmptyString || perm > uint64(os.ModePerm) {
+		s.reply(Status.SyntaxError, "SITE CHMOD syntax: SITE CHMOD <octal mode> <path>.")
+		return
+	}
+
+	if !s.rights.Contains(Access.PERM_Storable) {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	if err = s.driver().Chmod(s.resolve(path), os.FileMode(perm)); err != nil {
+		s.reply(Status.FileUnavailable, "SITE CHMOD command failed.")
+		return
+	}
+
+	s.reply(Status.PositiveCompletion, "SITE CHMOD command ok.")
+}
+
+/* Get a file's size (SIZE, RFC3659) */
+func (s *session) size(param string) {
+	info, err := s.driver().Stat(s.resolve(param))
+	if err != nil || !info.Mode().IsRegular() {
+		s.reply(Status.FileUnavailable, "Could not get file size.")
+		return
+	}
+
+	s.reply(Status.FileStatus, strconv.FormatInt(info.Size(), 10))
+}
+
+/* Receive a file from the client, replacing any existing file (STOR) */
+func (s *session) stor(param string) {
+	s.store(param, false)
+}
+
+/* Receives a file from the client, replacing or appending to any existing file (STOR, APPE), or restarting at the REST
+ offset */
+func (s *session) store(param string, append bool) {
+	var perm Access.Perm = Access.PERM_Storable
+
+	if param == EmptyString {
+		s.reply(Status.SyntaxError, "Syntax error in parameters or arguments.")
+		return
+	}
+
+	if append {
+		perm = Access.PERM_Append
+	}
+
+	if !s.rights.Contains(perm) {
+		s.reply(Status.FileUnavailable, "Permission denied.")
+		return
+	}
+
+	/* Uploads can only be restarted at the end of the existing file (the drivers do not support partial overwrites) */
+	if s.restart > 0 && !append {
+		if info, err := s.driver().Stat(s.resolve(param)); err != nil || info.Size() != s.restart {
+			s.reply(Status.InvalidRestParameter, "Invalid REST parameter.")
+			return
+		}
+
+		append = true
+	}
+
+	file, err := s.driver().OpenWrite(s.resolve(param), append)
+	if err != nil {
+		s.reply(Status.FileUnavailable, "Could not create file.")
+		return
+	}
+	defer file.Close()
+
+	s.transfer("Ok to send data.", func(conn Net.Conn) (err error) {
+		_, err = io.Copy(file, conn)
+		return
+	})
+}
+
+/* Set the file structure (STRU). Only file structure is supported */
+func (s *session) stru(param string) {
+	if strings.ToUpper(param) != "F" {
+		s.reply(Status.WrongParameters, "Structure not supported.")
+		return
+	}
+
+	s.reply(Status.PositiveCompletion, "Structure set to F.")
+}
+
+/* Get the system type (SYST) */
+func (s *session) syst(param string) {
+	s.reply(Status.NAMEType, SystemType)
+}
+
+/* Set the representation type (TYPE). ASCII and image types are accepted, all data is transferred as is */
+func (s *session) representationType(param string) {
+	switch strings.Join(strings.Fields(strings.ToUpper(param)), " ") {
+	case "A", "A N":
+		s.reply(Status.PositiveCompletion, "Type set to A.")
+	case "I", "L 8":
+		s.reply(Status.PositiveCompletion, "Type set to I.")
+	default:
+		s.reply(Status.WrongParameters, "Type not supported.")
+	}
+}
+
+/* Start the login sequence (USER). Any previous login is dropped */
+func (s *session) user(param string) {
+	if param == EmptyString {
+		s.reply(Status.SyntaxError, "Syntax error in parameters or arguments.")
+		return
+	}
+
+	s.resetLogin()
+	s.username = param
+
+	if AnonymousUsers[param] {
+		s.reply(Status.UserNameOk, "Anonymous login okay, send your e-mail address as password.")
+	} else {
+		s.reply(Status.UserNameOk, "User name okay, need password.")
+	}
+}
+
+/* Authenticates the login data gathered by USER, PASS and ACCT. On success, the session is confined to the user's root
+ directory */
+func (s *session) login() {
+	var user *User
+
+	credentials, err := Credentials.NewCredentials(s.username, s.password)
+	if err == nil {
+		user, err = s.server.authenticator.Authenticate(credentials, s.account, s.virtualHost)
+	}
+
+	if errors.Is(err, ERR_AccountRequired) && s.account == EmptyString {
+		s.accountRequired = true
+		s.reply(Status.AccountForLogin, "Need account for login.")
+		return
+	}
+
+	if err == nil && user == nil {
+		err = ERR_LoginIncorrect
+	}
+
+	if err != nil {
+		s.server.Logger.Error("Login failed for " + s.username + ": " + err.Error())
+		s.resetLogin()
+		s.reply(Status.NotLoggedIn, "Login incorrect.")
+		return
+	}
+
+	rootDir := Path.Clean(RootDir + user.RootDir)
+	if info, err := s.driver().Stat(rootDir); err != nil || !info.IsDir() {
+		s.server.Logger.Error("Root directory unavailable for " + s.username + ": " + rootDir)
+		s.resetLogin()
+		s.reply(Status.NotLoggedIn, "Root directory unavailable.")
+		return
+	}
+
+	s.password = EmptyString
+	s.accountRequired = false
+	s.loggedIn = true
+	s.rootDir = rootDir
+	s.currentDir = RootDir
+
+	if user.Rights != nil {
+		s.rights = user.Rights
+	}
+
+	s.reply(Status.UserLoggedIn, "User logged in, proceed.")
+}
+
+/* Drops any login data. The virtual host is kept, as it precedes the login sequence */
+func (s *session) resetLogin() {
+	s.username = EmptyString
+	s.password = EmptyString
+	s.account = EmptyString
+	s.accountRequired = false
+	s.loggedIn = false
+	s.rights = Access.NewEmptyAccessRights()
+	s.rootDir = RootDir
+	s.currentDir = RootDir
+}
+
+/* Opens a new passive mode listener on the control connection's local address, replacing the previous one */
+func (s *session) listenData(local *Address.Addr) (port int, err error) {
+	s.closeDataListener()
+
+	listener, err := Net.Listen(local.Network(), Net.JoinHostPort(local.IP.String(), "0"))
+	if err != nil {
+		return 0, err
+	}
+
+	s.dataListener = listener
+	return listener.Addr().(*Net.TCPAddr).Port, nil
+}
+
+/* Closes the passive mode listener, if any */
+func (s *session) closeDataListener() {
+	if s.dataListener != nil {
+		s.dataListener.Close()
+		s.dataListener = nil
+	}
+}
+
+/* Accepts the client's data connection, up to the data timeout. Each passive mode listener accepts a single connection */
+func (s *session) acceptDataConnection() (conn Net.Conn, err error) {
+	listener, ok := s.dataListener.(*Net.TCPListener)
+	defer s.closeDataListener()
+
+	if !ok {
+		return nil, ERR_NoDataConnection
+	}
+
+	if s.server.DataTimeout > 0 {
+		listener.SetDeadline(time.Now().Add(s.server.DataTimeout))
+	}
+
+	if conn, err = listener.Accept(); err != nil {
+		return nil, err
+	}
+
+	/* Refuse data connections from other hosts (RFC2577 port stealing) */
+	if !Address.FromConnection(conn).IP.Equal(*Address.FromConnection(s.conn).IP) {
+		conn.Close()
+		return nil, ERR_ForeignDataPeer
+	}
+
+	return conn, nil
+}
+
+/* Runs a data transfer: marks the transfer start, accepts the data connection, transfers the data and replies with
+ the transfer outcome */
+func (s *session) transfer(message string, run func(conn Net.Conn) error) {
+	if s.dataListener == nil {
+		s.reply(Status.DataConnectionFail, "Use PASV or EPSV first.")
+		return
+	}
+
+	s.reply(Status.FileStatusOk, message)
+
+	conn, err := s.acceptDataConnection()
+	if err != nil {
+		s.reply(Status.DataConnectionFail, "Failed to establish data connection.")
+		return
+	}
+
+	err = run(conn)
+	if closeErr := conn.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		s.server.Logger.Error("Transfer failed: " + err.Error())
+		s.reply(Status.ConnectionClose, "Connection closed, transfer aborted.")
+		return
+	}
+
+	s.reply(Status.DataConnectionClose, "Transfer complete.")
+}
+
+/* Quotes the specified path, doubling any inner quotes (RFC959 Appendix II) */
+func quotePath(path string) string {
+	return "\"" + strings.ReplaceAll(path, "\"", "\"\"") + "\""
+}
+
+/* Generates a Unix ls like line describing the specified file */
+func listLine(info os.FileInfo) string {
+	var kind string = "-"
+	var format string = ListRecentFormat
+	modified := info.ModTime()
+
+	if info.IsDir() {
+		kind = "d"
+	} else if info.Mode() & os.ModeSymlink != 0 {
+		kind = "l"
+	}
+
+	if time.Since(modified) > ListRecentPeriod || modified.After(time.Now()) {
+		format = ListOldFormat
+	}
+
+	return fmt.Sprintf("%s%s 1 ftp ftp %12d %s %s" + EOL, kind, info.Mode().Perm().String()[1:], info.Size(), modified.Format(format), info.Name())
+}